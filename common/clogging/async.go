@@ -0,0 +1,250 @@
+package clogging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cmetrics "github.com/232425wxy/chainer/common/clogging/metrics"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultAsyncBufferSize    = 1024
+	defaultAsyncFlushInterval = time.Second
+)
+
+// OverflowPolicy 决定异步写入器的缓冲区被写满时如何处理新的日志记录。
+type OverflowPolicy int8
+
+const (
+	// Block 让写入者等待，直到缓冲区里的日志记录被写出、腾出空间。
+	Block OverflowPolicy = iota
+	// DropOldest 丢弃缓冲区里最旧的一条日志记录，为新的日志记录腾出空间。
+	DropOldest
+	// DropNewest 直接丢弃新的日志记录。
+	DropNewest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop_oldest"
+	case DropNewest:
+		return "drop_newest"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", p)
+	}
+}
+
+type AsyncConfig struct {
+	// BufferSize 是缓冲区最多可以容纳的日志记录条数，默认为 1024。
+	BufferSize int
+	// FlushInterval 是后台把缓冲区里的日志记录写出的时间间隔，默认为 1 秒。缓冲区被写满时会被提前写出。
+	FlushInterval time.Duration
+	Policy        OverflowPolicy
+	// Provider 用于导出被丢弃的日志记录条数，为 nil 时使用 disabled.Provider，不导出指标。
+	Provider metrics.Provider
+}
+
+// AsyncWriter 是一个 zapcore.WriteSyncer，它把日志记录先存放在一个有界的环形缓冲区里，再由后台的 go 例程
+// 批量写入下层的写入器，这样写日志的 go 例程就不会被缓慢的磁盘阻塞。调用 Sync 会立即把缓冲区写出，Core.Write
+// 在写入级别不低于 PanicLevel 的日志记录后会调用 Sync，所以这类日志记录不会滞留在缓冲区里。
+type AsyncWriter struct {
+	mutex   sync.Mutex
+	notFull *sync.Cond
+	ring    [][]byte
+	head    int
+	count   int
+	closed  bool
+	err     error
+
+	// flushMutex 保证缓冲区里的日志记录按照写入的顺序被写出。
+	flushMutex sync.Mutex
+	out        zapcore.WriteSyncer
+	policy     OverflowPolicy
+
+	dropped        uint64
+	droppedCounter metrics.Counter
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewAsyncWriter(out zapcore.WriteSyncer, c AsyncConfig) *AsyncWriter {
+	if c.Provider == nil {
+		c.Provider = &disabled.Provider{}
+	}
+	return newAsyncWriter(out, c, c.Provider.NewCounter(cmetrics.DroppedCountOpts))
+}
+
+// newAsyncWriter 使用已经创建好的 droppedCounter，而不是通过 c.Provider 再创建一个计数器。
+func newAsyncWriter(out zapcore.WriteSyncer, c AsyncConfig, droppedCounter metrics.Counter) *AsyncWriter {
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultAsyncBufferSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultAsyncFlushInterval
+	}
+
+	a := &AsyncWriter{
+		ring:           make([][]byte, c.BufferSize),
+		out:            out,
+		policy:         c.Policy,
+		droppedCounter: droppedCounter,
+		wake:           make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+	a.notFull = sync.NewCond(&a.mutex)
+
+	a.wg.Add(1)
+	go a.run(c.FlushInterval)
+	return a
+}
+
+// Write 复制 p 并将其放入缓冲区，Close 之后的写入会直接同步地写入下层的写入器。
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	bz := make([]byte, len(p))
+	copy(bz, p)
+
+	a.mutex.Lock()
+	for !a.closed && a.count == len(a.ring) {
+		switch a.policy {
+		case DropNewest:
+			a.mutex.Unlock()
+			a.drop()
+			return len(p), nil
+		case DropOldest:
+			a.ring[a.head] = nil
+			a.head = (a.head + 1) % len(a.ring)
+			a.count--
+			a.drop()
+		default:
+			a.signal()
+			a.notFull.Wait()
+		}
+	}
+	if a.closed {
+		a.mutex.Unlock()
+		a.flushMutex.Lock()
+		defer a.flushMutex.Unlock()
+		return a.out.Write(p)
+	}
+
+	a.ring[(a.head+a.count)%len(a.ring)] = bz
+	a.count++
+	full := a.count == len(a.ring)
+	a.mutex.Unlock()
+
+	if full {
+		a.signal()
+	}
+	return len(p), nil
+}
+
+// Sync 把缓冲区里的日志记录全部写出，然后同步下层的写入器，后台写出时遇到的错误也会在这里返回。
+func (a *AsyncWriter) Sync() error {
+	err := a.flush()
+	if syncErr := a.out.Sync(); err == nil {
+		err = syncErr
+	}
+	return err
+}
+
+// Dropped 返回因为缓冲区写满而被丢弃的日志记录条数。
+func (a *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Close 停止后台的 go 例程，并把缓冲区里剩余的日志记录写出。
+func (a *AsyncWriter) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.notFull.Broadcast()
+	a.mutex.Unlock()
+
+	close(a.done)
+	a.wg.Wait()
+	return a.Sync()
+}
+
+func (a *AsyncWriter) run(interval time.Duration) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.backgroundFlush()
+		case <-a.wake:
+			a.backgroundFlush()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+func (a *AsyncWriter) signal() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drop 调用者可以持有也可以不持有 a.mutex，计数器不能反过来调用 AsyncWriter。
+func (a *AsyncWriter) drop() {
+	atomic.AddUint64(&a.dropped, 1)
+	a.droppedCounter.With("policy", a.policy.String()).Add(1)
+}
+
+// flush 把缓冲区里的日志记录合并成一次写入交给下层的写入器，返回本次写出或者之前的后台写出所遇到的错误。
+func (a *AsyncWriter) flush() error {
+	a.flushMutex.Lock()
+	defer a.flushMutex.Unlock()
+
+	a.mutex.Lock()
+	var size int
+	for i := 0; i < a.count; i++ {
+		size += len(a.ring[(a.head+i)%len(a.ring)])
+	}
+	batch := make([]byte, 0, size)
+	for i := 0; i < a.count; i++ {
+		idx := (a.head + i) % len(a.ring)
+		batch = append(batch, a.ring[idx]...)
+		a.ring[idx] = nil
+	}
+	a.head, a.count = 0, 0
+	a.notFull.Broadcast()
+	err := a.err
+	a.err = nil
+	a.mutex.Unlock()
+
+	if len(batch) > 0 {
+		if _, werr := a.out.Write(batch); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// backgroundFlush 保存后台写出时遇到的错误，留给下一次 Sync 返回。
+func (a *AsyncWriter) backgroundFlush() {
+	if err := a.flush(); err != nil {
+		a.mutex.Lock()
+		if a.err == nil {
+			a.err = err
+		}
+		a.mutex.Unlock()
+	}
+}
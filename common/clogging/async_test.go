@@ -0,0 +1,260 @@
+package clogging
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/232425wxy/chainer/common/metrics/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedWriter 在 gate 被关闭之前会阻塞所有的写入，每次进入 Write 时向 entered 发送一个信号。
+type gatedWriter struct {
+	mutex    sync.Mutex
+	buf      bytes.Buffer
+	syncs    int
+	writeErr error
+	gate     chan struct{}
+	entered  chan struct{}
+}
+
+func newGatedWriter(open bool) *gatedWriter {
+	w := &gatedWriter{gate: make(chan struct{}), entered: make(chan struct{}, 16)}
+	if open {
+		close(w.gate)
+	}
+	return w
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	select {
+	case w.entered <- struct{}{}:
+	default:
+	}
+	<-w.gate
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.writeErr != nil {
+		return 0, w.writeErr
+	}
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) Sync() error {
+	w.mutex.Lock()
+	w.syncs++
+	w.mutex.Unlock()
+	return nil
+}
+
+func (w *gatedWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriterBuffersUntilSync(t *testing.T) {
+	out := newGatedWriter(true)
+	a := NewAsyncWriter(out, AsyncConfig{FlushInterval: time.Hour})
+	defer a.Close()
+
+	for _, s := range []string{"a\n", "b\n", "c\n"} {
+		n, err := a.Write([]byte(s))
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+	}
+	require.Empty(t, out.String())
+
+	require.NoError(t, a.Sync())
+	require.Equal(t, "a\nb\nc\n", out.String())
+	require.Equal(t, 1, out.syncs)
+}
+
+func TestAsyncWriterFlushInterval(t *testing.T) {
+	out := newGatedWriter(true)
+	a := NewAsyncWriter(out, AsyncConfig{FlushInterval: 10 * time.Millisecond})
+	defer a.Close()
+
+	_, err := a.Write([]byte("tick\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return out.String() == "tick\n" }, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestAsyncWriterDropPolicies(t *testing.T) {
+	var tests = []struct {
+		policy   OverflowPolicy
+		expected string
+	}{
+		{policy: DropNewest, expected: "abcd"},
+		{policy: DropOldest, expected: "abde"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			counter := &metricsfakes.Counter{}
+			counter.SetWithReturns(counter)
+			provider := &metricsfakes.Provider{}
+			provider.SetNewCounterReturns(counter)
+
+			out := newGatedWriter(false)
+			a := NewAsyncWriter(out, AsyncConfig{BufferSize: 2, FlushInterval: time.Hour, Policy: tt.policy, Provider: provider})
+			defer a.Close()
+			require.Equal(t, 1, provider.NewCounterCallCount())
+
+			// 写满缓冲区后，后台的 go 例程取走 "ab" 并阻塞在下层的写入器上。
+			a.Write([]byte("a"))
+			a.Write([]byte("b"))
+			<-out.entered
+
+			a.Write([]byte("c"))
+			a.Write([]byte("d"))
+			a.Write([]byte("e"))
+			require.EqualValues(t, 1, a.Dropped())
+
+			close(out.gate)
+			require.NoError(t, a.Sync())
+			require.Equal(t, tt.expected, out.String())
+
+			require.Equal(t, 1, counter.AddCallCount())
+			require.Equal(t, float64(1), counter.AddArgsForCall(0))
+			require.Equal(t, []string{"policy", tt.policy.String()}, counter.WithArgsForCall(0))
+		})
+	}
+}
+
+func TestAsyncWriterBlockPolicy(t *testing.T) {
+	out := newGatedWriter(false)
+	a := NewAsyncWriter(out, AsyncConfig{BufferSize: 1, FlushInterval: time.Hour, Policy: Block})
+	defer a.Close()
+
+	a.Write([]byte("a"))
+	<-out.entered
+	a.Write([]byte("b"))
+
+	written := make(chan struct{})
+	go func() {
+		a.Write([]byte("c"))
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("write should block while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.gate)
+	<-written
+	require.NoError(t, a.Sync())
+	require.Equal(t, "abc", out.String())
+	require.Zero(t, a.Dropped())
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	out := newGatedWriter(true)
+	a := NewAsyncWriter(out, AsyncConfig{FlushInterval: time.Hour})
+
+	a.Write([]byte("buffered\n"))
+	require.NoError(t, a.Close())
+	require.Equal(t, "buffered\n", out.String())
+	require.NoError(t, a.Close())
+
+	n, err := a.Write([]byte("direct\n"))
+	require.NoError(t, err)
+	require.Equal(t, 7, n)
+	require.Equal(t, "buffered\ndirect\n", out.String())
+}
+
+func TestAsyncWriterBackgroundError(t *testing.T) {
+	out := newGatedWriter(true)
+	out.writeErr = errors.New("disk full")
+	a := NewAsyncWriter(out, AsyncConfig{BufferSize: 1, FlushInterval: time.Hour})
+	defer a.Close()
+
+	a.Write([]byte("a"))
+	<-out.entered
+	require.Eventually(t, func() bool {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		return a.err != nil
+	}, 5*time.Second, 5*time.Millisecond)
+
+	out.mutex.Lock()
+	out.writeErr = nil
+	out.mutex.Unlock()
+	require.EqualError(t, a.Sync(), "disk full")
+	require.NoError(t, a.Sync())
+}
+
+func TestLoggingAsyncConfig(t *testing.T) {
+	out := newGatedWriter(true)
+	l, err := New(Config{
+		Format: "%{message}",
+		Writer: out,
+		Async:  &AsyncConfig{FlushInterval: time.Hour},
+	})
+	require.NoError(t, err)
+	require.Len(t, l.owned, 1)
+
+	logger := l.Logger("async")
+	logger.Info("queued")
+	require.Empty(t, out.String())
+	require.NoError(t, logger.Sync())
+	require.Equal(t, "queued\n", out.String())
+
+	// 级别不低于 PanicLevel 的日志记录会被立即写出。
+	require.Panics(t, func() { logger.Panic("panicking") })
+	require.Equal(t, "queued\npanicking\n", out.String())
+
+	logger.Info("pending")
+	require.NoError(t, l.Apply(Config{Writer: &bytes.Buffer{}}))
+	require.Equal(t, "queued\npanicking\npending\n", out.String(), "replacing the writer should flush the async buffer")
+}
+
+func TestLoggingAsyncReapplyCreatesCounterOnce(t *testing.T) {
+	provider := &metricsfakes.Provider{}
+	counter := &metricsfakes.Counter{}
+	counter.SetWithReturns(counter)
+	provider.SetNewCounterReturns(counter)
+	c := Config{
+		Writer: &bytes.Buffer{},
+		Async:  &AsyncConfig{FlushInterval: time.Hour, Provider: provider},
+		Sinks:  []SinkConfig{{Name: "audit", Writer: &bytes.Buffer{}, Async: &AsyncConfig{FlushInterval: time.Hour, Provider: provider}}},
+	}
+	l, err := New(c)
+	require.NoError(t, err)
+	defer l.Apply(Config{Writer: &bytes.Buffer{}})
+	require.NoError(t, l.Apply(c))
+	require.NoError(t, l.Apply(c))
+	require.Equal(t, 1, provider.NewCounterCallCount())
+}
+
+func TestLoggingAsyncReapplyWithPrometheus(t *testing.T) {
+	provider := prometheus.NewRegistryProvider()
+	out := newGatedWriter(true)
+	c := Config{
+		Format: "%{message}",
+		Writer: out,
+		Async:  &AsyncConfig{BufferSize: 1, FlushInterval: time.Hour, Policy: DropNewest, Provider: provider},
+	}
+	l, err := New(c)
+	require.NoError(t, err)
+	defer l.Apply(Config{Writer: &bytes.Buffer{}})
+
+	for i := 0; i < 2; i++ {
+		require.NotPanics(t, func() { require.NoError(t, l.Apply(c)) })
+		logger := l.Logger("async")
+		logger.Info("kept")
+		logger.Info("dropped")
+	}
+
+	families, err := provider.Gatherer().Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	require.Equal(t, "logging_entries_dropped", families[0].GetName())
+	require.Equal(t, float64(2), families[0].GetMetric()[0].GetCounter().GetValue())
+}
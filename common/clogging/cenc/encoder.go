@@ -17,6 +17,8 @@ type FormatEncoder struct {
 	zapcore.Encoder
 	formatters []Formatter
 	pool       buffer.Pool
+	// context 记录通过 With 添加的字符串字段，使 %{traceid} 之类的动词也能读取到它们。
+	context []zapcore.Field
 }
 
 func NewFormatEncoder(formatters ...Formatter) *FormatEncoder {
@@ -44,13 +46,23 @@ func (f *FormatEncoder) Clone() zapcore.Encoder {
 		Encoder: f.Encoder.Clone(),
 		formatters: f.formatters,
 		pool: f.pool,
+		context: f.context[:len(f.context):len(f.context)],
 	}
 }
 
+func (f *FormatEncoder) AddString(key, value string) {
+	f.Encoder.AddString(key, value)
+	f.context = append(f.context, zapcore.Field{Key: key, Type: zapcore.StringType, String: value})
+}
+
 func (f *FormatEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
 	line := f.pool.Get()
+	all := fields
+	if len(f.context) > 0 {
+		all = append(f.context[:len(f.context):len(f.context)], fields...)
+	}
 	for _, formatter := range f.formatters {
-		formatter.Format(line, entry, fields)
+		formatter.Format(line, entry, all)
 	}
 
 	encodedFields, err := f.Encoder.EncodeEntry(entry, fields)
@@ -5,6 +5,7 @@ import (
 	"io"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,7 +17,15 @@ import (
 // %{color:red}%{level:debug}  匹配结果：共找到两处匹配：%{color:red}和%{level:debug}；
 // %{color}%{messagee}  匹配结果：共找到一处匹配：%{color}；
 // %{id:123}xxxx%{module::p2p}  匹配结果：共找到两处匹配：%{id:123}和%{module::p2p}。
-var formatRegexp = regexp.MustCompile(`%{(color|id|level|message|module|shortfunc|time)(?::(.*?))?}`)
+var formatRegexp = regexp.MustCompile(`%{(channel|color|id|level|message|module|shortfunc|spanid|time|traceid|txid)(?::(.*?))?}`)
+
+// 日志记录里保存请求相关信息的字段名，%{traceid}、%{spanid}、%{txid} 和 %{channel} 分别输出这些字段的值。
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+	TxIDKey    = "tx_id"
+	ChannelKey = "channel"
+)
 
 func ParseFormat(spec string) ([]Formatter, error) {
 	cursor := 0
@@ -72,6 +81,14 @@ func NewFormatter(verb, format string) (Formatter, error) {
 		return newSequenceFormatter(format), nil
 	case "module":
 		return newModuleFormatter(format), nil
+	case "traceid":
+		return newFieldFormatter(TraceIDKey, format), nil
+	case "spanid":
+		return newFieldFormatter(SpanIDKey, format), nil
+	case "txid":
+		return newFieldFormatter(TxIDKey, format), nil
+	case "channel":
+		return newFieldFormatter(ChannelKey, format), nil
 	default:
 		return nil, fmt.Errorf("unknown verb: %s", verb)
 	}
@@ -250,6 +267,46 @@ func (mf ModuleFormatter) Format(w io.Writer, entry zapcore.Entry, fields []zapc
 	fmt.Fprintf(w, mf.FormatVerb, entry.LoggerName)
 }
 
+// => FieldFormatter
+
+// FieldFormatter 输出日志记录里名为 Key 的字段的值，没有这个字段时输出空字符串。
+type FieldFormatter struct {
+	Key        string
+	FormatVerb string
+}
+
+func newFieldFormatter(key, fv string) FieldFormatter {
+	return FieldFormatter{Key: key, FormatVerb: "%" + stringOrDefault(fv, "s")}
+}
+
+func (ff FieldFormatter) Format(w io.Writer, entry zapcore.Entry, fields []zapcore.Field) {
+	var value string
+	// 调用时传入的字段排在 With 添加的字段之后，同名时以后面的为准。
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == ff.Key {
+			value = fieldValue(fields[i])
+			break
+		}
+	}
+	fmt.Fprintf(w, ff.FormatVerb, value)
+}
+
+func fieldValue(f zapcore.Field) string {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(f.Integer, 10)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+		return strconv.FormatUint(uint64(f.Integer), 10)
+	default:
+		if f.Interface != nil {
+			return fmt.Sprint(f.Interface)
+		}
+		return f.String
+	}
+}
+
 // => StringFormatter
 
 type StringFormatter struct {
@@ -63,6 +63,21 @@ func TestParseFormat(t *testing.T) {
 			},
 			err: "",
 		},
+		{
+			desc: "traceid spanid txid channel",
+			spec: "[%{traceid}/%{spanid:.8s}] %{txid} %{channel:-10s}",
+			formatters: []Formatter{
+				StringFormatter{Value: "["},
+				FieldFormatter{Key: TraceIDKey, FormatVerb: "%s"},
+				StringFormatter{Value: "/"},
+				FieldFormatter{Key: SpanIDKey, FormatVerb: "%.8s"},
+				StringFormatter{Value: "] "},
+				FieldFormatter{Key: TxIDKey, FormatVerb: "%s"},
+				StringFormatter{Value: " "},
+				FieldFormatter{Key: ChannelKey, FormatVerb: "%-10s"},
+			},
+			err: "",
+		},
 	}
 
 	for _, test := range tests {
@@ -141,3 +156,28 @@ func TestSequenceFormatter(t *testing.T) {
 
 	t.Log(results)
 }
+
+func TestFieldFormatter(t *testing.T) {
+	formatters, err := ParseFormat("[%{traceid}] %{message}")
+	require.NoError(t, err)
+	enc := NewFormatEncoder(formatters...)
+
+	entry := zapcore.Entry{Message: "hello"}
+	buf, err := enc.EncodeEntry(entry, nil)
+	require.NoError(t, err)
+	require.Equal(t, "[] hello\n", buf.String())
+
+	// 通过 With 添加的字段也能被动词读取到，调用时传入的同名字段优先。
+	clone := enc.Clone()
+	clone.AddString(TraceIDKey, "abc")
+	buf, err = clone.EncodeEntry(entry, nil)
+	require.NoError(t, err)
+	require.Equal(t, "[abc] hello trace_id=abc\n", buf.String())
+	buf, err = clone.EncodeEntry(entry, []zapcore.Field{{Key: TraceIDKey, Type: zapcore.Int64Type, Integer: 42}})
+	require.NoError(t, err)
+	require.Equal(t, "[42] hello trace_id=abc trace_id=42\n", buf.String())
+
+	buf, err = enc.EncodeEntry(entry, nil)
+	require.NoError(t, err)
+	require.Equal(t, "[] hello\n", buf.String(), "the original encoder is not affected by its clone")
+}
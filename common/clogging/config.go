@@ -0,0 +1,495 @@
+package clogging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig 是日志配置文件的结构，YAML 和 JSON 使用相同的字段名。例如：
+//
+//	format: json
+//	level: info
+//	loggers:
+//	  gossip: debug
+//	timezone: UTC
+//	output: /var/log/chainer/node.log
+//	sampling:
+//	  first: 100
+//	  thereafter: 10
+//	  rate_limits:
+//	    gossip: {rate: 50, burst: 100}
+//	fields:
+//	  gossip: {node: peer0, msp: Org1MSP}
+//	grpc:
+//	  levels: {info: debug}
+//	  verbosity: [info, debug]
+//	sinks:
+//	  - name: errors
+//	    spec: error
+//	    output: stderr
+//	    color: auto
+type fileConfig struct {
+	Format   string                            `yaml:"format" json:"format"`
+	Spec     string                            `yaml:"spec" json:"spec"`
+	Level    string                            `yaml:"level" json:"level"`
+	Loggers  map[string]string                 `yaml:"loggers" json:"loggers"`
+	Color    string                            `yaml:"color" json:"color"`
+	TimeZone string                            `yaml:"timezone" json:"timezone"`
+	Output   string                            `yaml:"output" json:"output"`
+	Rotation *fileRotation                     `yaml:"rotation" json:"rotation"`
+	Async    *fileAsync                        `yaml:"async" json:"async"`
+	Sampling *fileSampling                     `yaml:"sampling" json:"sampling"`
+	Fields   map[string]map[string]interface{} `yaml:"fields" json:"fields"`
+	GRPC     *fileGRPC                         `yaml:"grpc" json:"grpc"`
+	Sinks    []fileSink                        `yaml:"sinks" json:"sinks"`
+}
+
+type fileSink struct {
+	Name     string            `yaml:"name" json:"name"`
+	Format   string            `yaml:"format" json:"format"`
+	Spec     string            `yaml:"spec" json:"spec"`
+	Level    string            `yaml:"level" json:"level"`
+	Loggers  map[string]string `yaml:"loggers" json:"loggers"`
+	Color    string            `yaml:"color" json:"color"`
+	Output   string            `yaml:"output" json:"output"`
+	Rotation *fileRotation     `yaml:"rotation" json:"rotation"`
+	Async    *fileAsync        `yaml:"async" json:"async"`
+}
+
+type fileRotation struct {
+	Filename   string `yaml:"filename" json:"filename"`
+	MaxSize    int    `yaml:"max_size" json:"max_size"`
+	MaxAge     string `yaml:"max_age" json:"max_age"`
+	MaxBackups int    `yaml:"max_backups" json:"max_backups"`
+	Compress   bool   `yaml:"compress" json:"compress"`
+	LocalTime  bool   `yaml:"local_time" json:"local_time"`
+}
+
+type fileAsync struct {
+	BufferSize    int    `yaml:"buffer_size" json:"buffer_size"`
+	FlushInterval string `yaml:"flush_interval" json:"flush_interval"`
+	Policy        string `yaml:"policy" json:"policy"`
+}
+
+// fileSampling 对应 SamplingConfig。first 和 thereafter 按日志记录器、级别和调用位置（源文件和行号）计数，而不是按
+// 消息计数，见 SamplingConfig。
+type fileSampling struct {
+	Tick            string                   `yaml:"tick" json:"tick"`
+	First           int                      `yaml:"first" json:"first"`
+	Thereafter      int                      `yaml:"thereafter" json:"thereafter"`
+	RateLimits      map[string]fileRateLimit `yaml:"rate_limits" json:"rate_limits"`
+	SummaryInterval string                   `yaml:"summary_interval" json:"summary_interval"`
+}
+
+type fileGRPC struct {
+	Name      string            `yaml:"name" json:"name"`
+	Levels    map[string]string `yaml:"levels" json:"levels"`
+	Verbosity []string          `yaml:"verbosity" json:"verbosity"`
+	Filters   []string          `yaml:"filters" json:"filters"`
+}
+
+type fileRateLimit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// configError 记录出错的配置项的路径，例如 "sinks[1].rotation.max_age"。
+type configError struct {
+	path string
+	err  error
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.err)
+}
+
+func fieldError(path string, err error) error {
+	return &configError{path: path, err: err}
+}
+
+// LoadConfig 读取 YAML 或者 JSON 格式的日志配置文件，后缀为 ".json" 的文件按照 JSON 解析，其他文件按照 YAML 解析。
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return parseConfigFile(path, data)
+}
+
+func parseConfigFile(path string, data []byte) (Config, error) {
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	c, err := ParseConfig(data, format)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid logging config %s: %s", path, err)
+	}
+	return c, nil
+}
+
+// ParseConfig 解析并校验日志配置，format 可以是 "yaml" 或者 "json"。未知的字段会被视为错误。
+func ParseConfig(data []byte, format string) (Config, error) {
+	var fc fileConfig
+	switch format {
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, err
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, err
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported config format: %s", format)
+	}
+	return fc.config()
+}
+
+// ApplyFile 加载 path 指定的配置文件并将其应用到 l 上，加载或者应用失败时 l 原有的配置保持不变。
+func (l *Logging) ApplyFile(path string) error {
+	c, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	return l.Apply(c)
+}
+
+func (fc *fileConfig) config() (Config, error) {
+	c := Config{Format: fc.Format, Output: fc.Output}
+	var err error
+	if _, _, err = parseFormat(fc.Format); err != nil {
+		return Config{}, fieldError("format", err)
+	}
+	if c.LogSpec, err = logSpec("", fc.Spec, fc.Level, fc.Loggers); err != nil {
+		return Config{}, err
+	}
+	if c.Color, err = parseColorMode(fc.Color); err != nil {
+		return Config{}, fieldError("color", err)
+	}
+	if fc.TimeZone != "" {
+		if c.TimeZone, err = time.LoadLocation(fc.TimeZone); err != nil {
+			return Config{}, fieldError("timezone", err)
+		}
+	}
+	if c.Rotate, err = fc.Rotation.config("rotation", fc.Output); err != nil {
+		return Config{}, err
+	}
+	if c.Async, err = fc.Async.config("async"); err != nil {
+		return Config{}, err
+	}
+	if c.Sampling, err = fc.Sampling.config("sampling"); err != nil {
+		return Config{}, err
+	}
+	if c.FieldRules, err = fieldRulesConfig("fields", fc.Fields); err != nil {
+		return Config{}, err
+	}
+	if c.GRPC, err = fc.GRPC.config("grpc"); err != nil {
+		return Config{}, err
+	}
+
+	names := map[string]struct{}{}
+	for i, fs := range fc.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		if _, ok := names[fs.Name]; ok {
+			return Config{}, fieldError(path+".name", fmt.Errorf("duplicate sink name: '%s'", fs.Name))
+		}
+		names[fs.Name] = struct{}{}
+		sc, err := fs.config(path)
+		if err != nil {
+			return Config{}, err
+		}
+		c.Sinks = append(c.Sinks, sc)
+	}
+	return c, nil
+}
+
+func (fs *fileSink) config(path string) (SinkConfig, error) {
+	if !isValidLoggerName(fs.Name) {
+		return SinkConfig{}, fieldError(path+".name", fmt.Errorf("invalid sink name: '%s'", fs.Name))
+	}
+	sc := SinkConfig{Name: fs.Name, Format: fs.Format, Output: fs.Output}
+	var err error
+	if _, _, err = parseFormat(fs.Format); err != nil {
+		return SinkConfig{}, fieldError(path+".format", err)
+	}
+	if sc.LogSpec, err = logSpec(path+".", fs.Spec, fs.Level, fs.Loggers); err != nil {
+		return SinkConfig{}, err
+	}
+	if sc.Color, err = parseColorMode(fs.Color); err != nil {
+		return SinkConfig{}, fieldError(path+".color", err)
+	}
+	if sc.Rotate, err = fs.Rotation.config(path+".rotation", fs.Output); err != nil {
+		return SinkConfig{}, err
+	}
+	if sc.Async, err = fs.Async.config(path + ".async"); err != nil {
+		return SinkConfig{}, err
+	}
+	return sc, nil
+}
+
+func (fr *fileRotation) config(path, output string) (*RotateConfig, error) {
+	if fr == nil {
+		return nil, nil
+	}
+	if output != "" {
+		return nil, fieldError(path, errors.New("rotation and output are mutually exclusive"))
+	}
+	if fr.Filename == "" {
+		return nil, fieldError(path+".filename", errors.New("must not be empty"))
+	}
+	if fr.MaxSize < 0 {
+		return nil, fieldError(path+".max_size", errors.New("must not be negative"))
+	}
+	if fr.MaxBackups < 0 {
+		return nil, fieldError(path+".max_backups", errors.New("must not be negative"))
+	}
+	rc := &RotateConfig{
+		Filename:   fr.Filename,
+		MaxSize:    fr.MaxSize,
+		MaxBackups: fr.MaxBackups,
+		Compress:   fr.Compress,
+		LocalTime:  fr.LocalTime,
+	}
+	if fr.MaxAge != "" {
+		maxAge, err := parseDuration(fr.MaxAge)
+		if err != nil {
+			return nil, fieldError(path+".max_age", err)
+		}
+		rc.MaxAge = maxAge
+	}
+	return rc, nil
+}
+
+func (fa *fileAsync) config(path string) (*AsyncConfig, error) {
+	if fa == nil {
+		return nil, nil
+	}
+	if fa.BufferSize < 0 {
+		return nil, fieldError(path+".buffer_size", errors.New("must not be negative"))
+	}
+	ac := &AsyncConfig{BufferSize: fa.BufferSize}
+	if fa.FlushInterval != "" {
+		interval, err := parseDuration(fa.FlushInterval)
+		if err != nil {
+			return nil, fieldError(path+".flush_interval", err)
+		}
+		ac.FlushInterval = interval
+	}
+	policy, err := parseOverflowPolicy(fa.Policy)
+	if err != nil {
+		return nil, fieldError(path+".policy", err)
+	}
+	ac.Policy = policy
+	return ac, nil
+}
+
+func (fs *fileSampling) config(path string) (*SamplingConfig, error) {
+	if fs == nil {
+		return nil, nil
+	}
+	if fs.First < 0 {
+		return nil, fieldError(path+".first", errors.New("must not be negative"))
+	}
+	if fs.Thereafter < 0 {
+		return nil, fieldError(path+".thereafter", errors.New("must not be negative"))
+	}
+	sc := &SamplingConfig{First: fs.First, Thereafter: fs.Thereafter}
+	var err error
+	if fs.Tick != "" {
+		if sc.Tick, err = parseDuration(fs.Tick); err != nil {
+			return nil, fieldError(path+".tick", err)
+		}
+	}
+	if fs.SummaryInterval != "" {
+		// 汇总间隔允许为负数，表示不输出汇总。
+		if sc.SummaryInterval, err = time.ParseDuration(fs.SummaryInterval); err != nil {
+			return nil, fieldError(path+".summary_interval", err)
+		}
+	}
+
+	var names []string
+	for name := range fs.RateLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		limit := fs.RateLimits[name]
+		if !isValidLoggerName(strings.TrimSuffix(name, ".")) {
+			return nil, fieldError(path+".rate_limits", fmt.Errorf("invalid logger name: '%s'", name))
+		}
+		if limit.Rate <= 0 {
+			return nil, fieldError(fmt.Sprintf("%s.rate_limits.%s.rate", path, name), errors.New("must be positive"))
+		}
+		if limit.Burst < 0 {
+			return nil, fieldError(fmt.Sprintf("%s.rate_limits.%s.burst", path, name), errors.New("must not be negative"))
+		}
+		if sc.RateLimits == nil {
+			sc.RateLimits = map[string]RateLimit{}
+		}
+		sc.RateLimits[name] = RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	return sc, nil
+}
+
+func (fg *fileGRPC) config(path string) (GRPCConfig, error) {
+	if fg == nil {
+		return GRPCConfig{}, nil
+	}
+	gc := GRPCConfig{Name: fg.Name, Filters: fg.Filters}
+	var severities []string
+	for severity := range fg.Levels {
+		severities = append(severities, severity)
+	}
+	sort.Strings(severities)
+	for _, severity := range severities {
+		level, err := nameToLevel(fg.Levels[severity])
+		if err != nil {
+			return GRPCConfig{}, fieldError(path+".levels."+severity, err)
+		}
+		if gc.Levels == nil {
+			gc.Levels = map[string]zapcore.Level{}
+		}
+		gc.Levels[severity] = level
+	}
+	if fg.Verbosity != nil {
+		// 空的列表表示 V(l) 总是返回 false。
+		gc.Verbosity = []zapcore.Level{}
+	}
+	for i, name := range fg.Verbosity {
+		level, err := nameToLevel(name)
+		if err != nil {
+			return GRPCConfig{}, fieldError(fmt.Sprintf("%s.verbosity[%d]", path, i), err)
+		}
+		gc.Verbosity = append(gc.Verbosity, level)
+	}
+	// 名字、级别映射和过滤规则的其余检查与 Apply 相同。
+	if _, err := newGRPCSettings(gc); err != nil {
+		return GRPCConfig{}, fieldError(path, err)
+	}
+	return gc, nil
+}
+
+// fieldRulesConfig 把配置文件里的字段规则转换成 zap 的字段，同一个规则里的字段按照键排序。
+func fieldRulesConfig(path string, rules map[string]map[string]interface{}) (map[string][]zapcore.Field, error) {
+	var selectors []string
+	for selector := range rules {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+
+	var fr map[string][]zapcore.Field
+	for _, selector := range selectors {
+		if !isValidLoggerSelector(strings.TrimSuffix(selector, ".")) {
+			return nil, fieldError(path, fmt.Errorf("invalid logger name: '%s'", selector))
+		}
+		var keys []string
+		for key := range rules[selector] {
+			if key == "" {
+				return nil, fieldError(path+"."+selector, errors.New("field key must not be empty"))
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if fr == nil {
+			fr = map[string][]zapcore.Field{}
+		}
+		fields := []zapcore.Field{}
+		for _, key := range keys {
+			fields = append(fields, zap.Any(key, rules[selector][key]))
+		}
+		fr[selector] = fields
+	}
+	return fr, nil
+}
+
+// logSpec 把 spec 或者 level 与 loggers 的组合转换成日志规范，两种写法不能同时使用。
+func logSpec(prefix, spec, level string, loggers map[string]string) (string, error) {
+	if spec != "" {
+		if level != "" || len(loggers) != 0 {
+			return "", fieldError(prefix+"spec", errors.New("spec cannot be combined with level or loggers"))
+		}
+		if err := (&LoggerLevels{}).ActivateSpec(spec); err != nil {
+			return "", fieldError(prefix+"spec", err)
+		}
+		return spec, nil
+	}
+
+	if level != "" && !IsValidLevel(level) {
+		return "", fieldError(prefix+"level", fmt.Errorf("invalid log level: %s", level))
+	}
+	var names []string
+	for name := range loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var fields []string
+	for _, name := range names {
+		if !isValidLoggerSelector(strings.TrimSuffix(name, ".")) {
+			return "", fieldError(prefix+"loggers", fmt.Errorf("invalid logger name: '%s'", name))
+		}
+		if !IsValidLevel(loggers[name]) {
+			return "", fieldError(fmt.Sprintf("%sloggers.%s", prefix, name), fmt.Errorf("invalid log level: %s", loggers[name]))
+		}
+		fields = append(fields, fmt.Sprintf("%s=%s", name, loggers[name]))
+	}
+	if len(fields) == 0 {
+		return level, nil
+	}
+	if level == "" {
+		level = defaultLevel.String()
+	}
+	return strings.Join(append(fields, level), ":"), nil
+}
+
+func parseColorMode(mode string) (ColorMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	case "auto":
+		return ColorAuto, nil
+	default:
+		return ColorAlways, fmt.Errorf("invalid color mode '%s': expected always, never or auto", mode)
+	}
+}
+
+func parseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	for _, p := range []OverflowPolicy{Block, DropOldest, DropNewest} {
+		if policy == p.String() {
+			return p, nil
+		}
+	}
+	if policy == "" {
+		return Block, nil
+	}
+	return Block, fmt.Errorf("invalid overflow policy '%s': expected block, drop_oldest or drop_newest", policy)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration %s must not be negative", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,178 @@
+package clogging_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigYAML(t *testing.T) {
+	c, err := clogging.ParseConfig([]byte(`
+format: "%{color}%{level} %{message}%{color:reset}"
+level: warn
+loggers:
+  gossip: debug
+  consensus.raft: error
+color: never
+timezone: UTC
+async:
+  buffer_size: 16
+  flush_interval: 100ms
+  policy: drop_oldest
+sampling:
+  first: 10
+  thereafter: 100
+  summary_interval: -1s
+  rate_limits:
+    gossip: {rate: 5, burst: 10}
+sinks:
+  - name: file
+    format: json
+    spec: info
+    rotation:
+      filename: /tmp/node.log
+      max_size: 10
+      max_age: 72h
+      max_backups: 3
+      compress: true
+`), "yaml")
+	require.NoError(t, err)
+	require.Equal(t, "consensus.raft=error:gossip=debug:warn", c.LogSpec)
+	require.Equal(t, clogging.ColorNever, c.Color)
+	require.Equal(t, time.UTC, c.TimeZone)
+	require.Equal(t, &clogging.AsyncConfig{BufferSize: 16, FlushInterval: 100 * time.Millisecond, Policy: clogging.DropOldest}, c.Async)
+	require.Equal(t, &clogging.SamplingConfig{
+		First:           10,
+		Thereafter:      100,
+		SummaryInterval: -time.Second,
+		RateLimits:      map[string]clogging.RateLimit{"gossip": {Rate: 5, Burst: 10}},
+	}, c.Sampling)
+	require.Equal(t, []clogging.SinkConfig{{
+		Name:    "file",
+		Format:  "json",
+		LogSpec: "info",
+		Rotate: &clogging.RotateConfig{
+			Filename:   "/tmp/node.log",
+			MaxSize:    10,
+			MaxAge:     72 * time.Hour,
+			MaxBackups: 3,
+			Compress:   true,
+		},
+	}}, c.Sinks)
+}
+
+func TestParseConfigJSON(t *testing.T) {
+	c, err := clogging.ParseConfig([]byte(`{"format": "json", "spec": "gossip=debug:info", "output": "stdout", "color": "auto"}`), "json")
+	require.NoError(t, err)
+	require.Equal(t, clogging.Config{Format: "json", LogSpec: "gossip=debug:info", Output: "stdout", Color: clogging.ColorAuto}, c)
+
+	c, err = clogging.ParseConfig(nil, "yaml")
+	require.NoError(t, err)
+	require.Equal(t, clogging.Config{}, c)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		format string
+		data   string
+		err    string
+	}{
+		{desc: "unknown format", format: "toml", err: "unsupported config format: toml"},
+		{desc: "unknown yaml field", format: "yaml", data: "levle: info", err: "yaml: unmarshal errors:\n  line 1: field levle not found in type clogging.fileConfig"},
+		{desc: "unknown json field", format: "json", data: `{"levle": "info"}`, err: `json: unknown field "levle"`},
+		{desc: "bad format", format: "yaml", data: `format: "%{color:bad}"`, err: "format: invalid color option: bad"},
+		{desc: "bad spec", format: "yaml", data: "spec: a=b=c", err: "spec: invalid logging specification 'a=b=c': bad segment 'a=b=c'"},
+		{desc: "spec and level", format: "yaml", data: "spec: info\nlevel: info", err: "spec: spec cannot be combined with level or loggers"},
+		{desc: "bad level", format: "yaml", data: "level: loud", err: "level: invalid log level: loud"},
+		{desc: "bad logger name", format: "yaml", data: "loggers: {'bad name': info}", err: "loggers: invalid logger name: 'bad name'"},
+		{desc: "bad logger level", format: "yaml", data: "loggers: {gossip: loud}", err: "loggers.gossip: invalid log level: loud"},
+		{desc: "bad color", format: "yaml", data: "color: rainbow", err: "color: invalid color mode 'rainbow': expected always, never or auto"},
+		{desc: "bad timezone", format: "yaml", data: "timezone: Mars/Olympus", err: "timezone: unknown time zone Mars/Olympus"},
+		{desc: "rotation and output", format: "yaml", data: "output: stdout\nrotation: {filename: a.log}", err: "rotation: rotation and output are mutually exclusive"},
+		{desc: "rotation filename", format: "yaml", data: "rotation: {max_size: 1}", err: "rotation.filename: must not be empty"},
+		{desc: "rotation max age", format: "yaml", data: "rotation: {filename: a.log, max_age: 3days}", err: `rotation.max_age: time: unknown unit "days" in duration "3days"`},
+		{desc: "async policy", format: "yaml", data: "async: {policy: drop_all}", err: "async.policy: invalid overflow policy 'drop_all': expected block, drop_oldest or drop_newest"},
+		{desc: "async interval", format: "yaml", data: "async: {flush_interval: -1s}", err: "async.flush_interval: duration -1s must not be negative"},
+		{desc: "sampling first", format: "yaml", data: "sampling: {first: -1}", err: "sampling.first: must not be negative"},
+		{desc: "sampling tick", format: "yaml", data: "sampling: {tick: soon}", err: `sampling.tick: time: invalid duration "soon"`},
+		{desc: "rate limit logger", format: "yaml", data: "sampling: {rate_limits: {'a b': {rate: 1}}}", err: "sampling.rate_limits: invalid logger name: 'a b'"},
+		{desc: "rate limit rate", format: "yaml", data: "sampling: {rate_limits: {gossip: {burst: 1}}}", err: "sampling.rate_limits.gossip.rate: must be positive"},
+		{desc: "sink name", format: "yaml", data: "sinks: [{name: ''}]", err: "sinks[0].name: invalid sink name: ''"},
+		{desc: "duplicate sink", format: "yaml", data: "sinks: [{name: a}, {name: a}]", err: "sinks[1].name: duplicate sink name: 'a'"},
+		{desc: "sink level", format: "yaml", data: "sinks: [{name: a}, {name: b, loggers: {gossip: loud}}]", err: "sinks[1].loggers.gossip: invalid log level: loud"},
+		{desc: "sink rotation", format: "yaml", data: "sinks: [{name: a, rotation: {filename: a.log, max_backups: -1}}]", err: "sinks[0].rotation.max_backups: must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := clogging.ParseConfig([]byte(tt.data), tt.format)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestLoggingApplyFile(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "node.log")
+	path := filepath.Join(dir, "logging.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+format: "%{color}%{time:15:04} %{message}%{color:reset}"
+level: info
+color: auto
+timezone: Asia/Shanghai
+output: `+output+`
+`), 0o644))
+
+	logging, err := clogging.New(clogging.Config{Writer: &bytes.Buffer{}})
+	require.NoError(t, err)
+	require.NoError(t, logging.ApplyFile(path))
+	require.Equal(t, "Asia/Shanghai", logging.TimeZone().String())
+
+	logging.Logger("test").Info("from the file config")
+	logging.Logger("test").Debug("filtered")
+	require.NoError(t, logging.Apply(clogging.Config{Writer: &bytes.Buffer{}}))
+
+	bz, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Regexp(t, `^\d\d:\d\d from the file config\n$`, string(bz), "color codes are not written to a regular file in auto mode")
+
+	// 错误的配置文件不会改变当前的配置。
+	require.NoError(t, os.WriteFile(path, []byte("level: loud\n"), 0o644))
+	err = logging.ApplyFile(path)
+	require.EqualError(t, err, "invalid logging config "+path+": level: invalid log level: loud")
+	require.Equal(t, "info", logging.Spec())
+
+	_, err = clogging.LoadConfig(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
+
+func TestApplyFailureKeepsConfig(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{Format: "%{message}", LogSpec: "debug", Writer: buf})
+	require.NoError(t, err)
+
+	err = logging.Apply(clogging.Config{Format: "json", LogSpec: "warn", Rotate: &clogging.RotateConfig{}})
+	require.EqualError(t, err, "rotating writer requires a filename")
+	require.Equal(t, "debug", logging.Spec())
+
+	logging.Logger("test").Debug("still here")
+	require.Equal(t, "still here\n", buf.String())
+}
+
+func TestColorModeNever(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format: "%{color}%{level}%{color:reset} %{message}",
+		Color:  clogging.ColorNever,
+		Writer: buf,
+	})
+	require.NoError(t, err)
+	logging.Logger("test").Info("plain")
+	require.Equal(t, "INFO plain\n", buf.String())
+}
@@ -0,0 +1,161 @@
+package clogging
+
+import (
+	"context"
+	"sync"
+
+	"github.com/232425wxy/chainer/common/clogging/cenc"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 内置的上下文提取器使用的字段名，控制台格式里的 %{traceid}、%{spanid}、%{txid} 和 %{channel} 输出的就是这些字段。
+const (
+	TraceIDKey = cenc.TraceIDKey
+	SpanIDKey  = cenc.SpanIDKey
+	TxIDKey    = cenc.TxIDKey
+	ChannelKey = cenc.ChannelKey
+)
+
+type contextKey int
+
+const (
+	traceIDContextKey contextKey = iota
+	spanIDContextKey
+	txIDContextKey
+	channelContextKey
+)
+
+// WithTraceID 返回携带追踪 ID 的 context。
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDContextKey, traceID)
+}
+
+// WithSpanID 返回携带 span ID 的 context。
+func WithSpanID(ctx context.Context, spanID string) context.Context {
+	return context.WithValue(ctx, spanIDContextKey, spanID)
+}
+
+// WithTxID 返回携带交易 ID 的 context。
+func WithTxID(ctx context.Context, txID string) context.Context {
+	return context.WithValue(ctx, txIDContextKey, txID)
+}
+
+// WithChannel 返回携带通道名的 context。
+func WithChannel(ctx context.Context, channel string) context.Context {
+	return context.WithValue(ctx, channelContextKey, channel)
+}
+
+func TraceIDFromContext(ctx context.Context) string {
+	return contextString(ctx, traceIDContextKey)
+}
+
+func SpanIDFromContext(ctx context.Context) string {
+	return contextString(ctx, spanIDContextKey)
+}
+
+func TxIDFromContext(ctx context.Context) string {
+	return contextString(ctx, txIDContextKey)
+}
+
+func ChannelFromContext(ctx context.Context) string {
+	return contextString(ctx, channelContextKey)
+}
+
+func contextString(ctx context.Context, key contextKey) string {
+	if ctx == nil {
+		return ""
+	}
+	s, _ := ctx.Value(key).(string)
+	return s
+}
+
+// ContextExtractor 从 context 里取出需要添加到日志记录里的字段。
+type ContextExtractor func(ctx context.Context) []zapcore.Field
+
+type namedExtractor struct {
+	name      string
+	extractor ContextExtractor
+}
+
+var (
+	extractorsMutex sync.RWMutex
+	extractors      = []namedExtractor{{name: "chainer", extractor: chainerExtractor}}
+)
+
+// chainerExtractor 是默认注册的提取器，它取出通过 WithTraceID 等函数放入 context 的值。
+func chainerExtractor(ctx context.Context) []zapcore.Field {
+	var fields []zapcore.Field
+	if v := TraceIDFromContext(ctx); v != "" {
+		fields = append(fields, zap.String(TraceIDKey, v))
+	}
+	if v := SpanIDFromContext(ctx); v != "" {
+		fields = append(fields, zap.String(SpanIDKey, v))
+	}
+	if v := TxIDFromContext(ctx); v != "" {
+		fields = append(fields, zap.String(TxIDKey, v))
+	}
+	if v := ChannelFromContext(ctx); v != "" {
+		fields = append(fields, zap.String(ChannelKey, v))
+	}
+	return fields
+}
+
+// RegisterContextExtractor 注册一个上下文提取器，已经存在同名的提取器时替换它并保持原来的位置。
+// 提取器按照注册的顺序被调用，多个提取器给出同名的字段时，先注册的提取器给出的字段生效。
+func RegisterContextExtractor(name string, extractor ContextExtractor) {
+	extractorsMutex.Lock()
+	defer extractorsMutex.Unlock()
+	for i := range extractors {
+		if extractors[i].name == name {
+			extractors[i].extractor = extractor
+			return
+		}
+	}
+	extractors = append(extractors, namedExtractor{name: name, extractor: extractor})
+}
+
+// UnregisterContextExtractor 删除名为 name 的上下文提取器，内置的提取器名为 "chainer"。
+func UnregisterContextExtractor(name string) {
+	extractorsMutex.Lock()
+	defer extractorsMutex.Unlock()
+	for i := range extractors {
+		if extractors[i].name == name {
+			extractors = append(extractors[:i:i], extractors[i+1:]...)
+			return
+		}
+	}
+}
+
+// ContextFields 依次调用所有注册的上下文提取器，返回从 ctx 里取出的字段。
+func ContextFields(ctx context.Context) []zapcore.Field {
+	if ctx == nil {
+		return nil
+	}
+	extractorsMutex.RLock()
+	registered := append([]namedExtractor(nil), extractors...)
+	extractorsMutex.RUnlock()
+
+	var fields []zapcore.Field
+	for _, e := range registered {
+		for _, f := range e.extractor(ctx) {
+			if !hasField(fields, f.Key) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// contextArgs 把从 ctx 里取出的字段放在 kvs 前面，作为 SugaredLogger 的参数。
+func contextArgs(ctx context.Context, kvs []interface{}) []interface{} {
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return kvs
+	}
+	args := make([]interface{}, 0, len(fields)+len(kvs))
+	for _, f := range fields {
+		args = append(args, f)
+	}
+	return append(args, kvs...)
+}
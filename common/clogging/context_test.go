@@ -0,0 +1,68 @@
+package clogging_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestContextFields(t *testing.T) {
+	ctx := clogging.WithTraceID(context.Background(), "4bf92f35")
+	ctx = clogging.WithSpanID(ctx, "00f067aa")
+	ctx = clogging.WithTxID(ctx, "tx1")
+	ctx = clogging.WithChannel(ctx, "mychannel")
+	require.Equal(t, "4bf92f35", clogging.TraceIDFromContext(ctx))
+	require.Equal(t, "mychannel", clogging.ChannelFromContext(ctx))
+	require.Equal(t, []zapcore.Field{
+		zap.String("trace_id", "4bf92f35"),
+		zap.String("span_id", "00f067aa"),
+		zap.String("tx_id", "tx1"),
+		zap.String("channel", "mychannel"),
+	}, clogging.ContextFields(ctx))
+	require.Nil(t, clogging.ContextFields(context.Background()))
+}
+
+type tenantKey struct{}
+
+func TestRegisterContextExtractor(t *testing.T) {
+	clogging.RegisterContextExtractor("tenant", func(ctx context.Context) []zapcore.Field {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []zapcore.Field{zap.String("tenant", tenant), zap.String("channel", "ignored")}
+		}
+		return nil
+	})
+	defer clogging.UnregisterContextExtractor("tenant")
+
+	ctx := clogging.WithChannel(context.WithValue(context.Background(), tenantKey{}, "org1"), "mychannel")
+	require.Equal(t, []zapcore.Field{zap.String("channel", "mychannel"), zap.String("tenant", "org1")}, clogging.ContextFields(ctx))
+
+	clogging.UnregisterContextExtractor("tenant")
+	require.Equal(t, []zapcore.Field{zap.String("channel", "mychannel")}, clogging.ContextFields(ctx))
+}
+
+func TestChainerLoggerCtx(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format:  "[%{traceid}] %{channel} %{message}",
+		LogSpec: "debug",
+		Writer:  buf,
+	})
+	require.NoError(t, err)
+
+	ctx := clogging.WithChannel(clogging.WithTraceID(context.Background(), "abc"), "mychannel")
+	logger := logging.Logger("peer")
+	logger.InfowCtx(ctx, "committed", "height", 7)
+	logger.Ctx(ctx).Warn("slow")
+	logger.Ctx(context.Background()).Debug("plain")
+	require.Equal(t, ""+
+		"[abc] mychannel committed trace_id=abc channel=mychannel height=7\n"+
+		"[abc] mychannel slow trace_id=abc channel=mychannel\n"+
+		"[]  plain\n",
+		buf.String(),
+	)
+}
@@ -1,6 +1,10 @@
 package clogging
 
-import "go.uber.org/zap/zapcore"
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
 
 type Encoding int8
 
@@ -15,11 +19,21 @@ type EncodingSelector interface {
 	Encoding() Encoding
 }
 
+// TimeZoneSelector 用于决定日志记录里的时间在编码前需要转换到哪个时区。
+type TimeZoneSelector interface {
+	TimeZone() *time.Location
+}
+
 type Observer interface {
 	Check(entry zapcore.Entry, ce *zapcore.CheckedEntry)
 	WriteEntry(entry zapcore.Entry, fields []zapcore.Field)
 }
 
+// SinkSource 提供主输出之外的其他日志输出端。
+type SinkSource interface {
+	WithSinks(fn func(sinks []*Sink))
+}
+
 type Core struct {
 	zapcore.LevelEnabler // LevelEnabler 决定在记录消息时是否启用一个给定的日志级别。
 	Levels *LoggerLevels
@@ -27,6 +41,13 @@ type Core struct {
 	Selector EncodingSelector
 	Output zapcore.WriteSyncer
 	Observer Observer
+	Sinks SinkSource
+	TimeZone TimeZoneSelector
+	Limiter EntryLimiter
+	Rules FieldSource
+
+	// fields 是通过 With 添加的字段，Encoders 在 With 时就已经编码了这些字段，其他输出端在写入时才对它们进行编码。
+	fields []zapcore.Field
 }
 
 func (c *Core) With(fields []zapcore.Field) zapcore.Core {
@@ -43,6 +64,11 @@ func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 		Selector:     c.Selector,
 		Output:       c.Output,
 		Observer:     c.Observer,
+		Sinks:        c.Sinks,
+		TimeZone:     c.TimeZone,
+		Limiter:      c.Limiter,
+		Rules:        c.Rules,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
 	}
 }
 
@@ -51,22 +77,49 @@ func (c *Core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.Che
 		c.Observer.Check(entry, ce)
 	}
 
-	if c.Enabled(entry.Level) && c.Levels.Level(entry.LoggerName).Enabled(entry.Level) {
+	if c.Enabled(entry.Level) && (c.Levels.Level(entry.LoggerName).Enabled(entry.Level) || c.sinkEnabled(entry)) {
 		return ce.AddCore(entry, c)
 	}
 	return ce
 }
 
+// Write 把日志记录写入主输出以及所有启用了该日志记录的输出端，没有配置其他输出端时，日志记录总是会被写入主输出。
+// 采样和限流在这里而不是在 Check 里进行，因为只有到了这里日志记录才带有调用位置。
 func (c *Core) Write(e zapcore.Entry, fields []zapcore.Field) error {
-	encoding := c.Selector.Encoding()
-	enc := c.Encoders[encoding]
+	if c.Limiter != nil && !c.Limiter.Allow(e) {
+		return nil
+	}
+	if c.TimeZone != nil {
+		if loc := c.TimeZone.TimeZone(); loc != nil {
+			e.Time = e.Time.In(loc)
+		}
+	}
+	// 字段规则可以在运行时修改，所以它们在写入时才被添加，而不是在 With 时被编码到 Encoders 里。
+	if c.Rules != nil {
+		if extra := ruleFields(c.Rules.Fields(e.LoggerName), c.fields, fields); len(extra) > 0 {
+			fields = append(extra, fields...)
+		}
+	}
 
-	buf, err := enc.EncodeEntry(e, fields)
-	if err != nil {
-		return err
+	var err error
+	var hasSinks bool
+	c.withSinks(func(sinks []*Sink) {
+		hasSinks = len(sinks) > 0
+		for _, s := range sinks {
+			if !s.enabled(c.Levels, e) {
+				continue
+			}
+			if serr := s.write(e, c.fields, fields); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	})
+	// 主输出的写入不能放在 withSinks 里面，Output.Write 本身也会获取 Logging 的读锁。
+	if !hasSinks || c.Levels.Level(e.LoggerName).Enabled(e.Level) {
+		if perr := c.writePrimary(e, fields); perr != nil {
+			err = perr
+		}
 	}
-	_, err = c.Output.Write(buf.Bytes())
-	buf.Free()
 	if err != nil {
 		return err
 	}
@@ -83,7 +136,49 @@ func (c *Core) Write(e zapcore.Entry, fields []zapcore.Field) error {
 }
 
 func (c *Core) Sync() error {
-	return c.Output.Sync()
+	err := c.Output.Sync()
+	c.withSinks(func(sinks []*Sink) {
+		for _, s := range sinks {
+			if serr := s.sync(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	})
+	return err
+}
+
+func (c *Core) writePrimary(e zapcore.Entry, fields []zapcore.Field) error {
+	encoding := c.Selector.Encoding()
+	enc := c.Encoders[encoding]
+
+	buf, err := enc.EncodeEntry(e, fields)
+	if err != nil {
+		return err
+	}
+	_, err = c.Output.Write(buf.Bytes())
+	buf.Free()
+	return err
+}
+
+func (c *Core) withSinks(fn func(sinks []*Sink)) {
+	if c.Sinks == nil {
+		fn(nil)
+		return
+	}
+	c.Sinks.WithSinks(fn)
+}
+
+func (c *Core) sinkEnabled(e zapcore.Entry) bool {
+	var enabled bool
+	c.withSinks(func(sinks []*Sink) {
+		for _, s := range sinks {
+			if s.enabled(c.Levels, e) {
+				enabled = true
+				return
+			}
+		}
+	})
+	return enabled
 }
 
 func addFields(enc zapcore.ObjectEncoder, fields []zapcore.Field) {
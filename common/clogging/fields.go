@@ -0,0 +1,140 @@
+package clogging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldSource 提供根据日志记录器名自动添加到日志记录里的字段。
+type FieldSource interface {
+	Fields(loggerName string) []zapcore.Field
+}
+
+// fieldRules 是一组不可修改的字段规则，键是与日志规范相同写法的选择器，匹配的日志记录器输出的日志记录都会带上对应的字段。
+type fieldRules struct {
+	rules     map[string][]zapcore.Field
+	selectors []globSelector
+
+	mutex sync.RWMutex
+	cache map[string][]zapcore.Field
+}
+
+func newFieldRules(rules map[string][]zapcore.Field) (*fieldRules, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	fr := &fieldRules{rules: map[string][]zapcore.Field{}, cache: map[string][]zapcore.Field{}}
+	for selector, fields := range rules {
+		if !isValidLoggerSelector(strings.TrimSuffix(selector, ".")) {
+			return nil, fmt.Errorf("invalid field rule: bad logger selector '%s'", selector)
+		}
+		for _, f := range fields {
+			if f.Key == "" {
+				return nil, fmt.Errorf("invalid field rule for '%s': field key must not be empty", selector)
+			}
+		}
+		fr.rules[selector] = append([]zapcore.Field(nil), fields...)
+		fr.selectors = append(fr.selectors, newGlobSelector(selector, 0))
+	}
+	return fr, nil
+}
+
+// fields 返回 loggerName 匹配的所有规则里的字段。多个规则含有同名的字段时，最具体的规则里的字段生效，
+// 具体程度的比较规则与日志规范相同。
+func (fr *fieldRules) fields(loggerName string) []zapcore.Field {
+	fr.mutex.RLock()
+	fields, ok := fr.cache[loggerName]
+	fr.mutex.RUnlock()
+	if ok {
+		return fields
+	}
+
+	name := strings.Split(loggerName, ".")
+	var matches []selectorMatch
+	for _, s := range fr.selectors {
+		if m, ok := s.match(name); ok {
+			matches = append(matches, m)
+		}
+	}
+	// 从最不具体的规则开始合并，后合并的规则覆盖之前的同名字段。
+	sort.Slice(matches, func(i, j int) bool { return matches[j].moreSpecificThan(matches[i]) })
+	index := map[string]int{}
+	for _, m := range matches {
+		for _, f := range fr.rules[m.selector] {
+			if i, ok := index[f.Key]; ok {
+				fields[i] = f
+				continue
+			}
+			index[f.Key] = len(fields)
+			fields = append(fields, f)
+		}
+	}
+
+	fr.mutex.Lock()
+	fr.cache[loggerName] = fields
+	fr.mutex.Unlock()
+	return fields
+}
+
+// SetFieldRules 在运行时替换所有的字段规则，rules 为空时删除所有的字段规则。
+func (l *Logging) SetFieldRules(rules map[string][]zapcore.Field) error {
+	fr, err := newFieldRules(rules)
+	if err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	l.fieldRules = fr
+	l.mutex.Unlock()
+	return nil
+}
+
+// FieldRules 返回当前的字段规则。
+func (l *Logging) FieldRules() map[string][]zapcore.Field {
+	l.mutex.RLock()
+	fr := l.fieldRules
+	l.mutex.RUnlock()
+	if fr == nil {
+		return nil
+	}
+	rules := map[string][]zapcore.Field{}
+	for selector, fields := range fr.rules {
+		rules[selector] = append([]zapcore.Field(nil), fields...)
+	}
+	return rules
+}
+
+// Fields 返回名为 loggerName 的日志记录器需要自动添加的字段。
+func (l *Logging) Fields(loggerName string) []zapcore.Field {
+	l.mutex.RLock()
+	fr := l.fieldRules
+	l.mutex.RUnlock()
+	if fr == nil {
+		return nil
+	}
+	return fr.fields(loggerName)
+}
+
+// ruleFields 去掉 rules 里与 With 添加的字段或者本次调用传入的字段同名的字段，调用者明确给出的字段优先。
+func ruleFields(rules, contextFields, fields []zapcore.Field) []zapcore.Field {
+	var filtered []zapcore.Field
+	for _, r := range rules {
+		if hasField(contextFields, r.Key) || hasField(fields, r.Key) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+func hasField(fields []zapcore.Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package clogging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFieldRulesEncoders(t *testing.T) {
+	console, jsonBuf, logfmtBuf := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format:  "%{level} %{message}",
+		LogSpec: "info",
+		Writer:  console,
+		Sinks: []clogging.SinkConfig{
+			{Name: "json", Format: "json", Writer: jsonBuf},
+			{Name: "logfmt", Format: "logfmt", Writer: logfmtBuf},
+		},
+		FieldRules: map[string][]zapcore.Field{
+			"peer": {zap.String("node", "peer0"), zap.String("msp", "Org1MSP")},
+		},
+	})
+	require.NoError(t, err)
+
+	logging.Logger("peer.gossip").Infow("block received", "height", 7)
+	logging.Logger("orderer").Info("not matched")
+
+	require.Equal(t, "INFO block received node=peer0 msp=Org1MSP height=7\nINFO not matched\n", console.String())
+	lines := strings.Split(strings.TrimSuffix(logfmtBuf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `msg="block received" node=peer0 msp=Org1MSP height=7`)
+	require.NotContains(t, lines[1], "node=")
+
+	dec := json.NewDecoder(jsonBuf)
+	var entry map[string]interface{}
+	require.NoError(t, dec.Decode(&entry))
+	require.Equal(t, "peer0", entry["node"])
+	require.Equal(t, "Org1MSP", entry["msp"])
+	require.Equal(t, float64(7), entry["height"])
+	entry = nil
+	require.NoError(t, dec.Decode(&entry))
+	require.NotContains(t, entry, "node")
+}
+
+func TestFieldRulesPrecedence(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format: "%{message}",
+		Writer: buf,
+		FieldRules: map[string][]zapcore.Field{
+			"peer":           {zap.String("node", "peer0"), zap.String("channel", "none")},
+			"peer.*.deliver": {zap.String("channel", "mychannel")},
+			"peer.gossip.":   {zap.String("node", "gossip0")},
+		},
+	})
+	require.NoError(t, err)
+
+	logging.Logger("peer.gossip").Info("exact")
+	logging.Logger("peer.gossip.pull").Info("prefix")
+	logging.Logger("peer.orderer.deliver").Info("glob")
+	// 通过 With 或者调用时给出的同名字段优先于字段规则。
+	logging.Logger("peer").With("node", "explicit").Info("with")
+	logging.Logger("peer").Infow("call", "channel", "explicit")
+
+	require.Equal(t, ""+
+		"exact node=gossip0 channel=none\n"+
+		"prefix node=peer0 channel=none\n"+
+		"glob node=peer0 channel=mychannel\n"+
+		"with node=explicit channel=none\n"+
+		"call node=peer0 channel=explicit\n",
+		buf.String(),
+	)
+}
+
+func TestSetFieldRules(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{Format: "%{message}", Writer: buf})
+	require.NoError(t, err)
+	require.Nil(t, logging.FieldRules())
+
+	// 已经创建的日志记录器也会使用新的字段规则。
+	logger := logging.Logger("peer.gossip")
+	logger.Info("before")
+	require.NoError(t, logging.SetFieldRules(map[string][]zapcore.Field{"peer": {zap.Int("org", 1)}}))
+	logger.Info("after")
+	require.Len(t, logging.FieldRules()["peer"], 1)
+	require.Equal(t, []zapcore.Field{zap.Int("org", 1)}, logging.Fields("peer.gossip"))
+
+	err = logging.SetFieldRules(map[string][]zapcore.Field{"bad name": {zap.Int("org", 2)}})
+	require.EqualError(t, err, "invalid field rule: bad logger selector 'bad name'")
+	err = logging.SetFieldRules(map[string][]zapcore.Field{"peer": {zap.Int("", 2)}})
+	require.EqualError(t, err, "invalid field rule for 'peer': field key must not be empty")
+	logger.Info("unchanged")
+
+	require.NoError(t, logging.SetFieldRules(nil))
+	logger.Info("cleared")
+	require.Equal(t, "before\nafter org=1\nunchanged org=1\ncleared\n", buf.String())
+
+	err = logging.Apply(clogging.Config{FieldRules: map[string][]zapcore.Field{"peer..": {zap.Int("org", 2)}}})
+	require.EqualError(t, err, "invalid field rule: bad logger selector 'peer..'")
+}
+
+func TestParseConfigFields(t *testing.T) {
+	c, err := clogging.ParseConfig([]byte(`
+fields:
+  peer: {node: peer0, org: 1}
+  "**.deliver": {channel: mychannel}
+`), "yaml")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]zapcore.Field{
+		"peer":       {zap.Any("node", "peer0"), zap.Any("org", 1)},
+		"**.deliver": {zap.Any("channel", "mychannel")},
+	}, c.FieldRules)
+
+	_, err = clogging.ParseConfig([]byte("fields: {'bad name': {node: peer0}}"), "yaml")
+	require.EqualError(t, err, "fields: invalid logger name: 'bad name'")
+}
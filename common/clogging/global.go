@@ -14,8 +14,7 @@ func init() {
 		panic(err)
 	}
 	Global = logging
-	grpcLogger := Global.ZapLogger("grpc")
-	grpclog.SetLoggerV2(NewGRPCLogger(grpcLogger))
+	grpclog.SetLoggerV2(Global.GRPCLogger())
 }
 
 func Init(config Config) {
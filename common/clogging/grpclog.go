@@ -0,0 +1,228 @@
+package clogging
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/grpclog"
+)
+
+const defaultGRPCLoggerName = "grpc"
+
+// DefaultGRPCFilters 匹配 grpc 在连接正常关闭时也会输出的传输层日志。
+var DefaultGRPCFilters = []string{
+	`^transport: loopyWriter\.run returning`,
+	`^transport: http2Server\.HandleStreams failed to read frame`,
+	`^transport: http2Client\.notifyError got notified that the client transport was broken`,
+}
+
+// GRPCConfig 决定 grpc 内部输出的日志如何映射到 clogging。
+type GRPCConfig struct {
+	// Name 是 grpc 的日志记录器名，默认为 "grpc"。带有组件前缀（例如 "[transport]"）的日志会交给 Name.transport
+	// 日志记录器输出，所以可以在日志规范里为每个组件单独设置级别，例如 "grpc=warn:grpc.core=info"。
+	Name string
+	// Levels 把 grpc 的 "info"、"warning"、"error" 和 "fatal" 日志映射到 clogging 的级别，没有给出的使用同名的级别。
+	// fatal 日志无论映射到哪个级别，输出之后进程都会退出。
+	Levels map[string]zapcore.Level
+	// Verbosity 的第 l 个元素是 grpc 的 V(l) 对应的级别，V(l) 在该级别对 grpc 日志记录器启用时返回 true，
+	// l 超出范围时返回 false。默认为 [info, debug, debug]。
+	Verbosity []zapcore.Level
+	// Filters 是正则表达式，与其中任意一个匹配的日志（不含组件前缀）会被丢弃，fatal 日志不受影响。为 nil 时使用
+	// DefaultGRPCFilters。
+	Filters []string
+}
+
+type grpcSettings struct {
+	name      string
+	levels    [4]zapcore.Level
+	verbosity []zapcore.Level
+	filters   []*regexp.Regexp
+}
+
+const (
+	grpcInfo = iota
+	grpcWarning
+	grpcError
+	grpcFatal
+)
+
+var grpcSeverities = map[string]int{"info": grpcInfo, "warning": grpcWarning, "error": grpcError, "fatal": grpcFatal}
+
+func newGRPCSettings(c GRPCConfig) (*grpcSettings, error) {
+	s := &grpcSettings{
+		name:      c.Name,
+		levels:    [4]zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.FatalLevel},
+		verbosity: c.Verbosity,
+	}
+	if s.name == "" {
+		s.name = defaultGRPCLoggerName
+	}
+	if !isValidLoggerName(s.name) {
+		return nil, fmt.Errorf("invalid grpc logger name: '%s'", s.name)
+	}
+	for severity, level := range c.Levels {
+		i, ok := grpcSeverities[severity]
+		if !ok {
+			return nil, fmt.Errorf("invalid grpc severity '%s': expected info, warning, error or fatal", severity)
+		}
+		s.levels[i] = level
+	}
+	if s.verbosity == nil {
+		s.verbosity = []zapcore.Level{zapcore.InfoLevel, zapcore.DebugLevel, zapcore.DebugLevel}
+	}
+	filters := c.Filters
+	if filters == nil {
+		filters = DefaultGRPCFilters
+	}
+	for _, f := range filters {
+		re, err := regexp.Compile(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grpc log filter '%s': %s", f, err)
+		}
+		s.filters = append(s.filters, re)
+	}
+	return s, nil
+}
+
+func (s *grpcSettings) filtered(msg string) bool {
+	for _, re := range s.filters {
+		if re.MatchString(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCLogger 实现了 grpclog.LoggerV2 和 grpclog.DepthLoggerV2，它的配置来自 Logging，所以在 Apply 之后立即生效，
+// 日志级别则由 Logging 的日志规范动态决定。
+type GRPCLogger struct {
+	logging *Logging
+
+	mutex   sync.Mutex
+	loggers map[grpcLoggerKey]*zap.Logger
+}
+
+// grpcLoggerKey 标识一个已经跳过了 depth 层调用栈的日志记录器，grpc 只会使用少数几个不同的 depth。
+type grpcLoggerKey struct {
+	name  string
+	depth int
+}
+
+var _ grpclog.DepthLoggerV2 = &GRPCLogger{}
+
+// GRPCLogger 返回把 grpc 的日志输出到 l 的 grpclog.LoggerV2。
+func (l *Logging) GRPCLogger() *GRPCLogger {
+	return &GRPCLogger{logging: l, loggers: map[grpcLoggerKey]*zap.Logger{}}
+}
+
+func (l *Logging) grpcSettings() *grpcSettings {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.grpc
+}
+
+func (g *GRPCLogger) zapLogger(name string, depth int) *zap.Logger {
+	key := grpcLoggerKey{name: name, depth: depth}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	logger, ok := g.loggers[key]
+	if !ok {
+		logger = g.logging.ZapLogger(name).WithOptions(zap.AddCallerSkip(depth))
+		g.loggers[key] = logger
+	}
+	return logger
+}
+
+// log 输出一条 grpc 日志，depth 是从 log 到 grpc 里调用日志方法的位置之间的调用栈层数。
+func (g *GRPCLogger) log(severity, depth int, msg string) {
+	s := g.logging.grpcSettings()
+	name := s.name
+	// grpc 的组件日志以 "[组件名] " 开头。
+	if strings.HasPrefix(msg, "[") {
+		if end := strings.Index(msg, "] "); end > 0 && isValidLoggerName(msg[1:end]) {
+			name = s.name + "." + msg[1:end]
+			msg = msg[end+2:]
+		}
+	}
+
+	level := s.levels[severity]
+	logger := g.zapLogger(name, depth)
+	// fatal 日志不会被过滤，进程退出之前总是要留下原因。
+	fatal := severity == grpcFatal
+	if !fatal && (!logger.Core().Enabled(level) || s.filtered(msg)) {
+		return
+	}
+	if ce := logger.Check(level, msg); ce != nil {
+		ce.Write()
+	}
+	if fatal && level < zapcore.FatalLevel {
+		g.logging.Sync()
+		os.Exit(1)
+	}
+}
+
+// depthMessage 按照 grpc 的 Depth 方法的约定拼接参数，并在组件前缀之后补上空格。
+func depthMessage(args []interface{}) string {
+	if len(args) > 1 {
+		if prefix, ok := args[0].(string); ok && strings.HasPrefix(prefix, "[") && strings.HasSuffix(prefix, "]") {
+			return prefix + " " + fmt.Sprint(args[1:]...)
+		}
+	}
+	return fmt.Sprint(args...)
+}
+
+func (g *GRPCLogger) Info(args ...interface{})    { g.log(grpcInfo, 3, fmt.Sprint(args...)) }
+func (g *GRPCLogger) Warning(args ...interface{}) { g.log(grpcWarning, 3, fmt.Sprint(args...)) }
+func (g *GRPCLogger) Error(args ...interface{})   { g.log(grpcError, 3, fmt.Sprint(args...)) }
+func (g *GRPCLogger) Fatal(args ...interface{})   { g.log(grpcFatal, 3, fmt.Sprint(args...)) }
+
+func (g *GRPCLogger) Infoln(args ...interface{})    { g.log(grpcInfo, 3, formatArgs(args...)) }
+func (g *GRPCLogger) Warningln(args ...interface{}) { g.log(grpcWarning, 3, formatArgs(args...)) }
+func (g *GRPCLogger) Errorln(args ...interface{})   { g.log(grpcError, 3, formatArgs(args...)) }
+func (g *GRPCLogger) Fatalln(args ...interface{})   { g.log(grpcFatal, 3, formatArgs(args...)) }
+
+func (g *GRPCLogger) Infof(format string, args ...interface{}) {
+	g.log(grpcInfo, 3, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) {
+	g.log(grpcWarning, 3, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) {
+	g.log(grpcError, 3, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) {
+	g.log(grpcFatal, 3, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) InfoDepth(depth int, args ...interface{}) {
+	g.log(grpcInfo, depth+3, depthMessage(args))
+}
+
+func (g *GRPCLogger) WarningDepth(depth int, args ...interface{}) {
+	g.log(grpcWarning, depth+3, depthMessage(args))
+}
+
+func (g *GRPCLogger) ErrorDepth(depth int, args ...interface{}) {
+	g.log(grpcError, depth+3, depthMessage(args))
+}
+
+func (g *GRPCLogger) FatalDepth(depth int, args ...interface{}) {
+	g.log(grpcFatal, depth+3, depthMessage(args))
+}
+
+// V 根据配置把 grpc 的详细级别映射到 clogging 的级别，并检查 grpc 日志记录器当前是否启用了该级别。
+func (g *GRPCLogger) V(l int) bool {
+	s := g.logging.grpcSettings()
+	if l < 0 || l >= len(s.verbosity) {
+		return false
+	}
+	return g.logging.Level(s.name).Enabled(s.verbosity[l])
+}
@@ -0,0 +1,145 @@
+package clogging_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestGRPCLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format:  "%{module} %{level} %{shortfunc} %{message}",
+		LogSpec: "grpc=info:grpc.transport=warn",
+		Writer:  buf,
+	})
+	require.NoError(t, err)
+	grpclog.SetLoggerV2(logging.GRPCLogger())
+	defer grpclog.SetLoggerV2(clogging.Global.GRPCLogger())
+
+	grpclog.Component("core").Infof("Channel Connectivity change to %s", "READY")
+	grpclog.Component("transport").Info("transport: dialing")
+	grpclog.Component("transport").Warningf("transport: loopyWriter.run returning. connection error: %s", "closing")
+	grpclog.Component("transport").Warning("transport: handshake failed")
+	grpclog.Infoln("plain", 42)
+	grpclog.Errorf("failed %d times", 3)
+	require.Equal(t, ""+
+		"grpc.core INFO TestGRPCLogger Channel Connectivity change to READY\n"+
+		"grpc.transport WARN TestGRPCLogger transport: handshake failed\n"+
+		"grpc INFO TestGRPCLogger plain 42\n"+
+		"grpc ERROR TestGRPCLogger failed 3 times\n",
+		buf.String(),
+	)
+}
+
+func TestGRPCLoggerConfig(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format:  "%{module} %{level} %{message}",
+		LogSpec: "info",
+		Writer:  buf,
+		GRPC: clogging.GRPCConfig{
+			Name:      "rpc.grpc",
+			Levels:    map[string]zapcore.Level{"info": zapcore.DebugLevel, "warning": zapcore.ErrorLevel},
+			Verbosity: []zapcore.Level{zapcore.InfoLevel, zapcore.DebugLevel},
+			Filters:   []string{`^noisy`},
+		},
+	})
+	require.NoError(t, err)
+	logger := logging.GRPCLogger()
+
+	logger.Info("hidden at debug")
+	logger.Warning("promoted to error")
+	logger.WarningDepth(0, "[xds]", "noisy message")
+	require.True(t, logger.V(0))
+	require.False(t, logger.V(1))
+	require.False(t, logger.V(2), "verbosity beyond the configured levels is disabled")
+
+	// 级别和配置都是动态的。
+	require.NoError(t, logging.ActivateSpec("rpc.grpc=debug:info"))
+	require.True(t, logger.V(1))
+	logger.Info("now visible")
+	logger.InfoDepth(0, "[core]", "transport: loopyWriter.run returning")
+	require.NoError(t, logging.Apply(clogging.Config{Format: "%{module} %{level} %{message}", LogSpec: "grpc=debug", Writer: buf}))
+	logger.InfoDepth(0, "[core]", "transport: loopyWriter.run returning")
+	logger.InfoDepth(0, "[core]", "back to defaults")
+	require.True(t, logger.V(2))
+
+	require.Equal(t, ""+
+		"rpc.grpc ERROR promoted to error\n"+
+		"rpc.grpc DEBUG now visible\n"+
+		"rpc.grpc.core DEBUG transport: loopyWriter.run returning\n"+
+		"grpc.core INFO back to defaults\n",
+		buf.String(),
+	)
+}
+
+func TestGRPCConfigErrors(t *testing.T) {
+	_, err := clogging.New(clogging.Config{GRPC: clogging.GRPCConfig{Levels: map[string]zapcore.Level{"trace": zapcore.DebugLevel}}})
+	require.EqualError(t, err, "invalid grpc severity 'trace': expected info, warning, error or fatal")
+	_, err = clogging.New(clogging.Config{GRPC: clogging.GRPCConfig{Filters: []string{"("}}})
+	require.EqualError(t, err, "invalid grpc log filter '(': error parsing regexp: missing closing ): `(`")
+	_, err = clogging.New(clogging.Config{GRPC: clogging.GRPCConfig{Name: "bad name"}})
+	require.EqualError(t, err, "invalid grpc logger name: 'bad name'")
+
+	c, err := clogging.ParseConfig([]byte("grpc: {levels: {info: debug}, verbosity: [], filters: ['^x']}"), "yaml")
+	require.NoError(t, err)
+	require.Equal(t, clogging.GRPCConfig{
+		Levels:    map[string]zapcore.Level{"info": zapcore.DebugLevel},
+		Verbosity: []zapcore.Level{},
+		Filters:   []string{"^x"},
+	}, c.GRPC)
+	_, err = clogging.ParseConfig([]byte("grpc: {levels: {info: loud}}"), "yaml")
+	require.EqualError(t, err, "grpc.levels.info: invalid log level: loud")
+	_, err = clogging.ParseConfig([]byte("grpc: {levels: {trace: info}}"), "yaml")
+	require.EqualError(t, err, "grpc: invalid grpc severity 'trace': expected info, warning, error or fatal")
+}
+
+func TestGRPCLoggerFatalIgnoresFilters(t *testing.T) {
+	if os.Getenv("CLOGGING_GRPC_FATAL") == "1" {
+		logging, err := clogging.New(clogging.Config{
+			Format:  "%{module} %{level} %{message}",
+			LogSpec: "info",
+			GRPC:    clogging.GRPCConfig{Levels: map[string]zapcore.Level{"fatal": zapcore.ErrorLevel}, Filters: []string{`^noisy`}},
+		})
+		require.NoError(t, err)
+		logging.GRPCLogger().Fatal("noisy but fatal")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGRPCLoggerFatalIgnoresFilters$")
+	cmd.Env = append(os.Environ(), "CLOGGING_GRPC_FATAL=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	require.True(t, errors.As(err, &exitErr), "process should exit: %v", err)
+	require.Equal(t, 1, exitErr.ExitCode())
+	require.Contains(t, string(out), "grpc ERROR noisy but fatal")
+}
+
+func TestGRPCLoggerDepth(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{Format: "%{shortfunc} %{message}", Writer: buf})
+	require.NoError(t, err)
+	grpclog.SetLoggerV2(logging.GRPCLogger())
+	defer grpclog.SetLoggerV2(clogging.Global.GRPCLogger())
+
+	grpcHelper := func(depth int, msg string) { grpclog.Component("core").InfoDepth(depth, msg) }
+	for i := 0; i < 2; i++ {
+		grpcHelper(0, "helper")
+		grpcHelper(1, "caller")
+	}
+	require.Equal(t, ""+
+		"func1 helper\n"+
+		"TestGRPCLoggerDepth caller\n"+
+		"func1 helper\n"+
+		"TestGRPCLoggerDepth caller\n",
+		buf.String(),
+	)
+}
@@ -0,0 +1,149 @@
+package grpclogging
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ClientInterceptor 提供客户端的一元拦截器和流拦截器，两者共用同一组指标。客户端的日志记录里的 grpc.peer_address 是连接的目标地址。
+type ClientInterceptor struct {
+	interceptor
+}
+
+func NewClientInterceptor(c Config) *ClientInterceptor {
+	return &ClientInterceptor{interceptor: newInterceptor(c, "grpclogging.client", ClientRequestsCompletedOpts, ClientRequestDurationOpts)}
+}
+
+func (ci *ClientInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		c := newCall(ctx, method, cc.Target())
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		responseSize := 0
+		if err == nil {
+			responseSize = messageSize(reply)
+		}
+		ci.finish(c, "unary call completed", err,
+			zap.Int("grpc.request_size", messageSize(req)),
+			zap.Int("grpc.response_size", responseSize),
+		)
+		return err
+	}
+}
+
+func (ci *ClientInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		c := newCall(ctx, method, cc.Target())
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			ci.finish(c, "streaming call completed", err)
+			return nil, err
+		}
+		s := &clientStream{ClientStream: cs, interceptor: &ci.interceptor, call: c, serverStreams: desc.ServerStreams, done: make(chan struct{})}
+		go s.finishOnDone(ctx)
+		return s, nil
+	}
+}
+
+// clientStream 在收发消息时统计消息的条数和大小，并在以下任意一种情况最先发生时结束 RPC：RecvMsg 返回 io.EOF、返回错误
+// 或者收到非流式响应；调用者的 ctx 被取消或者超时；非流式响应的 RPC 在 CloseSend 之后流本身已经结束，而调用者没有在
+// 读取响应。这样调用者不再读取流时也会输出日志记录并记录指标。
+type clientStream struct {
+	grpc.ClientStream
+	interceptor   *interceptor
+	call          *call
+	serverStreams bool
+
+	mutex     sync.Mutex
+	stats     streamStats
+	receiving int
+
+	finishOnce sync.Once
+	done       chan struct{}
+}
+
+// finishOnDone 在 ctx 结束时结束 RPC，RPC 已经结束时退出。
+func (cs *clientStream) finishOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		cs.finish(status.FromContextError(ctx.Err()).Err())
+	case <-cs.done:
+	}
+}
+
+func (cs *clientStream) CloseSend() error {
+	err := cs.ClientStream.CloseSend()
+	switch {
+	case err != nil:
+		cs.finish(err)
+	case !cs.serverStreams:
+		// 服务端在收到 CloseSend 之后返回唯一的响应，流本身结束时如果调用者没有在 RecvMsg 里等待响应，就不会再读取它了。
+		go func() {
+			select {
+			case <-cs.ClientStream.Context().Done():
+				cs.mutex.Lock()
+				receiving := cs.receiving > 0
+				cs.mutex.Unlock()
+				if !receiving {
+					cs.finish(status.FromContextError(cs.ClientStream.Context().Err()).Err())
+				}
+			case <-cs.done:
+			}
+		}()
+	}
+	return err
+}
+
+func (cs *clientStream) SendMsg(m interface{}) error {
+	err := cs.ClientStream.SendMsg(m)
+	if err == nil {
+		size := messageSize(m)
+		cs.mutex.Lock()
+		cs.stats.sent++
+		cs.stats.sentBytes += size
+		cs.mutex.Unlock()
+		cs.interceptor.message(cs.call, "sent stream message", size)
+	}
+	return err
+}
+
+func (cs *clientStream) RecvMsg(m interface{}) error {
+	cs.mutex.Lock()
+	cs.receiving++
+	cs.mutex.Unlock()
+	err := cs.ClientStream.RecvMsg(m)
+	cs.mutex.Lock()
+	cs.receiving--
+	cs.mutex.Unlock()
+	switch {
+	case err == io.EOF:
+		cs.finish(nil)
+	case err != nil:
+		cs.finish(err)
+	default:
+		size := messageSize(m)
+		cs.mutex.Lock()
+		cs.stats.received++
+		cs.stats.receivedBytes += size
+		cs.mutex.Unlock()
+		cs.interceptor.message(cs.call, "received stream message", size)
+		if !cs.serverStreams {
+			cs.finish(nil)
+		}
+	}
+	return err
+}
+
+func (cs *clientStream) finish(err error) {
+	cs.finishOnce.Do(func() {
+		close(cs.done)
+		cs.mutex.Lock()
+		stats := cs.stats
+		cs.mutex.Unlock()
+		cs.interceptor.finish(cs.call, "streaming call completed", err, stats.fields()...)
+	})
+}
@@ -0,0 +1,202 @@
+// Package grpclogging 提供 gRPC 服务端和客户端的拦截器，它们为每个 RPC 输出一条结构化的日志记录，并记录请求数和耗时。
+package grpclogging
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/metrics"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ServerRequestsCompletedOpts = metrics.CounterOpts{
+		Namespace:    "grpc",
+		Subsystem:    "server",
+		Name:         "requests_completed",
+		Help:         "Number of gRPC requests completed by the server",
+		LabelNames:   []string{"grpc_service", "grpc_method", "code"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{grpc_service}.%{grpc_method}.%{code}",
+	}
+
+	ServerRequestDurationOpts = metrics.HistogramOpts{
+		Namespace:    "grpc",
+		Subsystem:    "server",
+		Name:         "request_duration",
+		Help:         "Time in seconds for the server to complete a gRPC request",
+		LabelNames:   []string{"grpc_service", "grpc_method", "code"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{grpc_service}.%{grpc_method}.%{code}",
+	}
+
+	ClientRequestsCompletedOpts = metrics.CounterOpts{
+		Namespace:    "grpc",
+		Subsystem:    "client",
+		Name:         "requests_completed",
+		Help:         "Number of gRPC requests completed by the client",
+		LabelNames:   []string{"grpc_service", "grpc_method", "code"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{grpc_service}.%{grpc_method}.%{code}",
+	}
+
+	ClientRequestDurationOpts = metrics.HistogramOpts{
+		Namespace:    "grpc",
+		Subsystem:    "client",
+		Name:         "request_duration",
+		Help:         "Time in seconds for the client to complete a gRPC request",
+		LabelNames:   []string{"grpc_service", "grpc_method", "code"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{grpc_service}.%{grpc_method}.%{code}",
+	}
+)
+
+type Config struct {
+	// Logger 默认为名为 "grpclogging.server" 或者 "grpclogging.client" 的日志记录器。
+	Logger *clogging.ChainerLogger
+	// Provider 为空时不记录指标。
+	Provider metrics.Provider
+	// CodeToLevel 根据 RPC 的状态码决定 RPC 结束时输出的日志记录的级别，默认为 DefaultCodeToLevel。
+	CodeToLevel func(code codes.Code) zapcore.Level
+	// MessageLevel 决定流式 RPC 每收发一条消息时输出的日志记录的级别，默认为 DebugLevel。
+	MessageLevel func(fullMethod string) zapcore.Level
+}
+
+// DefaultCodeToLevel 把调用方造成的错误映射为 InfoLevel 或者 WarnLevel，把服务端的错误映射为 ErrorLevel。
+func DefaultCodeToLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return zapcore.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+func defaultMessageLevel(string) zapcore.Level {
+	return zapcore.DebugLevel
+}
+
+// interceptor 是服务端和客户端拦截器共用的部分。
+type interceptor struct {
+	logger       *zap.Logger
+	codeToLevel  func(codes.Code) zapcore.Level
+	messageLevel func(string) zapcore.Level
+	completed    metrics.Counter
+	duration     metrics.Histogram
+}
+
+func newInterceptor(c Config, loggerName string, completedOpts metrics.CounterOpts, durationOpts metrics.HistogramOpts) interceptor {
+	if c.Logger == nil {
+		c.Logger = clogging.MustGetLogger(loggerName)
+	}
+	if c.CodeToLevel == nil {
+		c.CodeToLevel = DefaultCodeToLevel
+	}
+	if c.MessageLevel == nil {
+		c.MessageLevel = defaultMessageLevel
+	}
+	i := interceptor{
+		// ChainerLogger 为它自己的方法增加了一层调用栈，这里直接使用 zap.Logger，所以要去掉这一层。
+		logger:       c.Logger.Zap().WithOptions(zap.AddCallerSkip(-1)),
+		codeToLevel:  c.CodeToLevel,
+		messageLevel: c.MessageLevel,
+	}
+	if c.Provider != nil {
+		i.completed = c.Provider.NewCounter(completedOpts)
+		i.duration = c.Provider.NewHistogram(durationOpts)
+	}
+	return i
+}
+
+// call 记录一个 RPC 的信息，RPC 结束时由 finish 输出日志记录并记录指标。
+type call struct {
+	ctx        context.Context
+	fullMethod string
+	service    string
+	method     string
+	peer       string
+	start      time.Time
+}
+
+func newCall(ctx context.Context, fullMethod, peer string) *call {
+	service, method := splitMethodName(fullMethod)
+	return &call{ctx: ctx, fullMethod: fullMethod, service: service, method: method, peer: peer, start: time.Now()}
+}
+
+func (i *interceptor) finish(c *call, msg string, err error, extra ...zapcore.Field) {
+	elapsed := time.Since(c.start)
+	code := status.Code(err)
+	if i.completed != nil {
+		i.completed.With("grpc_service", c.service, "grpc_method", c.method, "code", code.String()).Add(1)
+		i.duration.With("grpc_service", c.service, "grpc_method", c.method, "code", code.String()).Observe(elapsed.Seconds())
+	}
+
+	ce := i.logger.Check(i.codeToLevel(code), msg)
+	if ce == nil {
+		return
+	}
+	fields := append(clogging.ContextFields(c.ctx), c.fields()...)
+	fields = append(fields, zap.String("grpc.code", code.String()), zap.Duration("grpc.duration", elapsed))
+	fields = append(fields, extra...)
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+// message 在流式 RPC 收发一条消息时输出日志记录。
+func (i *interceptor) message(c *call, msg string, size int) {
+	if ce := i.logger.Check(i.messageLevel(c.fullMethod), msg); ce != nil {
+		fields := append(clogging.ContextFields(c.ctx), c.fields()...)
+		ce.Write(append(fields, zap.Int("grpc.message_size", size))...)
+	}
+}
+
+func (c *call) fields() []zapcore.Field {
+	return []zapcore.Field{
+		zap.String("grpc.service", c.service),
+		zap.String("grpc.method", c.method),
+		zap.String("grpc.peer_address", c.peer),
+	}
+}
+
+// streamStats 统计流式 RPC 收发的消息条数和字节数。
+type streamStats struct {
+	sent, received           int
+	sentBytes, receivedBytes int
+}
+
+func (s *streamStats) fields() []zapcore.Field {
+	return []zapcore.Field{
+		zap.Int("grpc.sent_messages", s.sent),
+		zap.Int("grpc.received_messages", s.received),
+		zap.Int("grpc.sent_bytes", s.sentBytes),
+		zap.Int("grpc.received_bytes", s.receivedBytes),
+	}
+}
+
+// splitMethodName 把 "/package.Service/Method" 拆分成服务名和方法名。
+func splitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// messageSize 返回消息编码后的字节数，不是 protobuf 消息时返回 0。
+func messageSize(m interface{}) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
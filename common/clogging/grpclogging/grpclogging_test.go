@@ -0,0 +1,216 @@
+package grpclogging_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/clogging/grpclogging"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Lines() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return strings.Split(strings.TrimSuffix(b.buf.String(), "\n"), "\n")
+}
+
+type fakeMetrics struct {
+	provider  *metricsfakes.Provider
+	counter   *metricsfakes.Counter
+	histogram *metricsfakes.Histogram
+}
+
+func newFakeMetrics() *fakeMetrics {
+	fm := &fakeMetrics{provider: &metricsfakes.Provider{}, counter: &metricsfakes.Counter{}, histogram: &metricsfakes.Histogram{}}
+	fm.counter.SetWithReturns(fm.counter)
+	fm.histogram.SetWithReturns(fm.histogram)
+	fm.provider.SetNewCounterReturns(fm.counter)
+	fm.provider.SetNewHistogramReturns(fm.histogram)
+	return fm
+}
+
+type testEnv struct {
+	health    *health.Server
+	client    healthpb.HealthClient
+	serverLog *syncBuffer
+	clientLog *syncBuffer
+	server    *fakeMetrics
+	clientM   *fakeMetrics
+}
+
+func newTestEnv(t *testing.T, logSpec string) *testEnv {
+	env := &testEnv{health: health.NewServer(), serverLog: &syncBuffer{}, clientLog: &syncBuffer{}, server: newFakeMetrics(), clientM: newFakeMetrics()}
+	newLogger := func(w *syncBuffer, name string) *clogging.ChainerLogger {
+		logging, err := clogging.New(clogging.Config{Format: "%{level} %{message}", LogSpec: logSpec, Writer: w})
+		require.NoError(t, err)
+		return logging.Logger(name)
+	}
+
+	si := grpclogging.NewServerInterceptor(grpclogging.Config{Logger: newLogger(env.serverLog, "rpc.server"), Provider: env.server.provider})
+	server := grpc.NewServer(grpc.UnaryInterceptor(si.Unary()), grpc.StreamInterceptor(si.Stream()))
+	healthpb.RegisterHealthServer(server, env.health)
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	ci := grpclogging.NewClientInterceptor(grpclogging.Config{Logger: newLogger(env.clientLog, "rpc.client"), Provider: env.clientM.provider})
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(ci.Unary()),
+		grpc.WithStreamInterceptor(ci.Stream()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	env.client = healthpb.NewHealthClient(conn)
+	return env
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	env := newTestEnv(t, "info")
+	env.health.SetServingStatus("chainer", healthpb.HealthCheckResponse_SERVING)
+
+	ctx := clogging.WithTraceID(context.Background(), "abc")
+	resp, err := env.client.Check(ctx, &healthpb.HealthCheckRequest{Service: "chainer"})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	_, err = env.client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	serverLines := env.serverLog.Lines()
+	require.Len(t, serverLines, 2)
+	require.Regexp(t, `^INFO unary call completed grpc.service=grpc.health.v1.Health grpc.method=Check grpc.peer_address=bufconn grpc.code=OK grpc.duration=\S+ grpc.request_size=9 grpc.response_size=2$`, serverLines[0])
+	require.Contains(t, serverLines[1], "grpc.code=NotFound")
+	require.Contains(t, serverLines[1], `error="rpc error: code = NotFound desc = unknown service"`)
+
+	clientLines := env.clientLog.Lines()
+	require.Len(t, clientLines, 2)
+	require.Regexp(t, `^INFO unary call completed trace_id=abc grpc.service=grpc.health.v1.Health grpc.method=Check grpc.peer_address=bufnet grpc.code=OK `, clientLines[0])
+
+	require.Equal(t, 2, env.server.counter.AddCallCount())
+	require.Equal(t, []string{"grpc_service", "grpc.health.v1.Health", "grpc_method", "Check", "code", "OK"}, env.server.counter.WithArgsForCall(0))
+	require.Equal(t, []string{"grpc_service", "grpc.health.v1.Health", "grpc_method", "Check", "code", "NotFound"}, env.server.counter.WithArgsForCall(1))
+	require.Equal(t, 2, env.server.histogram.ObserveCallCount())
+	require.Equal(t, 2, env.clientM.counter.AddCallCount())
+	require.Equal(t, grpclogging.ClientRequestsCompletedOpts, env.clientM.provider.NewCounterArgsForCall(0))
+	require.Equal(t, grpclogging.ServerRequestDurationOpts, env.server.provider.NewHistogramArgsForCall(0))
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	env := newTestEnv(t, "debug")
+	env.health.SetServingStatus("chainer", healthpb.HealthCheckResponse_SERVING)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := env.client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "chainer"})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	cancel()
+	_, err = stream.Recv()
+	require.Equal(t, codes.Canceled, status.Code(err))
+
+	clientLines := env.clientLog.Lines()
+	require.Len(t, clientLines, 3)
+	require.Equal(t, "DEBUG sent stream message grpc.service=grpc.health.v1.Health grpc.method=Watch grpc.peer_address=bufnet grpc.message_size=9", clientLines[0])
+	require.Equal(t, "DEBUG received stream message grpc.service=grpc.health.v1.Health grpc.method=Watch grpc.peer_address=bufnet grpc.message_size=2", clientLines[1])
+	require.Contains(t, clientLines[2], "INFO streaming call completed")
+	require.Contains(t, clientLines[2], "grpc.code=Canceled")
+	require.Contains(t, clientLines[2], "grpc.sent_messages=1 grpc.received_messages=1 grpc.sent_bytes=9 grpc.received_bytes=2")
+	require.Equal(t, []string{"grpc_service", "grpc.health.v1.Health", "grpc_method", "Watch", "code", "Canceled"}, env.clientM.counter.WithArgsForCall(0))
+	require.Equal(t, 1, env.clientM.counter.AddCallCount(), "the stream is only finished once")
+
+	require.Eventually(t, func() bool { return env.server.counter.AddCallCount() == 1 }, time.Second, 10*time.Millisecond)
+	serverLines := env.serverLog.Lines()
+	require.Contains(t, serverLines[len(serverLines)-1], "streaming call completed")
+	require.Contains(t, serverLines[len(serverLines)-1], "grpc.sent_messages=1 grpc.received_messages=1")
+}
+
+func TestStreamInterceptorAbandonedStream(t *testing.T) {
+	env := newTestEnv(t, "info")
+	env.health.SetServingStatus("chainer", healthpb.HealthCheckResponse_SERVING)
+
+	// 调用者读到需要的消息之后就取消了流，不再调用 Recv。
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := env.client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "chainer"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	cancel()
+
+	require.Eventually(t, func() bool { return env.clientM.counter.AddCallCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"grpc_service", "grpc.health.v1.Health", "grpc_method", "Watch", "code", "Canceled"}, env.clientM.counter.WithArgsForCall(0))
+	require.Equal(t, 1, env.clientM.histogram.ObserveCallCount())
+	clientLines := env.clientLog.Lines()
+	require.Len(t, clientLines, 1)
+	require.Contains(t, clientLines[0], "INFO streaming call completed")
+	require.Contains(t, clientLines[0], "grpc.code=Canceled")
+	require.Contains(t, clientLines[0], "grpc.sent_messages=1 grpc.received_messages=1")
+
+	// 之后再调用 Recv 不会重复结束 RPC。
+	_, err = stream.Recv()
+	require.Equal(t, codes.Canceled, status.Code(err))
+	require.Equal(t, 1, env.clientM.counter.AddCallCount())
+}
+
+func TestCodeToLevel(t *testing.T) {
+	require.Equal(t, zapcore.InfoLevel, grpclogging.DefaultCodeToLevel(codes.OK))
+	require.Equal(t, zapcore.WarnLevel, grpclogging.DefaultCodeToLevel(codes.Unavailable))
+	require.Equal(t, zapcore.ErrorLevel, grpclogging.DefaultCodeToLevel(codes.Internal))
+
+	buf := &syncBuffer{}
+	logging, err := clogging.New(clogging.Config{Format: "%{level} %{message}", LogSpec: "warn", Writer: buf})
+	require.NoError(t, err)
+	si := grpclogging.NewServerInterceptor(grpclogging.Config{
+		Logger: logging.Logger("rpc"),
+		CodeToLevel: func(code codes.Code) zapcore.Level {
+			if code == codes.OK {
+				return zapcore.DebugLevel
+			}
+			return zapcore.ErrorLevel
+		},
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if req == nil {
+			return nil, status.Error(codes.InvalidArgument, "empty request")
+		}
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/chainer.Admin/Ping"}
+	_, err = si.Unary()(context.Background(), "ping", info, handler)
+	require.NoError(t, err)
+	_, err = si.Unary()(context.Background(), nil, info, handler)
+	require.Error(t, err)
+
+	lines := buf.Lines()
+	require.Len(t, lines, 1)
+	require.Regexp(t, `^ERROR unary call completed grpc.service=chainer.Admin grpc.method=Ping grpc.peer_address= grpc.code=InvalidArgument .* error="rpc error: code = InvalidArgument desc = empty request"$`, lines[0])
+}
+
+var _ metrics.Provider = &metricsfakes.Provider{}
@@ -0,0 +1,78 @@
+package grpclogging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// ServerInterceptor 提供服务端的一元拦截器和流拦截器，两者共用同一组指标。
+type ServerInterceptor struct {
+	interceptor
+}
+
+func NewServerInterceptor(c Config) *ServerInterceptor {
+	return &ServerInterceptor{interceptor: newInterceptor(c, "grpclogging.server", ServerRequestsCompletedOpts, ServerRequestDurationOpts)}
+}
+
+func (si *ServerInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := newCall(ctx, info.FullMethod, peerAddress(ctx))
+		resp, err := handler(ctx, req)
+		si.finish(c, "unary call completed", err,
+			zap.Int("grpc.request_size", messageSize(req)),
+			zap.Int("grpc.response_size", messageSize(resp)),
+		)
+		return resp, err
+	}
+}
+
+func (si *ServerInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c := newCall(ss.Context(), info.FullMethod, peerAddress(ss.Context()))
+		stream := &serverStream{ServerStream: ss, interceptor: &si.interceptor, call: c}
+		err := handler(srv, stream)
+		si.finish(c, "streaming call completed", err, stream.stats.fields()...)
+		return err
+	}
+}
+
+// serverStream 在收发消息时统计消息的条数和大小。grpc 保证同一个方向上的 SendMsg 或者 RecvMsg 不会被并发调用，
+// 但是 SendMsg 和 RecvMsg 可能在不同的 goroutine 里被调用，所以两个方向使用不同的计数器。
+type serverStream struct {
+	grpc.ServerStream
+	interceptor *interceptor
+	call        *call
+	stats       streamStats
+}
+
+func (ss *serverStream) SendMsg(m interface{}) error {
+	err := ss.ServerStream.SendMsg(m)
+	if err == nil {
+		size := messageSize(m)
+		ss.stats.sent++
+		ss.stats.sentBytes += size
+		ss.interceptor.message(ss.call, "sent stream message", size)
+	}
+	return err
+}
+
+func (ss *serverStream) RecvMsg(m interface{}) error {
+	err := ss.ServerStream.RecvMsg(m)
+	if err == nil {
+		size := messageSize(m)
+		ss.stats.received++
+		ss.stats.receivedBytes += size
+		ss.interceptor.message(ss.call, "received stream message", size)
+	}
+	return err
+}
+
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package httpadmin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logging 是 SpecHandler 读取和修改日志规范所依赖的接口，clogging.Logging 实现了该接口。
+type Logging interface {
+	ActivateSpecVersion(spec string, version uint64) (uint64, error)
+	UpdateSpecVersion(set map[string]string, unset []string, version uint64) (string, uint64, error)
+	SpecVersion() (string, uint64)
+	ExplicitLevel(logger string) (zapcore.Level, bool)
+	LevelName(logger string) string
+}
+
+type LogSpec struct {
+	Spec string `json:"spec,omitempty"`
+}
+
+// SpecUpdate 是 PATCH 请求的请求体，Set 里的日志记录器前缀会被合并到日志规范里，Unset 里的会被删除。
+type SpecUpdate struct {
+	Set   map[string]string `json:"set,omitempty"`
+	Unset []string          `json:"unset,omitempty"`
+}
+
+// LoggerLevel 是 GET /logspec?logger=xxx 的响应，Explicit 表示日志规范里是否明确设置了该日志记录器前缀的日志级别。
+type LoggerLevel struct {
+	Logger   string `json:"logger"`
+	Level    string `json:"level"`
+	Explicit bool   `json:"explicit"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewSpecHandler() *SpecHandler {
+	return &SpecHandler{
+		Logging: clogging.Global,
+		Logger:  clogging.MustGetLogger("clogging.httpadmin"),
+	}
+}
+
+// SpecHandler 处理 /logspec 请求：GET 返回当前生效的日志规范，带有 logger 查询参数时返回单个日志记录器的日志级别；
+// PUT 激活请求体里的日志规范；PATCH 只修改请求体里列出的日志记录器。响应头 ETag 是日志规范的版本号，PUT 和 PATCH
+// 请求可以通过 If-Match 请求头带上读取时得到的 ETag，日志规范在此期间被其他人修改过时返回 412。
+type SpecHandler struct {
+	Logging Logging
+	Logger  *clogging.ChainerLogger
+}
+
+func (h *SpecHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPut:
+		version, ok := h.ifMatch(resp, req)
+		if !ok {
+			return
+		}
+		var logSpec LogSpec
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&logSpec); err != nil {
+			h.sendResponse(resp, http.StatusBadRequest, err)
+			return
+		}
+		req.Body.Close()
+
+		version, err := h.Logging.ActivateSpecVersion(logSpec.Spec, version)
+		if err != nil {
+			h.sendError(resp, err)
+			return
+		}
+		h.Logger.Infof("log spec changed to %s by %s", logSpec.Spec, req.RemoteAddr)
+		setETag(resp, version)
+		resp.WriteHeader(http.StatusNoContent)
+
+	case http.MethodPatch:
+		version, ok := h.ifMatch(resp, req)
+		if !ok {
+			return
+		}
+		var update SpecUpdate
+		decoder := json.NewDecoder(req.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&update); err != nil {
+			h.sendResponse(resp, http.StatusBadRequest, err)
+			return
+		}
+		req.Body.Close()
+
+		spec, version, err := h.Logging.UpdateSpecVersion(update.Set, update.Unset, version)
+		if err != nil {
+			h.sendError(resp, err)
+			return
+		}
+		h.Logger.Infof("log spec changed to %s by %s", spec, req.RemoteAddr)
+		setETag(resp, version)
+		h.sendResponse(resp, http.StatusOK, &LogSpec{Spec: spec})
+
+	case http.MethodGet:
+		spec, version := h.Logging.SpecVersion()
+		setETag(resp, version)
+		if logger := req.URL.Query().Get("logger"); logger != "" {
+			_, explicit := h.Logging.ExplicitLevel(logger)
+			h.sendResponse(resp, http.StatusOK, &LoggerLevel{
+				Logger:   logger,
+				Level:    h.Logging.LevelName(logger),
+				Explicit: explicit,
+			})
+			return
+		}
+		h.sendResponse(resp, http.StatusOK, &LogSpec{Spec: spec})
+
+	default:
+		err := fmt.Errorf("invalid request method: %s", req.Method)
+		h.sendResponse(resp, http.StatusBadRequest, err)
+	}
+}
+
+// ifMatch 解析 If-Match 请求头，没有该请求头或者其值为 "*" 时返回 0，表示不检查版本号。
+func (h *SpecHandler) ifMatch(resp http.ResponseWriter, req *http.Request) (uint64, bool) {
+	etag := req.Header.Get("If-Match")
+	if etag == "" || etag == "*" {
+		return 0, true
+	}
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), 10, 64)
+	if err != nil || version == 0 {
+		h.sendResponse(resp, http.StatusBadRequest, fmt.Errorf("invalid If-Match header: %s", etag))
+		return 0, false
+	}
+	return version, true
+}
+
+func (h *SpecHandler) sendError(resp http.ResponseWriter, err error) {
+	var conflict *clogging.VersionConflictError
+	if errors.As(err, &conflict) {
+		setETag(resp, conflict.Current)
+		h.sendResponse(resp, http.StatusPreconditionFailed, err)
+		return
+	}
+	h.sendResponse(resp, http.StatusBadRequest, err)
+}
+
+func setETag(resp http.ResponseWriter, version uint64) {
+	resp.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+func (h *SpecHandler) sendResponse(resp http.ResponseWriter, code int, payload interface{}) {
+	if err, ok := payload.(error); ok {
+		payload = &ErrorResponse{Error: err.Error()}
+	}
+	bz, err := json.Marshal(payload)
+	if err != nil {
+		h.Logger.Errorw("failed to encode payload", "error", err)
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(code)
+	resp.Write(bz)
+}
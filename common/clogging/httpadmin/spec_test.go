@@ -0,0 +1,179 @@
+package httpadmin_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/clogging/httpadmin"
+	"github.com/stretchr/testify/require"
+)
+
+func newSpecHandler(t *testing.T) (*httpadmin.SpecHandler, *clogging.Logging) {
+	logging, err := clogging.New(clogging.Config{LogSpec: "info"})
+	require.NoError(t, err)
+	return &httpadmin.SpecHandler{
+		Logging: logging,
+		Logger:  logging.Logger("test"),
+	}, logging
+}
+
+func TestSpecHandlerGet(t *testing.T) {
+	handler, logging := newSpecHandler(t)
+	require.NoError(t, logging.ActivateSpec("gossip=debug:info"))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/logspec", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"spec":"gossip=debug:info"}`, resp.Body.String())
+}
+
+func TestSpecHandlerPut(t *testing.T) {
+	handler, logging := newSpecHandler(t)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodPut, "/logspec", strings.NewReader(`{"spec":"consensus=warn:debug"}`)))
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	require.Empty(t, resp.Body.String())
+	require.Equal(t, "consensus=warn:debug", logging.Spec())
+}
+
+func TestSpecHandlerErrors(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		method string
+		body   string
+		result string
+	}{
+		{
+			desc:   "bad payload",
+			method: http.MethodPut,
+			body:   `{"spec":`,
+			result: `{"error":"unexpected EOF"}`,
+		},
+		{
+			desc:   "bad spec",
+			method: http.MethodPut,
+			body:   `{"spec":"bad=spec=here"}`,
+			result: `{"error":"invalid logging specification 'bad=spec=here': bad segment 'bad=spec=here'"}`,
+		},
+		{
+			desc:   "bad method",
+			method: http.MethodPost,
+			result: `{"error":"invalid request method: POST"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			handler, logging := newSpecHandler(t)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest(tt.method, "/logspec", strings.NewReader(tt.body)))
+			require.Equal(t, http.StatusBadRequest, resp.Code)
+			require.JSONEq(t, tt.result, resp.Body.String())
+			require.Equal(t, "info", logging.Spec())
+		})
+	}
+}
+
+func TestSpecHandlerETag(t *testing.T) {
+	handler, logging := newSpecHandler(t)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/logspec", nil))
+	etag := resp.Header().Get("ETag")
+	require.Equal(t, `"1"`, etag)
+
+	req := httptest.NewRequest(http.MethodPut, "/logspec", strings.NewReader(`{"spec":"debug"}`))
+	req.Header.Set("If-Match", etag)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	require.Equal(t, `"2"`, resp.Header().Get("ETag"))
+
+	// 使用过期的 ETag 修改日志规范会失败。
+	req = httptest.NewRequest(http.MethodPut, "/logspec", strings.NewReader(`{"spec":"warn"}`))
+	req.Header.Set("If-Match", etag)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	require.Equal(t, `"2"`, resp.Header().Get("ETag"))
+	require.JSONEq(t, `{"error":"log spec version conflict: expected version 1, current version is 2"}`, resp.Body.String())
+	require.Equal(t, "debug", logging.Spec())
+}
+
+func TestSpecHandlerPatch(t *testing.T) {
+	handler, logging := newSpecHandler(t)
+	require.NoError(t, logging.ActivateSpec("gossip=warn:consensus=error:info"))
+
+	req := httptest.NewRequest(http.MethodPatch, "/logspec", strings.NewReader(`{"set":{"gossip.pull":"debug"},"unset":["consensus"]}`))
+	req.Header.Set("If-Match", `"2"`)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, `"3"`, resp.Header().Get("ETag"))
+	require.JSONEq(t, `{"spec":"gossip.pull=debug:gossip=warn:info"}`, resp.Body.String())
+
+	req = httptest.NewRequest(http.MethodPatch, "/logspec", strings.NewReader(`{"set":{"ledger":"debug"}}`))
+	req.Header.Set("If-Match", `"2"`)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/logspec?logger=gossip.pull.state", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.JSONEq(t, `{"logger":"gossip.pull.state","level":"debug","explicit":false}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/logspec?logger=gossip", nil))
+	require.JSONEq(t, `{"logger":"gossip","level":"warn","explicit":true}`, resp.Body.String())
+}
+
+func TestSpecHandlerPayloadLevel(t *testing.T) {
+	handler, logging := newSpecHandler(t)
+	require.NoError(t, logging.ActivateSpec("gossip=payload:info"))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/logspec?logger=gossip.pull", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.JSONEq(t, `{"logger":"gossip.pull","level":"payload","explicit":false}`, resp.Body.String())
+
+	// 读到的日志级别可以原样写回。
+	req := httptest.NewRequest(http.MethodPatch, "/logspec", strings.NewReader(`{"set":{"ledger":"payload"}}`))
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.JSONEq(t, `{"spec":"gossip=payload:ledger=payload:info"}`, resp.Body.String())
+}
+
+func TestSpecHandlerPatchErrors(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		ifMatch string
+		body    string
+		result  string
+	}{
+		{desc: "bad if-match", ifMatch: "abc", body: `{}`, result: `{"error":"invalid If-Match header: abc"}`},
+		{desc: "unknown field", body: `{"add":{}}`, result: `{"error":"json: unknown field \"add\""}`},
+		{desc: "bad level", body: `{"set":{"gossip":"loud"}}`, result: `{"error":"invalid log level 'loud' for logger 'gossip'"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			handler, logging := newSpecHandler(t)
+			req := httptest.NewRequest(http.MethodPatch, "/logspec", strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusBadRequest, resp.Code)
+			require.JSONEq(t, tt.result, resp.Body.String())
+			require.Equal(t, "info", logging.Spec())
+		})
+	}
+}
@@ -28,6 +28,14 @@ func NameToLevel(level string) zapcore.Level {
 	return l
 }
 
+// levelName 返回可以被 nameToLevel 解析的日志级别名，zapcore.Level 的 String 方法不认识 PayloadLevel。
+func levelName(level zapcore.Level) string {
+	if level == PayloadLevel {
+		return "payload"
+	}
+	return level.String()
+}
+
 func nameToLevel(level string) (zapcore.Level, error) {
 	switch level {
 	case "PAYLOAD", "payload":
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 )
@@ -16,9 +17,48 @@ var loggerNameRegexp = regexp.MustCompile(`^[[:alnum:]_#:-]+(\.[[:alnum:]_#:-]+)
 type LoggerLevels struct {
 	mutex sync.RWMutex
 	levelCache map[string]zapcore.Level // 一个动态的缓冲区，将程序运行过程中遇到的日志记录器和对应的日志记录存储在这个缓冲区里。
+	// specs、defaultLevel 和 minLevel 是叠加了所有临时覆盖之后实际生效的日志级别。
 	specs map[string]zapcore.Level
 	defaultLevel zapcore.Level
 	minLevel zapcore.Level
+	// globs 是 specs 里带有通配符的选择器和正则表达式选择器，只有在它们不为空时 calculateLevel 才需要逐个匹配。
+	globs []globSelector
+
+	// baseSpecs 和 baseDefault 是通过 ActivateSpec 设置的日志级别，临时覆盖过期之后会恢复成它们。
+	baseSpecs   map[string]zapcore.Level
+	baseDefault zapcore.Level
+	baseSet     bool
+	overrides   []*override
+	overrideSeq uint64
+	// version 在日志规范每次被修改时加一，用于检测并发的修改。
+	version uint64
+}
+
+// VersionConflictError 表示修改日志规范时给定的版本号与当前的版本号不一致，也就是日志规范已经被其他人修改过了。
+type VersionConflictError struct {
+	Expected uint64
+	Current  uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("log spec version conflict: expected version %d, current version is %d", e.Expected, e.Current)
+}
+
+// Override 描述一个通过 ActivateSpecFor 设置的、尚未过期的临时覆盖。
+type Override struct {
+	Spec      string
+	ExpiresAt time.Time
+	Remaining time.Duration
+}
+
+type override struct {
+	id           uint64
+	spec         string
+	specs        map[string]zapcore.Level
+	defaultLevel zapcore.Level
+	hasDefault   bool
+	expiresAt    time.Time
+	timer        *time.Timer
 }
 
 // DefaultLevel 为没有明确设置日志级别的记录器返回默认日志级别。
@@ -33,53 +73,270 @@ func (l *LoggerLevels) DefaultLevel() zapcore.Level {
 // 以将 `xx.yy` 看成是 `xx` 的子日志记录器的名称。`level2` 没有与之对应的日志记录器，那么 `level2` 会被看作是默认的日志级别，会赋
 // 值给 LoggerLevel 的 defaultLevel 字段。`xx.yy.zz=level1` 与 `aa.bb=level3` 会被 LoggerLevel 的 specs 字段存储，其中 `xx.yy.zz`
 // 与 `aa.bb` 会被当成 map 的 key，`level1` 和 `level3` 则会被当成对应的 value。`level1 level2 level3` 里级别最小的会被赋值给
-// LoggerLevel 的 minLevel 字段。
+// LoggerLevel 的 minLevel 字段。日志记录器名里可以使用整段的通配符 "*" 和 "**"，
+// 也可以使用以 "/" 包围的正则表达式，多个选择器同时匹配时最具体的一个生效。
 func (l *LoggerLevels) ActivateSpec(spec string) error {
+	_, err := l.ActivateSpecVersion(spec, 0)
+	return err
+}
+
+// ActivateSpecVersion 与 ActivateSpec 相同，但是只有在当前的版本号等于 version 时才会修改日志规范，version 为 0 时
+// 不检查版本号。返回修改之后的版本号。
+func (l *LoggerLevels) ActivateSpecVersion(spec string, version uint64) (uint64, error) {
+	specs, defaultLevel, _, err := parseSpec(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := l.checkVersion(version); err != nil {
+		return 0, err
+	}
+	l.baseSpecs = specs
+	l.baseDefault = defaultLevel
+	l.baseSet = true
+	l.version++
+	l.recalculate()
+	return l.version, nil
+}
+
+// SetLevel 只修改 logger 这一个日志记录器前缀的日志级别，其他的日志记录器保持不变。logger 的写法与日志规范里的相同。
+func (l *LoggerLevels) SetLevel(logger, level string, version uint64) (uint64, error) {
+	return l.UpdateSpec(map[string]string{logger: level}, nil, version)
+}
+
+// UnsetLevel 删除 logger 这一个日志记录器前缀的日志级别，之后它使用上一级前缀或者默认的日志级别。
+func (l *LoggerLevels) UnsetLevel(logger string, version uint64) (uint64, error) {
+	return l.UpdateSpec(nil, []string{logger}, version)
+}
+
+// UpdateSpec 把 set 合并到日志规范里，并删除 unset 里的日志记录器前缀，所有的修改要么全部生效，要么全部不生效。
+// version 不为 0 时，只有在当前的版本号等于 version 时才会修改日志规范。返回修改之后的版本号。
+func (l *LoggerLevels) UpdateSpec(set map[string]string, unset []string, version uint64) (uint64, error) {
+	_, version, err := l.UpdateSpecVersion(set, unset, version)
+	return version, err
+}
+
+// UpdateSpecVersion 与 UpdateSpec 相同，同时返回修改之后的日志规范，它与返回的版本号是在同一个临界区里读取的，
+// 不会受到并发修改的影响。
+func (l *LoggerLevels) UpdateSpecVersion(set map[string]string, unset []string, version uint64) (string, uint64, error) {
+	levels := map[string]zapcore.Level{}
+	for logger, level := range set {
+		if !isValidLoggerSelector(strings.TrimSuffix(logger, ".")) {
+			return "", 0, fmt.Errorf("invalid logger name: '%s'", logger)
+		}
+		if !IsValidLevel(level) {
+			return "", 0, fmt.Errorf("invalid log level '%s' for logger '%s'", level, logger)
+		}
+		levels[logger] = NameToLevel(level)
+	}
+	for _, logger := range unset {
+		if !isValidLoggerSelector(strings.TrimSuffix(logger, ".")) {
+			return "", 0, fmt.Errorf("invalid logger name: '%s'", logger)
+		}
+		if _, ok := levels[logger]; ok {
+			return "", 0, fmt.Errorf("logger '%s' cannot be both set and unset", logger)
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := l.checkVersion(version); err != nil {
+		return "", 0, err
+	}
+	if !l.baseSet {
+		l.recalculate()
+	}
+	specs := map[string]zapcore.Level{}
+	for logger, lvl := range l.baseSpecs {
+		specs[logger] = lvl
+	}
+	for logger, lvl := range levels {
+		specs[logger] = lvl
+	}
+	for _, logger := range unset {
+		delete(specs, logger)
+	}
+	l.baseSpecs = specs
+	l.version++
+	l.recalculate()
+	return l.spec(), l.version, nil
+}
+
+// ExplicitLevel 返回通过日志规范为 logger 这一个日志记录器前缀明确设置的日志级别，不考虑上一级前缀和临时覆盖。
+func (l *LoggerLevels) ExplicitLevel(logger string) (zapcore.Level, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	lvl, ok := l.baseSpecs[logger]
+	return lvl, ok
+}
+
+// Version 返回日志规范当前的版本号。
+func (l *LoggerLevels) Version() uint64 {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.version
+}
+
+// SpecVersion 同时返回日志规范和它的版本号，两者总是对应的。
+func (l *LoggerLevels) SpecVersion() (string, uint64) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.spec(), l.version
+}
+
+// checkVersion 的调用者需要持有 l.mutex。
+func (l *LoggerLevels) checkVersion(version uint64) error {
+	if version != 0 && version != l.version {
+		return &VersionConflictError{Expected: version, Current: l.version}
+	}
+	return nil
+}
+
+// ActivateSpecFor 把 spec 作为一个临时覆盖叠加在当前的日志规范之上，ttl 之后自动撤销。spec 里没有出现的日志记录器
+// 保持原有的日志级别，只有 spec 里含有不带日志记录器名的日志级别时才会覆盖默认的日志级别。后设置的覆盖优先，
+// 覆盖过期之后恢复成其余覆盖叠加在日志规范之上的日志级别，期间通过 ActivateSpec 修改的日志规范同样会被保留。
+func (l *LoggerLevels) ActivateSpecFor(spec string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid override duration: %s", ttl)
+	}
+	specs, defaultLevel, hasDefault, err := parseSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.overrideSeq++
+	o := &override{
+		id:           l.overrideSeq,
+		spec:         spec,
+		specs:        specs,
+		defaultLevel: defaultLevel,
+		hasDefault:   hasDefault,
+		expiresAt:    time.Now().Add(ttl),
+	}
+	o.timer = time.AfterFunc(ttl, func() { l.expire(o.id) })
+	l.overrides = append(l.overrides, o)
+	l.recalculate()
+	return nil
+}
+
+// Overrides 按照设置的先后顺序返回所有尚未过期的临时覆盖。
+func (l *LoggerLevels) Overrides() []Override {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	now := time.Now()
+	var overrides []Override
+	for _, o := range l.overrides {
+		remaining := o.expiresAt.Sub(now)
+		if remaining < 0 {
+			remaining = 0
+		}
+		overrides = append(overrides, Override{Spec: o.spec, ExpiresAt: o.expiresAt, Remaining: remaining})
+	}
+	return overrides
+}
+
+// ClearOverrides 立即撤销所有的临时覆盖。
+func (l *LoggerLevels) ClearOverrides() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, o := range l.overrides {
+		o.timer.Stop()
+	}
+	l.overrides = nil
+	l.recalculate()
+}
+
+func (l *LoggerLevels) expire(id uint64) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
+	for i, o := range l.overrides {
+		if o.id == id {
+			l.overrides = append(l.overrides[:i:i], l.overrides[i+1:]...)
+			l.recalculate()
+			return
+		}
+	}
+}
 
-	defaultLevel := zapcore.InfoLevel
+// recalculate 把临时覆盖按照设置的先后顺序叠加在日志规范之上，调用者需要持有 l.mutex。
+func (l *LoggerLevels) recalculate() {
+	if !l.baseSet {
+		// 没有调用过 ActivateSpec 时，以构造 LoggerLevels 时给定的默认日志级别作为日志规范。
+		l.baseDefault = l.defaultLevel
+		l.baseSet = true
+	}
+	defaultLevel := l.baseDefault
 	specs := map[string]zapcore.Level{}
+	for logger, lvl := range l.baseSpecs {
+		specs[logger] = lvl
+	}
+	for _, o := range l.overrides {
+		if o.hasDefault {
+			defaultLevel = o.defaultLevel
+		}
+		for logger, lvl := range o.specs {
+			specs[logger] = lvl
+		}
+	}
+
+	minLevel := defaultLevel
+	for _, lvl := range specs {
+		if lvl < minLevel {
+			minLevel = lvl
+		}
+	}
+
+	var globs []globSelector
+	for selector, lvl := range specs {
+		if isGlobSelector(selector) {
+			globs = append(globs, newGlobSelector(selector, lvl))
+		}
+	}
+
+	l.minLevel = minLevel
+	l.defaultLevel = defaultLevel
+	l.specs = specs
+	l.globs = globs
+	l.levelCache = map[string]zapcore.Level{}
+}
+
+// parseSpec 解析日志规范，hasDefault 表示 spec 里是否含有不带日志记录器名的日志级别。
+func parseSpec(spec string) (specs map[string]zapcore.Level, defaultLevel zapcore.Level, hasDefault bool, err error) {
+	defaultLevel = zapcore.InfoLevel
+	specs = map[string]zapcore.Level{}
 	for _, field := range strings.Split(spec, ":") {
 		split := strings.Split(field, "=")
 		switch len(split) {
 		case 1:
 			if field != "" && !IsValidLevel(field) {
-				return fmt.Errorf("invalid logging specification '%s': bad segment '%s'", spec, field)
+				return nil, 0, false, fmt.Errorf("invalid logging specification '%s': bad segment '%s'", spec, field)
 			}
 			defaultLevel = NameToLevel(field)
+			hasDefault = true
 		case 2:
 			if split[0] == "" {
-				return fmt.Errorf("invalid logging specification '%s': no logger specified in segment '%s'", spec, field)
+				return nil, 0, false, fmt.Errorf("invalid logging specification '%s': no logger specified in segment '%s'", spec, field)
 			}
 			if field != "" && !IsValidLevel(split[1]) {
-				return fmt.Errorf("invalid logging specification '%s': bad segment '%s'", spec, field)
+				return nil, 0, false, fmt.Errorf("invalid logging specification '%s': bad segment '%s'", spec, field)
 			}
 			level := NameToLevel(split[1])
 			loggers := strings.Split(split[0], ",")
 			for _, logger := range loggers {
-				if !isValidLoggerName(strings.TrimSuffix(logger, ".")) {
-					return fmt.Errorf("invalid logging specification '%s': bad logger name '%s'", spec, logger)
+				if !isValidLoggerSelector(strings.TrimSuffix(logger, ".")) {
+					return nil, 0, false, fmt.Errorf("invalid logging specification '%s': bad logger name '%s'", spec, logger)
 				}
 				specs[logger] = level
 			}
 		default:
-			return fmt.Errorf("invalid logging specification '%s': bad segment '%s'", spec, field)
-		}
-	}
-
-	minLevel := defaultLevel
-	for _, lvl := range specs {
-		if lvl < minLevel {
-			minLevel = lvl
+			return nil, 0, false, fmt.Errorf("invalid logging specification '%s': bad segment '%s'", spec, field)
 		}
 	}
-
-	l.minLevel = minLevel
-	l.defaultLevel = defaultLevel
-	l.specs = specs
-	l.levelCache = map[string]zapcore.Level{}
-	return nil
+	return specs, defaultLevel, hasDefault, nil
 }
 
 func (l *LoggerLevels) Level(loggerName string) zapcore.Level {
@@ -95,16 +352,31 @@ func (l *LoggerLevels) Level(loggerName string) zapcore.Level {
 	return level
 }
 
+// LevelName 返回 loggerName 当前的日志级别名，它可以直接用在日志规范里，zapcore.Level 的 String 方法不认识 PayloadLevel。
+func (l *LoggerLevels) LevelName(loggerName string) string {
+	return levelName(l.Level(loggerName))
+}
+
+// Spec 返回通过 ActivateSpec 设置的日志规范，不包含临时覆盖，临时覆盖可以通过 Overrides 获取。
 func (l *LoggerLevels) Spec() string {
 	l.mutex.RLock()
 	defer l.mutex.RUnlock()
+	return l.spec()
+}
+
+// spec 返回规范化的日志规范字符串，调用者需要持有 l.mutex。
+func (l *LoggerLevels) spec() string {
+	specs, defaultLevel := l.baseSpecs, l.baseDefault
+	if !l.baseSet {
+		specs, defaultLevel = l.specs, l.defaultLevel
+	}
 	var fields []string
-	for k, v := range l.specs {
-		fields = append(fields, fmt.Sprintf("%s=%s", k, v))
+	for k, v := range specs {
+		fields = append(fields, fmt.Sprintf("%s=%s", k, levelName(v)))
 	}
 
 	sort.Strings(fields)
-	fields = append(fields, l.defaultLevel.String())
+	fields = append(fields, levelName(defaultLevel))
 	return strings.Join(fields, ":") // 从这里可以看出，spec 的形式是这样的 "logger.A,logger.B=info:logger.C=debug"
 }
 
@@ -122,19 +394,39 @@ func (l *LoggerLevels) cachedLevel(loggerName string) (zapcore.Level, bool) {
 	return level, ok
 }
 
+// calculateLevel 在所有匹配 loggerName 的选择器里选出最具体的一个，规则见 selectorMatch.moreSpecificThan。
+// 不带通配符的选择器里，最长的前缀总是最具体的，所以只需要从长到短依次查找。
 func (l *LoggerLevels) calculateLevel(loggerName string) zapcore.Level {
+	level := l.defaultLevel
+	best := selectorMatch{depth: -1}
+
 	candidate := loggerName + "."
 	for {
 		if lvl, ok := l.specs[candidate]; ok {
-			return lvl
+			depth := strings.Count(strings.TrimSuffix(candidate, "."), ".") + 1
+			level = lvl
+			best = selectorMatch{selector: candidate, depth: depth, literals: depth, exact: strings.HasSuffix(candidate, ".")}
+			break
 		}
 
 		idx := strings.LastIndex(candidate, ".")
 		if idx <= 0 {
-			return l.defaultLevel
+			break
 		}
 		candidate = candidate[:idx]
 	}
+
+	if len(l.globs) == 0 {
+		return level
+	}
+	name := strings.Split(loggerName, ".")
+	for _, g := range l.globs {
+		if m, ok := g.match(name); ok && m.moreSpecificThan(best) {
+			level = g.level
+			best = m
+		}
+	}
+	return level
 }
 
 func isValidLoggerName(loggerName string) bool {
@@ -3,6 +3,7 @@ package clogging
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
@@ -159,4 +160,200 @@ func TestEnables(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestActivateSpecFor(t *testing.T) {
+	ll := &LoggerLevels{}
+	require.NoError(t, ll.ActivateSpec("gossip=warn:consensus=error:info"))
+
+	require.NoError(t, ll.ActivateSpecFor("gossip=debug", time.Hour))
+	require.NoError(t, ll.ActivateSpecFor("gossip.pull=payload:warn", 50*time.Millisecond))
+	require.Equal(t, zapcore.DebugLevel, ll.Level("gossip.push"))
+	require.Equal(t, PayloadLevel, ll.Level("gossip.pull"))
+	require.Equal(t, zapcore.ErrorLevel, ll.Level("consensus"))
+	require.Equal(t, zapcore.WarnLevel, ll.Level("ledger"))
+	require.True(t, ll.Enabled(PayloadLevel))
+	require.Equal(t, "consensus=error:gossip=warn:info", ll.Spec(), "Spec does not include overrides")
+
+	overrides := ll.Overrides()
+	require.Len(t, overrides, 2)
+	require.Equal(t, "gossip=debug", overrides[0].Spec)
+	require.InDelta(t, time.Hour, overrides[0].Remaining, float64(time.Minute))
+	require.Equal(t, "gossip.pull=payload:warn", overrides[1].Spec)
+
+	// 覆盖期间修改的日志规范在覆盖过期之后依然有效。
+	require.NoError(t, ll.ActivateSpec("gossip=error:debug"))
+	require.Equal(t, zapcore.WarnLevel, ll.Level("ledger"))
+
+	require.Eventually(t, func() bool { return len(ll.Overrides()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, zapcore.DebugLevel, ll.Level("gossip.pull"))
+	require.Equal(t, zapcore.DebugLevel, ll.Level("ledger"))
+	require.False(t, ll.Enabled(PayloadLevel))
+
+	ll.ClearOverrides()
+	require.Empty(t, ll.Overrides())
+	require.Equal(t, zapcore.ErrorLevel, ll.Level("gossip.pull"))
+}
+
+func TestActivateSpecForErrors(t *testing.T) {
+	ll := &LoggerLevels{}
+	require.EqualError(t, ll.ActivateSpecFor("debug", 0), "invalid override duration: 0s")
+	require.EqualError(t, ll.ActivateSpecFor("a=b=c", time.Minute), "invalid logging specification 'a=b=c': bad segment 'a=b=c'")
+	require.Empty(t, ll.Overrides())
+}
+
+func TestUpdateSpec(t *testing.T) {
+	ll := &LoggerLevels{}
+	version, err := ll.ActivateSpecVersion("gossip=warn:consensus=error:info", 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, version)
+
+	version, err = ll.SetLevel("gossip.pull", "debug", version)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, version)
+	require.Equal(t, "consensus=error:gossip.pull=debug:gossip=warn:info", ll.Spec())
+	require.Equal(t, zapcore.DebugLevel, ll.Level("gossip.pull.state"))
+
+	lvl, ok := ll.ExplicitLevel("gossip.pull")
+	require.True(t, ok)
+	require.Equal(t, zapcore.DebugLevel, lvl)
+	_, ok = ll.ExplicitLevel("gossip.push")
+	require.False(t, ok)
+
+	version, err = ll.UnsetLevel("gossip", 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, version)
+	require.Equal(t, zapcore.InfoLevel, ll.Level("gossip.push"))
+
+	version, err = ll.UpdateSpec(map[string]string{"ledger": "warn", "consensus": "debug"}, []string{"gossip.pull"}, version)
+	require.NoError(t, err)
+	spec, current := ll.SpecVersion()
+	require.Equal(t, "consensus=debug:ledger=warn:info", spec)
+	require.Equal(t, version, current)
+	require.Equal(t, version, ll.Version())
+
+	spec, current, err = ll.UpdateSpecVersion(nil, []string{"ledger"}, version)
+	require.NoError(t, err)
+	require.Equal(t, "consensus=debug:info", spec)
+	require.Equal(t, version+1, current)
+	_, _, err = ll.UpdateSpecVersion(map[string]string{"ledger": "warn"}, nil, version)
+	require.EqualError(t, err, "log spec version conflict: expected version 4, current version is 5")
+}
+
+func TestUpdateSpecErrors(t *testing.T) {
+	ll := &LoggerLevels{}
+	require.NoError(t, ll.ActivateSpec("gossip=warn:info"))
+
+	_, err := ll.SetLevel("gossip", "debug", 7)
+	require.EqualError(t, err, "log spec version conflict: expected version 7, current version is 1")
+	var conflict *VersionConflictError
+	require.True(t, errors.As(err, &conflict))
+	require.EqualValues(t, 1, conflict.Current)
+
+	_, err = ll.ActivateSpecVersion("debug", 2)
+	require.EqualError(t, err, "log spec version conflict: expected version 2, current version is 1")
+
+	_, err = ll.SetLevel("bad name", "debug", 0)
+	require.EqualError(t, err, "invalid logger name: 'bad name'")
+	_, err = ll.SetLevel("gossip", "loud", 0)
+	require.EqualError(t, err, "invalid log level 'loud' for logger 'gossip'")
+	_, err = ll.UpdateSpec(map[string]string{"gossip": "debug"}, []string{"gossip"}, 0)
+	require.EqualError(t, err, "logger 'gossip' cannot be both set and unset")
+
+	require.Equal(t, "gossip=warn:info", ll.Spec())
+	require.EqualValues(t, 1, ll.Version())
+}
+
+func TestLoggerLevelsGlobSelectors(t *testing.T) {
+	ll := &LoggerLevels{}
+	spec := "*.grpc=warn:peer.*.deliver=debug:peer=error:peer.chan1.deliver.=fatal:**.raft=payload:ledger.**=dpanic:info"
+	require.NoError(t, ll.ActivateSpec(spec))
+
+	var tests = []struct {
+		logger   string
+		expected zapcore.Level
+	}{
+		{logger: "comm.grpc", expected: zapcore.WarnLevel},
+		{logger: "comm.grpc.server", expected: zapcore.WarnLevel},
+		{logger: "grpc", expected: zapcore.InfoLevel},
+		// "*" 只匹配一段。
+		{logger: "a.b.grpc", expected: zapcore.InfoLevel},
+		{logger: "peer.chan2.deliver", expected: zapcore.DebugLevel},
+		{logger: "peer.chan2.deliver.blocks", expected: zapcore.DebugLevel},
+		{logger: "peer.chan2.gossip", expected: zapcore.ErrorLevel},
+		// 以点号结尾的选择器比同样深度的通配符更具体，但不匹配子日志记录器。
+		{logger: "peer.chan1.deliver", expected: zapcore.FatalLevel},
+		{logger: "peer.chan1.deliver.blocks", expected: zapcore.DebugLevel},
+		// "**" 匹配任意多段，包括零段。
+		{logger: "raft", expected: PayloadLevel},
+		{logger: "orderer.consensus.raft.storage", expected: PayloadLevel},
+		// 位置更深的 "*.grpc" 比 "peer" 更具体。
+		{logger: "peer.grpc", expected: zapcore.WarnLevel},
+		// 末尾的 "**" 相当于前缀匹配。
+		{logger: "ledger", expected: zapcore.DPanicLevel},
+		{logger: "ledger.kv", expected: zapcore.DPanicLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.logger, func(t *testing.T) {
+			require.Equal(t, tt.expected, ll.Level(tt.logger))
+			require.Equal(t, tt.expected, ll.Level(tt.logger), "cached level")
+		})
+	}
+
+	require.Equal(t, "**.raft=payload:*.grpc=warn:ledger.**=dpanic:peer.*.deliver=debug:peer.chan1.deliver.=fatal:peer=error:info", ll.Spec())
+	other := &LoggerLevels{}
+	require.NoError(t, other.ActivateSpec(ll.Spec()))
+	require.Equal(t, ll.Spec(), other.Spec())
+
+	// 修改日志规范之后缓存里的日志级别会被重新计算。
+	_, err := ll.SetLevel("comm.grpc", "debug", 0)
+	require.NoError(t, err)
+	require.Equal(t, zapcore.DebugLevel, ll.Level("comm.grpc"), "literals beat wildcards at the same depth")
+	require.Equal(t, zapcore.DebugLevel, ll.Level("comm.grpc.server"))
+
+	// 深度和通配符数量都相同时按照选择器的字典序决定。
+	_, err = ll.SetLevel("orderer.*", "error", 0)
+	require.NoError(t, err)
+	require.Equal(t, zapcore.WarnLevel, ll.Level("orderer.grpc"))
+}
+
+func TestLoggerLevelsRegexSelectors(t *testing.T) {
+	ll := &LoggerLevels{}
+	spec := `/orderer\.(chan1|chan2)\.raft/=debug:orderer=error:/peer\..*\.deliver/=warn:peer.*.deliver=fatal:peer.chan1.deliver.=payload:/.*\.grpc(\..*)?/=dpanic:info`
+	require.NoError(t, ll.ActivateSpec(spec))
+
+	var tests = []struct {
+		logger   string
+		expected zapcore.Level
+	}{
+		// 正则表达式匹配完整的日志记录器名，比匹配位置更浅的选择器更具体。
+		{logger: "orderer.chan2.raft", expected: zapcore.DebugLevel},
+		{logger: "orderer.chan3.raft", expected: zapcore.ErrorLevel},
+		// 正则表达式不匹配子日志记录器。
+		{logger: "orderer.chan2.raft.storage", expected: zapcore.ErrorLevel},
+		// 匹配位置相同时，通配符和日志记录器名都比正则表达式更具体。
+		{logger: "peer.chan2.deliver", expected: zapcore.FatalLevel},
+		{logger: "peer.chan1.deliver", expected: PayloadLevel},
+		{logger: "peer.grpc", expected: zapcore.DPanicLevel},
+		{logger: "comm.grpc.server", expected: zapcore.DPanicLevel},
+		{logger: "grpc", expected: zapcore.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.logger, func(t *testing.T) {
+			require.Equal(t, tt.expected, ll.Level(tt.logger))
+		})
+	}
+
+	expected := `/.*\.grpc(\..*)?/=dpanic:/orderer\.(chan1|chan2)\.raft/=debug:/peer\..*\.deliver/=warn:orderer=error:peer.*.deliver=fatal:peer.chan1.deliver.=payload:info`
+	require.Equal(t, expected, ll.Spec())
+	other := &LoggerLevels{}
+	require.NoError(t, other.ActivateSpec(ll.Spec()))
+	require.Equal(t, ll.Spec(), other.Spec())
+	require.Equal(t, zapcore.DebugLevel, other.Level("orderer.chan1.raft"), "regex selectors survive a round trip")
+}
+
+func TestLoggerSelectorErrors(t *testing.T) {
+	for _, spec := range []string{"peer*=debug", "a.***=info", "*..b=info", "/peer(/=debug", "//=debug", "/[[:alpha:]]/=debug"} {
+		require.Error(t, (&LoggerLevels{}).ActivateSpec(spec), spec)
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/232425wxy/chainer/common/clogging/cenc"
+	cmetrics "github.com/232425wxy/chainer/common/clogging/metrics"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
 	zaplogfmt "github.com/sykesm/zap-logfmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -17,10 +22,39 @@ const (
 	defaultFormat = "%{color}%{time:2006-01-02 15:04:05.000 MST} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}"
 )
 
+// ColorMode 决定控制台格式里的 %{color} 是否生效。
+type ColorMode int8
+
+const (
+	// ColorAlways 总是输出颜色控制字符，这是默认的行为。
+	ColorAlways ColorMode = iota
+	// ColorNever 从不输出颜色控制字符。
+	ColorNever
+	// ColorAuto 只在写入器是终端时输出颜色控制字符。
+	ColorAuto
+)
+
 type Config struct {
 	Format  string
 	LogSpec string
 	Writer  io.Writer
+	// Output 在没有指定 Writer 和 Rotate 时决定日志的去向："stderr"、"stdout" 或者一个文件路径，为空时使用 stderr。
+	Output string
+	Color  ColorMode
+	// TimeZone 不为空时，日志记录里的时间会被转换到该时区之后再编码。
+	TimeZone *time.Location
+	// Rotate 不为空并且没有指定 Writer 时，日志会被写入一个按照 Rotate 配置进行切割的文件。
+	Rotate *RotateConfig
+	// Async 不为空时，日志会先写入一个有界的缓冲区，再由后台异步地写入 Writer。
+	Async *AsyncConfig
+	// Sinks 是除 Writer 之外的其他日志输出端。
+	Sinks []SinkConfig
+	// Sampling 不为空时，日志记录在写入之前会先经过采样和限流。
+	Sampling *SamplingConfig
+	// FieldRules 的键是与日志规范相同写法的选择器，匹配的日志记录器输出的日志记录都会自动带上对应的字段。
+	FieldRules map[string][]zapcore.Field
+	// GRPC 决定 GRPCLogger 如何输出 grpc 内部的日志。
+	GRPC GRPCConfig
 }
 
 type Logging struct {
@@ -31,6 +65,18 @@ type Logging struct {
 	multiFormatter *cenc.MultiFormatter
 	writer         zapcore.WriteSyncer
 	observer       Observer
+	timeZone       *time.Location
+	// owned 是 Logging 根据 Config 创建的写入器，它们在被替换后由 Logging 负责关闭，排在前面的先关闭。
+	owned   []io.Closer
+	sinks   []*Sink
+	sampler *sampler
+	fieldRules *fieldRules
+	grpc       *grpcSettings
+
+	// droppedCounters 缓存每个指标提供者创建的丢弃计数器，反复 Apply 或者多个输出端使用同一个提供者时不会重复创建，
+	// 否则 prometheus 等提供者会因为重复注册而 panic。
+	countersMutex   sync.Mutex
+	droppedCounters map[metrics.Provider]metrics.Counter
 }
 
 func New(c Config) (*Logging, error) {
@@ -49,8 +95,9 @@ func New(c Config) (*Logging, error) {
 	return l, nil
 }
 
+// Apply 先校验 c 并创建所需的写入器，全部成功之后才替换当前的配置，所以返回错误时原有的配置保持不变。
 func (l *Logging) Apply(c Config) error {
-	err := l.SetFormat(c.Format)
+	encoding, formatters, err := parseFormat(c.Format)
 	if err != nil {
 		return err
 	}
@@ -61,58 +108,117 @@ func (l *Logging) Apply(c Config) error {
 	if c.LogSpec == "" {
 		c.LogSpec = defaultLevel.String()
 	}
-	if err = l.LoggerLevels.ActivateSpec(c.LogSpec); err != nil {
+	if err = (&LoggerLevels{}).ActivateSpec(c.LogSpec); err != nil {
 		return err
 	}
 
-	if c.Writer == nil {
-		c.Writer = os.Stderr
+	rules, err := newFieldRules(c.FieldRules)
+	if err != nil {
+		return err
+	}
+	grpc, err := newGRPCSettings(c.GRPC)
+	if err != nil {
+		return err
+	}
+	sinks, err := l.newSinks(c.Sinks)
+	if err != nil {
+		return err
+	}
+	w, owned, err := openWriter(c.Writer, c.Output, c.Rotate, c.Async, l.droppedCounter(c.Async))
+	if err != nil {
+		closeSinks(sinks)
+		return err
+	}
+	sampler, err := l.newSampler(c.Sampling)
+	if err != nil {
+		for _, closer := range owned {
+			closer.Close()
+		}
+		closeSinks(sinks)
+		return err
 	}
-	l.SetWriter(c.Writer)
-	return nil
-
-}
 
-func (l *Logging) SetFormat(format string) error {
+	// 格式、日志规范、写入器和输出端在同一个临界区里被替换，正在进行的写入要么全部使用旧的配置，要么全部使用新的配置。
 	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	if format == "" {
-		format = defaultFormat
+	l.setFormat(encoding, colorFormatters(formatters, c.Color, w))
+	l.LoggerLevels.ActivateSpec(c.LogSpec)
+	l.writer = writeSyncer(w)
+	oldOwned, oldSinks, oldSampler := l.owned, l.sinks, l.sampler
+	l.owned, l.sinks, l.sampler = owned, sinks, sampler
+	l.timeZone = c.TimeZone
+	l.fieldRules = rules
+	l.grpc = grpc
+	l.mutex.Unlock()
+	for _, closer := range oldOwned {
+		closer.Close()
 	}
+	closeSinks(oldSinks)
+	// 被替换的采样器最后输出的汇总信息会写入新的输出。
+	if oldSampler != nil {
+		oldSampler.Close()
+	}
+	return nil
 
-	if format == "json" {
-		l.encoding = JSON
+}
+
+// droppedCounter 返回异步写入器使用的丢弃计数器，同一个提供者只创建一次。
+func (l *Logging) droppedCounter(async *AsyncConfig) metrics.Counter {
+	if async == nil {
 		return nil
 	}
+	if async.Provider == nil {
+		// disabled.Provider 创建的计数器不记录任何东西，不需要缓存。
+		return (&disabled.Provider{}).NewCounter(cmetrics.DroppedCountOpts)
+	}
+	// 不可比较的提供者不能作为 map 的键，只能每次创建新的计数器。
+	if !reflect.TypeOf(async.Provider).Comparable() {
+		return async.Provider.NewCounter(cmetrics.DroppedCountOpts)
+	}
 
-	if format == "logfmt" {
-		l.encoding = LOGFMT
-		return nil
+	l.countersMutex.Lock()
+	defer l.countersMutex.Unlock()
+	counter, ok := l.droppedCounters[async.Provider]
+	if !ok {
+		counter = async.Provider.NewCounter(cmetrics.DroppedCountOpts)
+		if l.droppedCounters == nil {
+			l.droppedCounters = map[metrics.Provider]metrics.Counter{}
+		}
+		l.droppedCounters[async.Provider] = counter
 	}
+	return counter
+}
 
-	formatters, err := cenc.ParseFormat(format) // 可能是默认的格式："%{color}%{time:2006-01-02 15:04:05.000 MST} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}"。
+func (l *Logging) SetFormat(format string) error {
+	// format 为空时使用默认的格式："%{color}%{time:2006-01-02 15:04:05.000 MST} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}"。
+	encoding, formatters, err := parseFormat(format)
 	if err != nil {
 		return err
 	}
-	l.multiFormatter.SetFormatters(formatters)
-	l.encoding = CONSOLE
-
+	l.mutex.Lock()
+	l.setFormat(encoding, formatters)
+	l.mutex.Unlock()
 	return nil
 }
 
+// setFormat 的调用者需要持有 l.mutex。
+func (l *Logging) setFormat(encoding Encoding, formatters []cenc.Formatter) {
+	if encoding == CONSOLE {
+		l.multiFormatter.SetFormatters(formatters)
+	}
+	l.encoding = encoding
+}
+
+// TimeZone 返回日志记录里的时间需要转换到的时区，为 nil 时不做转换。
+func (l *Logging) TimeZone() *time.Location {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.timeZone
+}
+
 // SetWriter控制格式化的日志记录被写入哪个写入器。
 // 除了*os.File之外，写程序需要安全地被多个go例程同时使用。
 func (l *Logging) SetWriter(w io.Writer) io.Writer {
-	var ws zapcore.WriteSyncer
-
-	switch t := w.(type) {
-	case *os.File:
-		ws = zapcore.Lock(t) // 将 os.File 包裹在一个 mutex 里，以使其能支持并发操作。
-	case zapcore.WriteSyncer:
-		ws = t
-	default:
-		ws = zapcore.AddSync(w) // 将 io.Writer 转化为 zapcore.WriteSyncer。
-	}
+	ws := writeSyncer(w)
 
 	l.mutex.Lock()
 	old := l.writer
@@ -121,6 +227,17 @@ func (l *Logging) SetWriter(w io.Writer) io.Writer {
 	return old
 }
 
+func writeSyncer(w io.Writer) zapcore.WriteSyncer {
+	switch t := w.(type) {
+	case *os.File:
+		return zapcore.Lock(t) // 将 os.File 包裹在一个 mutex 里，以使其能支持并发操作。
+	case zapcore.WriteSyncer:
+		return t
+	default:
+		return zapcore.AddSync(w) // 将 io.Writer 转化为 zapcore.WriteSyncer。
+	}
+}
+
 // SetObserver 用于提供一个日志观察者，当日志级别被检查或写入时，它将被调用。
 func (l *Logging) SetObserver(observer Observer) Observer {
 	l.mutex.Lock()
@@ -130,18 +247,17 @@ func (l *Logging) SetObserver(observer Observer) Observer {
 	return old
 }
 
+// Write 在写入期间持有读锁，这样 SetWriter 返回之后，旧的写入器上就不会再有正在进行的写入，可以被安全地关闭。
 func (l *Logging) Write(bz []byte) (int, error) {
 	l.mutex.RLock()
-	w := l.writer
-	l.mutex.RUnlock()
-	return w.Write(bz)
+	defer l.mutex.RUnlock()
+	return l.writer.Write(bz)
 }
 
 func (l *Logging) Sync() error {
 	l.mutex.RLock()
-	w := l.writer
-	l.mutex.RUnlock()
-	return w.Sync()
+	defer l.mutex.RUnlock()
+	return l.writer.Sync()
 }
 
 func (l *Logging) Encoding() Encoding {
@@ -158,7 +274,7 @@ func (l *Logging) ZapLogger(name string) *zap.Logger {
 
 	l.mutex.RLock()
 	core := &Core{
-		LevelEnabler: l.LoggerLevels,
+		LevelEnabler: l,
 		Levels:       l.LoggerLevels,
 		Encoders:     map[Encoding]zapcore.Encoder{
 			JSON: zapcore.NewJSONEncoder(l.encoderConfig),
@@ -168,6 +284,10 @@ func (l *Logging) ZapLogger(name string) *zap.Logger {
 		Selector:     l,
 		Output:       l,
 		Observer:     l,
+		Sinks:        l,
+		TimeZone:     l,
+		Limiter:      l,
+		Rules:        l,
 	}
 	l.mutex.RUnlock()
 
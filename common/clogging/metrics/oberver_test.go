@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	commonmetrics "github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
 	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
@@ -13,6 +14,8 @@ func TestNewObserver(t *testing.T) {
 	provider := &metricsfakes.Provider{}
 	checkedCounter := &metricsfakes.Counter{}
 	writtenCounter := &metricsfakes.Counter{}
+	sampledCounter := &metricsfakes.Counter{}
+	rateLimitedCounter := &metricsfakes.Counter{}
 
 	provider.NewCounterStub = func(opts commonmetrics.CounterOpts) commonmetrics.Counter {
 		switch opts.Name {
@@ -22,19 +25,38 @@ func TestNewObserver(t *testing.T) {
 		case "entries_written":
 			require.Equal(t, WriteCountOpts, opts)
 			return writtenCounter
+		case "entries_sampled":
+			require.Equal(t, SampledCountOpts, opts)
+			return sampledCounter
+		case "entries_rate_limited":
+			require.Equal(t, RateLimitedCountOpts, opts)
+			return rateLimitedCounter
 		default:
 			return nil
 		}
 	}
 
 	expectedObserver := &Observer{
-		CheckedCounter: checkedCounter,
-		WrittenCounter: writtenCounter,
+		CheckedCounter:     checkedCounter,
+		WrittenCounter:     writtenCounter,
+		SampledCounter:     sampledCounter,
+		RateLimitedCounter: rateLimitedCounter,
 	}
 
 	m := NewObserver(provider)
 	require.Equal(t, expectedObserver, m)
-	require.Equal(t, 2, provider.NewCounterCallCount())
+	require.Equal(t, 4, provider.NewCounterCallCount())
+}
+
+func TestNewObserverDisabled(t *testing.T) {
+	m := NewObserver(nil)
+	require.Equal(t, disabled.Counter{}, m.CheckedCounter)
+	require.NotPanics(t, func() {
+		m.Check(zapcore.Entry{Level: zapcore.InfoLevel}, nil)
+		m.WriteEntry(zapcore.Entry{Level: zapcore.InfoLevel}, nil)
+		m.Sampled(zapcore.Entry{Level: zapcore.InfoLevel})
+		m.RateLimited(zapcore.Entry{Level: zapcore.InfoLevel})
+	})
 }
 
 func TestCheck(t *testing.T) {
@@ -66,4 +88,20 @@ func TestWrite(t *testing.T) {
 
 	require.Equal(t, 1, counter.WithCallCount())
 	require.Equal(t, []string{"level", "debug"}, counter.WithArgsForCall(0))
-}
\ No newline at end of file
+}
+
+func TestSampledAndRateLimited(t *testing.T) {
+	sampled := &metricsfakes.Counter{}
+	sampled.SetWithReturns(sampled)
+	rateLimited := &metricsfakes.Counter{}
+	rateLimited.SetWithReturns(rateLimited)
+
+	m := Observer{SampledCounter: sampled, RateLimitedCounter: rateLimited}
+	m.Sampled(zapcore.Entry{Level: zapcore.WarnLevel})
+	m.RateLimited(zapcore.Entry{Level: zapcore.InfoLevel})
+
+	require.Equal(t, 1, sampled.AddCallCount())
+	require.Equal(t, []string{"level", "warn"}, sampled.WithArgsForCall(0))
+	require.Equal(t, 1, rateLimited.AddCallCount())
+	require.Equal(t, []string{"level", "info"}, rateLimited.WithArgsForCall(0))
+}
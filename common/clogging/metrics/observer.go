@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -23,17 +24,68 @@ var (
 		LabelHelp:    map[string]string{},
 		StatsdFormat: "%{#fqname}.%{level}",
 	}
+
+	DroppedCountOpts = metrics.CounterOpts{
+		Namespace:    "logging",
+		Name:         "entries_dropped",
+		Help:         "Number of log entries dropped because the asynchronous output buffer was full",
+		LabelNames:   []string{"policy"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{policy}",
+	}
+
+	SampledCountOpts = metrics.CounterOpts{
+		Namespace:    "logging",
+		Name:         "entries_sampled",
+		Help:         "Number of log entries discarded by sampling",
+		LabelNames:   []string{"level"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{level}",
+	}
+
+	RateLimitedCountOpts = metrics.CounterOpts{
+		Namespace:    "logging",
+		Name:         "entries_rate_limited",
+		Help:         "Number of log entries dropped by the per-logger rate limiter",
+		LabelNames:   []string{"level"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{level}",
+	}
+
+	ReloadCountOpts = metrics.CounterOpts{
+		Namespace:    "logging",
+		Name:         "config_reloads",
+		Help:         "Number of attempts to reload the logging configuration file",
+		LabelNames:   []string{"result"},
+		LabelHelp:    map[string]string{},
+		StatsdFormat: "%{#fqname}.%{result}",
+	}
+
+	LastReloadGaugeOpts = metrics.GaugeOpts{
+		Namespace:    "logging",
+		Name:         "config_last_reload_timestamp_seconds",
+		Help:         "Unix time of the last successful reload of the logging configuration file",
+		StatsdFormat: "%{#fqname}",
+	}
 )
 
 type Observer struct {
-	CheckedCounter metrics.Counter
-	WrittenCounter metrics.Counter
+	CheckedCounter     metrics.Counter
+	WrittenCounter     metrics.Counter
+	SampledCounter     metrics.Counter
+	RateLimitedCounter metrics.Counter
 }
 
+// NewObserver 用 provider 创建计数器，provider 为 nil 时使用 disabled.Provider，不记录任何指标。
 func NewObserver(provider metrics.Provider) *Observer {
+	if provider == nil {
+		provider = &disabled.Provider{}
+	}
 	return &Observer{
-		CheckedCounter: provider.NewCounter(CheckedCountOpts),
-		WrittenCounter: provider.NewCounter(WriteCountOpts),
+		CheckedCounter:     provider.NewCounter(CheckedCountOpts),
+		WrittenCounter:     provider.NewCounter(WriteCountOpts),
+		SampledCounter:     provider.NewCounter(SampledCountOpts),
+		RateLimitedCounter: provider.NewCounter(RateLimitedCountOpts),
 	}
 }
 
@@ -44,4 +96,14 @@ func (o *Observer) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) {
 
 func (o *Observer) WriteEntry(entry zapcore.Entry, fields []zapcore.Field) {
 	o.WrittenCounter.With("level", entry.Level.String()).Add(1)
-}
\ No newline at end of file
+}
+
+// Sampled 统计因为采样而被丢弃的日志记录。
+func (o *Observer) Sampled(entry zapcore.Entry) {
+	o.SampledCounter.With("level", entry.Level.String()).Add(1)
+}
+
+// RateLimited 统计因为限流而被丢弃的日志记录。
+func (o *Observer) RateLimited(entry zapcore.Entry) {
+	o.RateLimitedCounter.With("level", entry.Level.String()).Add(1)
+}
@@ -0,0 +1,435 @@
+package clogging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+	megabyte         = 1024 * 1024
+	defaultMaxSize   = 100
+)
+
+// RotateConfig 配置一个按大小切割的日志文件。
+type RotateConfig struct {
+	// Filename 是当前正在写入的日志文件，切割出来的旧文件与它位于同一目录下，命名形式为 "name-<时间戳>.ext"。
+	Filename string
+	// MaxSize 是单个日志文件的最大大小，单位是 MB，默认为 100。
+	MaxSize int
+	// MaxAge 是旧日志文件的最长保留时间，根据文件名里的时间戳判断，为 0 时不按时间清理。
+	MaxAge time.Duration
+	// MaxBackups 是最多保留的旧日志文件个数，为 0 时不按个数清理。
+	MaxBackups int
+	// Compress 为 true 时，切割出来的旧日志文件会被 gzip 压缩。
+	Compress bool
+	// LocalTime 为 true 时，旧日志文件名里的时间戳使用本地时间，否则使用 UTC 时间。
+	LocalTime bool
+	// ErrorHandler 处理在后台压缩和清理旧日志文件时遇到的错误，这些错误没有调用者可以返回。为 nil 时错误会被写到标准
+	// 错误输出，与 zap 处理内部错误的方式相同。
+	ErrorHandler func(error)
+}
+
+// RotatingWriter 是一个 zapcore.WriteSyncer，写入的内容超过 MaxSize 时会把当前文件重命名为带时间戳的旧文件，
+// 然后重新创建一个日志文件继续写入。旧文件的压缩和清理在后台进行，不会阻塞写入。RotatingWriter 可以被多个
+// go 例程同时使用。
+type RotatingWriter struct {
+	mutex  sync.Mutex
+	config RotateConfig
+	file   *os.File
+	size   int64
+	closed bool
+
+	now       func() time.Time
+	millCh    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	millWG    sync.WaitGroup
+}
+
+func NewRotatingWriter(c RotateConfig) (*RotatingWriter, error) {
+	if c.Filename == "" {
+		return nil, errors.New("rotating writer requires a filename")
+	}
+	if c.MaxSize < 0 || c.MaxBackups < 0 || c.MaxAge < 0 {
+		return nil, fmt.Errorf("invalid rotation config for %s: limits must not be negative", c.Filename)
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = defaultMaxSize
+	}
+
+	w := &RotatingWriter{
+		config: c,
+		now:    time.Now,
+		millCh: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if err := w.openExistingOrNew(); err != nil {
+		return nil, err
+	}
+
+	w.millWG.Add(1)
+	go w.millRun()
+	w.triggerMill()
+
+	return w, nil
+}
+
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("write %s: rotating writer is closed", w.config.Filename)
+	}
+	// 上一次切割或者重新打开失败时，日志文件处于关闭状态，每次写入都会重新尝试打开它。
+	if w.file == nil {
+		if err := w.openExistingOrNew(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize() {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingWriter) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed || w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Rotate 立即切割当前的日志文件。
+func (w *RotatingWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return fmt.Errorf("rotate %s: rotating writer is closed", w.config.Filename)
+	}
+	return w.rotate()
+}
+
+// Reopen 关闭当前的文件句柄，并按照 Filename 重新打开日志文件。外部的 logrotate 工具移走日志文件后，
+// 需要调用 Reopen 让后续的日志写入新的文件。重新打开失败时返回错误，之后的 Write 会再次尝试打开日志文件。
+func (w *RotatingWriter) Reopen() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return fmt.Errorf("reopen %s: rotating writer is closed", w.config.Filename)
+	}
+	closeErr := w.closeFile()
+	if err := w.openExistingOrNew(); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// ReopenOnSignal 在进程收到给定的信号时调用 Reopen，没有给定信号时默认监听 SIGHUP，返回的函数用于停止监听。
+// 它适用于不受 Logging 管理的 RotatingWriter；由 Config.Rotate 创建的写入器在 Watcher 收到 SIGHUP 重新加载时
+// 就会被重新创建，见 WatchConfig.Signals。
+func (w *RotatingWriter) ReopenOnSignal(errorf func(template string, args ...interface{}), signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+	stopCh := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				if err := w.Reopen(); err != nil && errorf != nil {
+					errorf("failed to reopen log file %s: %s", w.config.Filename, err)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigCh)
+			close(stopCh)
+		})
+	}
+}
+
+// Close 关闭日志文件并等待后台的压缩和清理工作结束。
+func (w *RotatingWriter) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		w.mutex.Lock()
+		w.closed = true
+		err = w.closeFile()
+		w.mutex.Unlock()
+
+		close(w.done)
+		w.millWG.Wait()
+	})
+	return err
+}
+
+func (w *RotatingWriter) maxSize() int64 {
+	return int64(w.config.MaxSize) * megabyte
+}
+
+func (w *RotatingWriter) openExistingOrNew() error {
+	if err := os.MkdirAll(filepath.Dir(w.config.Filename), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate 调用者必须持有 w.mutex。关闭或者重命名失败时仍然会重新打开日志文件，重新打开失败时 w.file 为 nil，
+// 之后的 Write 会再次尝试打开它。
+func (w *RotatingWriter) rotate() error {
+	closeErr := w.closeFile()
+	renameErr := os.Rename(w.config.Filename, w.freeBackupName(w.now()))
+	if renameErr != nil && os.IsNotExist(renameErr) {
+		renameErr = nil
+	}
+	if err := w.openExistingOrNew(); err != nil {
+		return err
+	}
+	w.triggerMill()
+	if closeErr != nil {
+		return closeErr
+	}
+	return renameErr
+}
+
+// closeFile 关闭当前的日志文件，调用者必须持有 w.mutex。
+func (w *RotatingWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotatingWriter) backupName(t time.Time, seq int) string {
+	if !w.config.LocalTime {
+		t = t.UTC()
+	}
+	prefix, ext := w.prefixAndExt()
+	stamp := t.Format(backupTimeFormat)
+	if seq > 0 {
+		stamp += "." + strconv.Itoa(seq)
+	}
+	return filepath.Join(filepath.Dir(w.config.Filename), prefix+stamp+ext)
+}
+
+// freeBackupName 返回 t 对应的、还没有被占用的旧日志文件名。同一毫秒内发生多次切割时，后面的文件名会带上递增的序号，
+// 例如 node-2023-05-01T10-00-00.000.1.log，以免覆盖之前的旧日志文件。
+func (w *RotatingWriter) freeBackupName(t time.Time) string {
+	for seq := 0; ; seq++ {
+		name := w.backupName(t, seq)
+		if !exists(name) && !exists(name+compressSuffix) {
+			return name
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (w *RotatingWriter) prefixAndExt() (string, string) {
+	base := filepath.Base(w.config.Filename)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func (w *RotatingWriter) triggerMill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *RotatingWriter) millRun() {
+	defer w.millWG.Done()
+	for {
+		select {
+		case <-w.millCh:
+			if err := w.mill(); err != nil {
+				w.handleError(err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *RotatingWriter) handleError(err error) {
+	if w.config.ErrorHandler != nil {
+		w.config.ErrorHandler(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%v rotating writer %s error: %v\n", time.Now(), w.config.Filename, err)
+}
+
+type backupFile struct {
+	path      string
+	timestamp time.Time
+	seq       int
+}
+
+// mill 按照 MaxBackups 和 MaxAge 删除多余的旧日志文件，并压缩剩余的未压缩的旧日志文件。
+func (w *RotatingWriter) mill() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	now := w.now()
+	w.mutex.Unlock()
+
+	var remaining []backupFile
+	var errs []string
+	cutoff := now.Add(-w.config.MaxAge)
+	for i, b := range backups {
+		expired := w.config.MaxAge > 0 && b.timestamp.Before(cutoff)
+		excess := w.config.MaxBackups > 0 && i >= w.config.MaxBackups
+		if expired || excess {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
+		remaining = append(remaining, b)
+	}
+
+	if w.config.Compress {
+		for _, b := range remaining {
+			if strings.HasSuffix(b.path, compressSuffix) {
+				continue
+			}
+			if err := compressFile(b.path, b.path+compressSuffix); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// backups 返回所有的旧日志文件，最新的排在最前面。
+func (w *RotatingWriter) backups() ([]backupFile, error) {
+	entries, err := os.ReadDir(filepath.Dir(w.config.Filename))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, ext := w.prefixAndExt()
+	var backups []backupFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), compressSuffix)
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ext)
+		loc := time.UTC
+		if w.config.LocalTime {
+			loc = time.Local
+		}
+		seq := 0
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, loc)
+		if err != nil {
+			// 同一毫秒内切割出的旧日志文件在时间戳之后带有序号。
+			i := strings.LastIndex(stamp, ".")
+			if i < 0 {
+				continue
+			}
+			if seq, err = strconv.Atoi(stamp[i+1:]); err != nil || seq <= 0 {
+				continue
+			}
+			if t, err = time.ParseInLocation(backupTimeFormat, stamp[:i], loc); err != nil {
+				continue
+			}
+		}
+		backups = append(backups, backupFile{path: filepath.Join(filepath.Dir(w.config.Filename), name), timestamp: t, seq: seq})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].timestamp.Equal(backups[j].timestamp) {
+			return backups[i].timestamp.After(backups[j].timestamp)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
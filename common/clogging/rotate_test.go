@@ -0,0 +1,321 @@
+package clogging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRotatingWriter(t *testing.T, c RotateConfig, start time.Time) (*RotatingWriter, func(time.Duration)) {
+	w, err := NewRotatingWriter(c)
+	require.NoError(t, err)
+	t.Cleanup(func() { w.Close() })
+
+	var mutex sync.Mutex
+	now := start
+	w.mutex.Lock()
+	w.now = func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return now
+	}
+	w.mutex.Unlock()
+	advance := func(d time.Duration) {
+		mutex.Lock()
+		now = now.Add(d)
+		mutex.Unlock()
+	}
+	return w, advance
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNewRotatingWriterErrors(t *testing.T) {
+	_, err := NewRotatingWriter(RotateConfig{})
+	require.EqualError(t, err, "rotating writer requires a filename")
+
+	_, err = NewRotatingWriter(RotateConfig{Filename: "x.log", MaxBackups: -1})
+	require.EqualError(t, err, "invalid rotation config for x.log: limits must not be negative")
+}
+
+func TestRotatingWriterRotatesOnSize(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	w, advance := newTestRotatingWriter(t, RotateConfig{Filename: filename, MaxSize: 1}, start)
+
+	chunk := bytes.Repeat([]byte("a"), 600*1024)
+	_, err := w.Write(chunk)
+	require.NoError(t, err)
+	require.Equal(t, []string{"node.log"}, dirEntries(t, dir))
+
+	advance(time.Second)
+	_, err = w.Write(chunk)
+	require.NoError(t, err)
+	require.Equal(t, []string{"node-2023-05-01T10-00-01.000.log", "node.log"}, dirEntries(t, dir))
+
+	bz, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Len(t, bz, len(chunk))
+}
+
+func TestRotatingWriterRotatesWithinSameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	w, _ := newTestRotatingWriter(t, RotateConfig{Filename: filename, MaxSize: 1, MaxBackups: 2}, start)
+
+	for _, b := range []byte("abcd") {
+		_, err := w.Write(bytes.Repeat([]byte{b}, 600*1024))
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		return len(dirEntries(t, dir)) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"node-2023-05-01T10-00-00.000.1.log", "node-2023-05-01T10-00-00.000.2.log", "node.log"}, dirEntries(t, dir))
+
+	for name, content := range map[string]byte{"node-2023-05-01T10-00-00.000.1.log": 'b', "node-2023-05-01T10-00-00.000.2.log": 'c', "node.log": 'd'} {
+		bz, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, bz[0], name)
+	}
+}
+
+func TestRotatingWriterAppendsToExistingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nested", "node.log")
+	w, err := NewRotatingWriter(RotateConfig{Filename: filename})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = NewRotatingWriter(RotateConfig{Filename: filename})
+	require.NoError(t, err)
+	defer w.Close()
+	require.EqualValues(t, 6, w.size)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Sync())
+
+	bz, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "first\nsecond\n", string(bz))
+}
+
+func TestRotatingWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	w, advance := newTestRotatingWriter(t, RotateConfig{Filename: filename, MaxBackups: 2, MaxAge: time.Hour}, start)
+
+	for i := 0; i < 4; i++ {
+		_, err := w.Write([]byte("entry\n"))
+		require.NoError(t, err)
+		advance(time.Minute)
+		require.NoError(t, w.Rotate())
+	}
+	require.Eventually(t, func() bool {
+		return len(dirEntries(t, dir)) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{
+		"node-2023-05-01T10-03-00.000.log",
+		"node-2023-05-01T10-04-00.000.log",
+		"node.log",
+	}, dirEntries(t, dir))
+
+	advance(2 * time.Hour)
+	w.triggerMill()
+	require.Eventually(t, func() bool {
+		return len(dirEntries(t, dir)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRotatingWriterCompress(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.Local)
+	w, _ := newTestRotatingWriter(t, RotateConfig{Filename: filename, Compress: true, LocalTime: true}, start)
+
+	_, err := w.Write([]byte("compress me\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+
+	compressed := filepath.Join(dir, "node-2023-05-01T10-00-00.000.log.gz")
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(compressed)
+		return err == nil && len(dirEntries(t, dir)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	f, err := os.Open(compressed)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	bz, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, "compress me\n", string(bz))
+}
+
+func TestRotatingWriterConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	w, err := NewRotatingWriter(RotateConfig{Filename: filename, MaxSize: 1})
+	require.NoError(t, err)
+
+	line := strings.Repeat("x", 1023) + "\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 256; j++ {
+				_, err := w.Write([]byte(line))
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, w.Close())
+
+	var total int
+	for _, name := range dirEntries(t, dir) {
+		bz, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Zero(t, len(bz)%len(line), "partial line in %s", name)
+		total += len(bz)
+	}
+	require.Equal(t, 8*256*len(line), total)
+}
+
+func TestRotatingWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	w, err := NewRotatingWriter(RotateConfig{Filename: filename})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(filename, filename+".1"))
+
+	var errs []string
+	stop := w.ReopenOnSignal(func(template string, args ...interface{}) {
+		errs = append(errs, template)
+	}, syscall.SIGUSR1)
+	defer stop()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filename)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	bz, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "after\n", string(bz))
+	bz, err = os.ReadFile(filename + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "before\n", string(bz))
+	require.Empty(t, errs)
+}
+
+func TestRotatingWriterReopenFailure(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	w, err := NewRotatingWriter(RotateConfig{Filename: filename})
+	require.NoError(t, err)
+	defer w.Close()
+
+	// 日志文件所在的位置被一个目录占用，重新打开会失败。
+	require.NoError(t, os.Remove(filename))
+	require.NoError(t, os.Mkdir(filename, 0o755))
+	require.Error(t, w.Reopen())
+	_, err = w.Write([]byte("lost\n"))
+	require.Error(t, err)
+	require.NoError(t, w.Sync())
+
+	// 障碍消失之后，写入会重新打开日志文件。
+	require.NoError(t, os.Remove(filename))
+	_, err = w.Write([]byte("recovered\n"))
+	require.NoError(t, err)
+	bz, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "recovered\n", string(bz))
+}
+
+func TestRotatingWriterReportsMillErrors(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	// 压缩的目标位置被一个目录占用，压缩会失败。
+	backup := filepath.Join(dir, "node-2023-05-01T10-00-00.000.log")
+	require.NoError(t, os.WriteFile(backup, []byte("old\n"), 0o644))
+	require.NoError(t, os.Mkdir(backup+compressSuffix, 0o755))
+
+	errs := make(chan error, 1)
+	w, err := NewRotatingWriter(RotateConfig{Filename: filename, Compress: true, ErrorHandler: func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}})
+	require.NoError(t, err)
+	defer w.Close()
+
+	select {
+	case err := <-errs:
+		require.Contains(t, err.Error(), backup+compressSuffix)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mill error was not reported")
+	}
+}
+
+func TestRotatingWriterClosed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "node.log")
+	w, err := NewRotatingWriter(RotateConfig{Filename: filename})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte("late"))
+	require.EqualError(t, err, "write "+filename+": rotating writer is closed")
+	require.NoError(t, w.Sync())
+}
+
+func TestLoggingRotateConfig(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "node.log")
+	l, err := New(Config{Format: "%{message}", Rotate: &RotateConfig{Filename: filename}})
+	require.NoError(t, err)
+	require.Len(t, l.owned, 1)
+
+	l.Logger("test").Info("to the file")
+	bz, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "to the file\n", string(bz))
+
+	rotating := l.owned[0].(*RotatingWriter)
+	require.NoError(t, l.Apply(Config{}))
+	require.Empty(t, l.owned)
+	_, err = rotating.Write([]byte("x"))
+	require.Error(t, err, "the replaced rotating writer should be closed")
+}
@@ -0,0 +1,364 @@
+package clogging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultSamplingTick    = time.Second
+	defaultSummaryInterval = time.Minute
+
+	// samplerLoggerName 是输出汇总信息的日志记录器，它输出的日志记录不会被采样或者限流。
+	samplerLoggerName = "clogging.sampler"
+
+	// maxCachedLoggers 是 bucketOf 最多缓存的日志记录器名的个数，超过时清空缓存，以免动态生成的日志记录器名耗尽内存。
+	maxCachedLoggers = 4096
+)
+
+// RateLimit 是一个令牌桶：每秒补充 Rate 个令牌，最多积累 Burst 个令牌，每条日志记录消耗一个令牌。
+type RateLimit struct {
+	Rate float64
+	// Burst 为 0 时等于 Rate 向上取整，并且至少为 1。
+	Burst int
+}
+
+type SamplingConfig struct {
+	// Tick 是采样窗口的长度，默认为 1 秒。
+	Tick time.Duration
+	// 在每个采样窗口里，同一个日志记录器以同一个级别在同一个调用位置（源文件和行号）输出的日志记录，前 First 条
+	// 全部保留，之后每 Thereafter 条保留一条，Thereafter 为 0 时之后的日志记录全部丢弃。First 和 Thereafter 都为 0
+	// 时不进行采样。采样不看消息的内容：同一行代码输出的不同消息共用一个配额，经由同一个辅助函数输出的日志记录的调用
+	// 位置都是这个辅助函数里的那一行，也共用一个配额；没有记录调用位置时，同一个日志记录器以同一个级别输出的所有
+	// 日志记录共用一个配额。
+	First      int
+	Thereafter int
+	// RateLimits 为日志记录器的子树配置限流，键的含义与日志规范里的日志记录器名相同："gossip" 对 gossip 以及
+	// gossip.xxx 生效，"gossip." 只对 gossip 生效。同一棵子树里的所有日志记录器共享一个令牌桶。
+	RateLimits map[string]RateLimit
+	// SummaryInterval 是输出被丢弃的日志记录条数汇总的时间间隔，默认为 1 分钟，为负数时不输出汇总。
+	SummaryInterval time.Duration
+}
+
+// EntryLimiter 决定一条已经启用的日志记录是否因为采样或者限流而被丢弃。
+type EntryLimiter interface {
+	Allow(entry zapcore.Entry) bool
+}
+
+// DropObserver 是 Observer 可选实现的接口，用于观察被采样或者限流丢弃的日志记录。
+type DropObserver interface {
+	Sampled(entry zapcore.Entry)
+	RateLimited(entry zapcore.Entry)
+}
+
+type sampleKey struct {
+	logger string
+	level  zapcore.Level
+	caller string
+}
+
+type sampleCounter struct {
+	resetAt time.Time
+	count   uint64
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type suppressedKey struct {
+	logger string
+	level  zapcore.Level
+}
+
+type suppressedCount struct {
+	sampled     uint64
+	rateLimited uint64
+}
+
+// sampler 实现了采样和限流，并定期汇总被丢弃的日志记录条数。
+type sampler struct {
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+	limits     map[string]RateLimit
+
+	mutex    sync.Mutex
+	now      func() time.Time
+	counters map[sampleKey]*sampleCounter
+	// nextSweep 是下一次清理过期的采样计数器的时间。
+	nextSweep  time.Time
+	buckets    map[string]*tokenBucket
+	bucketOf   map[string]string
+	suppressed map[suppressedKey]*suppressedCount
+
+	report func(logger string, level zapcore.Level, sampled, rateLimited uint64)
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newSampler(c SamplingConfig, report func(logger string, level zapcore.Level, sampled, rateLimited uint64)) (*sampler, error) {
+	if c.Tick < 0 || c.First < 0 || c.Thereafter < 0 {
+		return nil, fmt.Errorf("invalid sampling config: tick, first and thereafter must not be negative")
+	}
+	if c.Tick == 0 {
+		c.Tick = defaultSamplingTick
+	}
+	if c.SummaryInterval == 0 {
+		c.SummaryInterval = defaultSummaryInterval
+	}
+
+	limits := map[string]RateLimit{}
+	for name, limit := range c.RateLimits {
+		if !isValidLoggerName(strings.TrimSuffix(name, ".")) {
+			return nil, fmt.Errorf("invalid rate limit: bad logger name '%s'", name)
+		}
+		if limit.Rate <= 0 || limit.Burst < 0 {
+			return nil, fmt.Errorf("invalid rate limit for logger '%s': rate must be positive and burst must not be negative", name)
+		}
+		if limit.Burst == 0 {
+			limit.Burst = int(limit.Rate)
+			if float64(limit.Burst) < limit.Rate || limit.Burst == 0 {
+				limit.Burst++
+			}
+		}
+		limits[name] = limit
+	}
+
+	s := &sampler{
+		tick:       c.Tick,
+		first:      uint64(c.First),
+		thereafter: uint64(c.Thereafter),
+		limits:     limits,
+		now:        time.Now,
+		counters:   map[sampleKey]*sampleCounter{},
+		buckets:    map[string]*tokenBucket{},
+		bucketOf:   map[string]string{},
+		suppressed: map[suppressedKey]*suppressedCount{},
+		report:     report,
+		done:       make(chan struct{}),
+	}
+	if c.SummaryInterval > 0 {
+		s.wg.Add(1)
+		go s.run(c.SummaryInterval)
+	}
+	return s, nil
+}
+
+// sample 返回 false 时 entry 应该因为采样而被丢弃。
+func (s *sampler) sample(e zapcore.Entry) bool {
+	if s.first == 0 && s.thereafter == 0 {
+		return true
+	}
+	// 以调用位置而不是消息为键：使用 Infof 之类的方法输出的消息各不相同，以消息为键时计数器的个数没有上限。没有
+	// 调用位置时，同一个日志记录器以同一个级别输出的日志记录共用一个计数器。
+	key := sampleKey{logger: e.LoggerName, level: e.Level}
+	if e.Caller.Defined {
+		key.caller = fmt.Sprintf("%s:%d", e.Caller.File, e.Caller.Line)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	if !now.Before(s.nextSweep) {
+		s.sweep(now)
+	}
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = &sampleCounter{resetAt: now.Add(s.tick)}
+		s.counters[key] = c
+	}
+	c.count++
+	if c.count <= s.first {
+		return true
+	}
+	if s.thereafter > 0 && (c.count-s.first)%s.thereafter == 0 {
+		return true
+	}
+	s.suppress(e, true)
+	return false
+}
+
+// sweep 删除过期的采样计数器，它们不会再被使用。每个采样窗口最多清理一次，与是否输出汇总无关。
+// 调用者需要持有 s.mutex。
+func (s *sampler) sweep(now time.Time) {
+	for key, c := range s.counters {
+		if !now.Before(c.resetAt) {
+			delete(s.counters, key)
+		}
+	}
+	s.nextSweep = now.Add(s.tick)
+}
+
+// limit 返回 false 时 entry 应该因为限流而被丢弃。
+func (s *sampler) limit(e zapcore.Entry) bool {
+	if len(s.limits) == 0 {
+		return true
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	name, ok := s.bucketOf[e.LoggerName]
+	if !ok {
+		name = s.subtree(e.LoggerName)
+		if len(s.bucketOf) >= maxCachedLoggers {
+			s.bucketOf = map[string]string{}
+		}
+		s.bucketOf[e.LoggerName] = name
+	}
+	if name == "" {
+		return true
+	}
+	b, ok := s.buckets[name]
+	if !ok {
+		limit := s.limits[name]
+		b = &tokenBucket{rate: limit.Rate, burst: float64(limit.Burst), tokens: float64(limit.Burst), last: s.now()}
+		s.buckets[name] = b
+	}
+	if b.allow(s.now()) {
+		return true
+	}
+	s.suppress(e, false)
+	return false
+}
+
+// subtree 按照与 LoggerLevels.calculateLevel 相同的规则找到 loggerName 所属的子树，没有配置限流时返回空字符串。
+func (s *sampler) subtree(loggerName string) string {
+	candidate := loggerName + "."
+	for {
+		if _, ok := s.limits[candidate]; ok {
+			return candidate
+		}
+		idx := strings.LastIndex(candidate, ".")
+		if idx <= 0 {
+			return ""
+		}
+		candidate = candidate[:idx]
+	}
+}
+
+// suppress 的调用者需要持有 s.mutex。
+func (s *sampler) suppress(e zapcore.Entry, sampled bool) {
+	key := suppressedKey{logger: e.LoggerName, level: e.Level}
+	c, ok := s.suppressed[key]
+	if !ok {
+		c = &suppressedCount{}
+		s.suppressed[key] = c
+	}
+	if sampled {
+		c.sampled++
+	} else {
+		c.rateLimited++
+	}
+}
+
+func (s *sampler) run(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.summarize()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// summarize 把上次汇总之后被丢弃的日志记录条数交给 report，按照日志记录器名和级别排序。
+func (s *sampler) summarize() {
+	s.mutex.Lock()
+	suppressed := s.suppressed
+	s.suppressed = map[suppressedKey]*suppressedCount{}
+	s.mutex.Unlock()
+
+	if s.report == nil {
+		return
+	}
+	keys := make([]suppressedKey, 0, len(suppressed))
+	for key := range suppressed {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].logger != keys[j].logger {
+			return keys[i].logger < keys[j].logger
+		}
+		return keys[i].level < keys[j].level
+	})
+	for _, key := range keys {
+		c := suppressed[key]
+		s.report(key.logger, key.level, c.sampled, c.rateLimited)
+	}
+}
+
+// Close 停止定期汇总，并把剩余的汇总信息输出。
+func (s *sampler) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	close(s.done)
+	s.wg.Wait()
+	s.summarize()
+	return nil
+}
+
+// Allow 依次进行采样和限流，级别不低于 PanicLevel 的日志记录以及汇总信息总是会被保留。
+func (l *Logging) Allow(e zapcore.Entry) bool {
+	l.mutex.RLock()
+	s, observer := l.sampler, l.observer
+	l.mutex.RUnlock()
+	if s == nil || e.Level >= zapcore.PanicLevel || e.LoggerName == samplerLoggerName {
+		return true
+	}
+
+	if !s.sample(e) {
+		if do, ok := observer.(DropObserver); ok {
+			do.Sampled(e)
+		}
+		return false
+	}
+	if !s.limit(e) {
+		if do, ok := observer.(DropObserver); ok {
+			do.RateLimited(e)
+		}
+		return false
+	}
+	return true
+}
+
+func (l *Logging) newSampler(c *SamplingConfig) (*sampler, error) {
+	if c == nil {
+		return nil, nil
+	}
+	summary := l.Logger(samplerLoggerName)
+	return newSampler(*c, func(logger string, level zapcore.Level, sampled, rateLimited uint64) {
+		summary.Warnw("suppressed log entries", "logger", logger, "level", level.String(), "sampled", sampled, "rate_limited", rateLimited)
+	})
+}
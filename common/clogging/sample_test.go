@@ -0,0 +1,161 @@
+package clogging
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging/metrics"
+	commonmetrics "github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestSampler(t *testing.T, c SamplingConfig) (*sampler, func(time.Duration), *[]string) {
+	var reports []string
+	c.SummaryInterval = -1
+	s, err := newSampler(c, func(logger string, level zapcore.Level, sampled, rateLimited uint64) {
+		reports = append(reports, fmt.Sprintf("%s %s %d %d", logger, level, sampled, rateLimited))
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }, &reports
+}
+
+func TestSamplerSample(t *testing.T) {
+	s, advance, reports := newTestSampler(t, SamplingConfig{First: 2, Thereafter: 3})
+
+	entry := zapcore.Entry{LoggerName: "gossip", Level: zapcore.WarnLevel, Caller: zapcore.NewEntryCaller(0, "gossip.go", 42, true)}
+	var kept []int
+	for i := 1; i <= 10; i++ {
+		entry.Message = fmt.Sprintf("peer %d unreachable", i)
+		if s.sample(entry) {
+			kept = append(kept, i)
+		}
+	}
+	require.Equal(t, []int{1, 2, 5, 8}, kept)
+
+	// 不同的调用位置、级别和日志记录器分别计数。
+	require.True(t, s.sample(zapcore.Entry{LoggerName: "gossip", Level: zapcore.WarnLevel, Message: "other"}))
+	require.True(t, s.sample(zapcore.Entry{LoggerName: "gossip", Level: zapcore.InfoLevel, Caller: entry.Caller}))
+	require.True(t, s.sample(zapcore.Entry{LoggerName: "gossip.pull", Level: zapcore.WarnLevel, Caller: entry.Caller}))
+
+	// 新的采样窗口重新计数。
+	advance(time.Second)
+	require.True(t, s.sample(entry))
+
+	s.Close()
+	require.Equal(t, []string{"gossip warn 6 0"}, *reports)
+}
+
+func TestSamplerBoundedState(t *testing.T) {
+	s, advance, _ := newTestSampler(t, SamplingConfig{First: 1, RateLimits: map[string]RateLimit{"gossip": {Rate: 1000}}})
+
+	// 没有调用位置时，不同的消息共用一个计数器。
+	for i := 0; i < 1000; i++ {
+		s.sample(zapcore.Entry{LoggerName: "gossip", Message: fmt.Sprintf("peer %d unreachable", i)})
+	}
+	require.Len(t, s.counters, 1)
+
+	// 即使不输出汇总，过期的计数器也会在之后的采样窗口里被清理。
+	for i := 0; i < 100; i++ {
+		s.sample(zapcore.Entry{LoggerName: "gossip", Caller: zapcore.NewEntryCaller(0, "gossip.go", i, true)})
+	}
+	require.Len(t, s.counters, 101)
+	advance(time.Second)
+	s.sample(zapcore.Entry{LoggerName: "consensus"})
+	require.Len(t, s.counters, 1)
+
+	for i := 0; i < 2*maxCachedLoggers; i++ {
+		s.limit(zapcore.Entry{LoggerName: fmt.Sprintf("gossip.peer%d", i)})
+	}
+	require.LessOrEqual(t, len(s.bucketOf), maxCachedLoggers)
+	require.Len(t, s.buckets, 1)
+}
+
+func TestSamplerDisabled(t *testing.T) {
+	s, _, _ := newTestSampler(t, SamplingConfig{})
+	for i := 0; i < 100; i++ {
+		require.True(t, s.sample(zapcore.Entry{LoggerName: "gossip", Message: "same"}))
+		require.True(t, s.limit(zapcore.Entry{LoggerName: "gossip", Message: "same"}))
+	}
+}
+
+func TestSamplerRateLimit(t *testing.T) {
+	s, advance, reports := newTestSampler(t, SamplingConfig{RateLimits: map[string]RateLimit{
+		"gossip":     {Rate: 1, Burst: 2},
+		"consensus.": {Rate: 0.5},
+	}})
+
+	// gossip 子树里的日志记录器共享一个令牌桶。
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "gossip"}))
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "gossip.pull"}))
+	require.False(t, s.limit(zapcore.Entry{LoggerName: "gossip.push"}))
+	advance(time.Second)
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "gossip.push"}))
+	require.False(t, s.limit(zapcore.Entry{LoggerName: "gossip"}))
+
+	// "consensus." 只对 consensus 生效，Burst 默认为 1。
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "consensus"}))
+	require.False(t, s.limit(zapcore.Entry{LoggerName: "consensus"}))
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "consensus.raft"}))
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "consensus.raft"}))
+	require.True(t, s.limit(zapcore.Entry{LoggerName: "gossipy"}))
+
+	s.summarize()
+	require.Equal(t, []string{"consensus info 0 1", "gossip info 0 1", "gossip.push info 0 1"}, *reports)
+	s.summarize()
+	require.Len(t, *reports, 3, "counts are reset after each summary")
+}
+
+func TestNewSamplerErrors(t *testing.T) {
+	_, err := newSampler(SamplingConfig{First: -1}, nil)
+	require.EqualError(t, err, "invalid sampling config: tick, first and thereafter must not be negative")
+
+	_, err = newSampler(SamplingConfig{RateLimits: map[string]RateLimit{"a b": {Rate: 1}}}, nil)
+	require.EqualError(t, err, "invalid rate limit: bad logger name 'a b'")
+
+	_, err = newSampler(SamplingConfig{RateLimits: map[string]RateLimit{"gossip": {}}}, nil)
+	require.EqualError(t, err, "invalid rate limit for logger 'gossip': rate must be positive and burst must not be negative")
+}
+
+func TestLoggingSampling(t *testing.T) {
+	counters := map[string]*metricsfakes.Counter{}
+	provider := &metricsfakes.Provider{}
+	provider.SetNewCounterStub(func(opts commonmetrics.CounterOpts) commonmetrics.Counter {
+		counter := &metricsfakes.Counter{}
+		counter.SetWithReturns(counter)
+		counters[opts.Name] = counter
+		return counter
+	})
+
+	buf := &bytes.Buffer{}
+	l, err := New(Config{
+		Format:   "%{message}",
+		Writer:   buf,
+		Sampling: &SamplingConfig{First: 1, Tick: time.Hour, SummaryInterval: -1},
+	})
+	require.NoError(t, err)
+	l.SetObserver(metrics.NewObserver(provider))
+
+	logger := l.Logger("gossip")
+	for i := 0; i < 5; i++ {
+		logger.Warnf("peer %d unreachable", i)
+	}
+	require.Equal(t, "peer 0 unreachable\n", buf.String())
+	require.Panics(t, func() { logger.Panic("always written") })
+
+	// 被替换的采样器会把剩余的汇总信息写入新的输出。
+	next := &bytes.Buffer{}
+	require.NoError(t, l.Apply(Config{Format: "%{message}", Writer: next}))
+	require.Equal(t, "suppressed log entries logger=gossip level=warn sampled=4 rate_limited=0\n", next.String())
+
+	sampled := counters["entries_sampled"]
+	require.Equal(t, 4, sampled.AddCallCount())
+	require.Equal(t, []string{"level", "warn"}, sampled.WithArgsForCall(0))
+	require.Zero(t, counters["entries_rate_limited"].AddCallCount())
+}
@@ -0,0 +1,145 @@
+package clogging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// loggerSelectorRegexp 在 loggerNameRegexp 的基础上允许整段的通配符："*" 匹配一段日志记录器名，"**" 匹配任意多段（包括零段）。
+var loggerSelectorRegexp = regexp.MustCompile(`^(\*\*|\*|[[:alnum:]_#:-]+)(\.(\*\*|\*|[[:alnum:]_#:-]+))*$`)
+
+// isValidLoggerSelector 判断 selector 是否可以出现在日志规范里，它可以是日志记录器名，也可以带有通配符，或者是以 "/" 包围的
+// 正则表达式，例如 "/peer\..*\.deliver/"。正则表达式需要匹配完整的日志记录器名，因为日志规范以 ":"、"=" 和 "," 作为分隔符，
+// 正则表达式里不能出现这三个字符。
+func isValidLoggerSelector(selector string) bool {
+	if isRegexSelector(selector) {
+		_, err := compileSelectorRegexp(selector)
+		return err == nil
+	}
+	return loggerSelectorRegexp.MatchString(selector)
+}
+
+// isGlobSelector 判断 selector 是否需要逐个与日志记录器名匹配，也就是带有通配符或者是正则表达式。
+func isGlobSelector(selector string) bool {
+	return strings.Contains(selector, "*") || isRegexSelector(strings.TrimSuffix(selector, "."))
+}
+
+func isRegexSelector(selector string) bool {
+	return len(selector) > 2 && strings.HasPrefix(selector, "/") && strings.HasSuffix(selector, "/")
+}
+
+func compileSelectorRegexp(selector string) (*regexp.Regexp, error) {
+	expr := selector[1 : len(selector)-1]
+	if strings.ContainsAny(expr, ":=,") {
+		return nil, fmt.Errorf("regular expression selector '%s' must not contain ':', '=' or ','", selector)
+	}
+	return regexp.Compile(`^(?:` + expr + `)$`)
+}
+
+// selectorMatch 描述一个选择器与日志记录器名的匹配结果，用于在多个匹配的选择器之间决定哪个最具体。
+type selectorMatch struct {
+	selector string
+	// depth 是选择器里最后一个不是 "**" 的段在日志记录器名里匹配到的位置，末尾的 "**" 不会让选择器变得更具体。
+	depth int
+	// literals 是选择器里不是通配符的段数，正则表达式选择器的每一段都被看作通配符。
+	literals int
+	// exact 表示选择器以点号结尾，只匹配完整的日志记录器名。
+	exact bool
+}
+
+// moreSpecificThan 实现了选择器的优先级规则：匹配到的位置越深越具体；位置相同时，通配符越少越具体；仍然相同时，
+// 以点号结尾的选择器更具体；最后按照选择器的字典序决定，保证结果是确定的。
+func (m selectorMatch) moreSpecificThan(o selectorMatch) bool {
+	if m.depth != o.depth {
+		return m.depth > o.depth
+	}
+	if m.literals != o.literals {
+		return m.literals > o.literals
+	}
+	if m.exact != o.exact {
+		return m.exact
+	}
+	return m.selector < o.selector
+}
+
+type globSelector struct {
+	selector string
+	segments []string
+	// re 不为 nil 时，selector 是正则表达式选择器，segments 不会被使用。
+	re       *regexp.Regexp
+	literals int
+	exact    bool
+	level    zapcore.Level
+}
+
+func newGlobSelector(selector string, level zapcore.Level) globSelector {
+	g := globSelector{selector: selector, level: level, exact: strings.HasSuffix(selector, ".")}
+	if expr := strings.TrimSuffix(selector, "."); isRegexSelector(expr) {
+		// 正则表达式总是匹配完整的日志记录器名，末尾的点号没有作用。
+		g.re, _ = compileSelectorRegexp(expr)
+		g.exact = true
+		return g
+	}
+	// 连续的 "**" 与一个 "**" 等价；末尾的 "**" 可以匹配剩余的任意段，相当于前缀匹配，所以去掉它并取消 exact。
+	for _, s := range strings.Split(strings.TrimSuffix(selector, "."), ".") {
+		if s == "**" && len(g.segments) > 0 && g.segments[len(g.segments)-1] == "**" {
+			continue
+		}
+		g.segments = append(g.segments, s)
+		if s != "*" && s != "**" {
+			g.literals++
+		}
+	}
+	if n := len(g.segments); g.segments[n-1] == "**" {
+		g.segments = g.segments[:n-1]
+		g.exact = false
+	}
+	return g
+}
+
+// match 与日志记录器名的各段进行匹配。不以点号结尾的选择器匹配日志记录器名的前缀，也就是同时匹配子日志记录器。
+func (g globSelector) match(name []string) (selectorMatch, bool) {
+	if g.re != nil {
+		if !g.re.MatchString(strings.Join(name, ".")) {
+			return selectorMatch{}, false
+		}
+		return selectorMatch{selector: g.selector, depth: len(name), exact: true}, true
+	}
+	depth := matchSegments(g.segments, name, g.exact)
+	if depth < 0 {
+		return selectorMatch{}, false
+	}
+	return selectorMatch{selector: g.selector, depth: depth, literals: g.literals, exact: g.exact}, true
+}
+
+// matchSegments 返回 pattern 最多能够匹配 name 开头的多少段，不能匹配时返回 -1。full 为 true 时必须匹配 name 的全部段。
+// pattern 不能以 "**" 结尾。
+func matchSegments(pattern, name []string, full bool) int {
+	if len(pattern) == 0 {
+		if full && len(name) != 0 {
+			return -1
+		}
+		return 0
+	}
+
+	if pattern[0] == "**" {
+		best := -1
+		for i := 0; i <= len(name); i++ {
+			if n := matchSegments(pattern[1:], name[i:], full); n >= 0 && i+n > best {
+				best = i + n
+			}
+		}
+		return best
+	}
+	if len(name) == 0 || (pattern[0] != "*" && pattern[0] != name[0]) {
+		return -1
+	}
+	n := matchSegments(pattern[1:], name[1:], full)
+	if n < 0 {
+		return -1
+	}
+	return n + 1
+}
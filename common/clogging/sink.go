@@ -0,0 +1,271 @@
+package clogging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/232425wxy/chainer/common/clogging/cenc"
+	"github.com/232425wxy/chainer/common/metrics"
+	zaplogfmt "github.com/sykesm/zap-logfmt"
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkConfig 描述一个额外的日志输出端。每个输出端都有自己的写入器、编码格式和日志级别，
+// ChainerLogger 的一次调用会同时送达主输出和所有的输出端。
+type SinkConfig struct {
+	// Name 是输出端的名字，在同一个 Config 里必须唯一。
+	Name string
+	// Format 的取值与 Config.Format 相同："json"、"logfmt" 或者控制台格式字符串，为空时使用默认的控制台格式。
+	Format string
+	// LogSpec 的语法与 Config.LogSpec 相同，为空时输出端与主输出使用相同的日志级别。
+	LogSpec string
+	Writer  io.Writer
+	// Output 的取值与 Config.Output 相同。
+	Output string
+	Color  ColorMode
+	Rotate *RotateConfig
+	Async  *AsyncConfig
+}
+
+// Sink 是根据 SinkConfig 创建的日志输出端。
+type Sink struct {
+	name     string
+	encoding Encoding
+	encoder  zapcore.Encoder
+	levels   *LoggerLevels
+	writer   zapcore.WriteSyncer
+	owned    []io.Closer
+}
+
+func (s *Sink) Name() string {
+	return s.name
+}
+
+func (s *Sink) Encoding() Encoding {
+	return s.encoding
+}
+
+// Spec 返回输出端自己的日志规范，没有单独配置日志规范时返回空字符串。
+func (s *Sink) Spec() string {
+	if s.levels == nil {
+		return ""
+	}
+	return s.levels.Spec()
+}
+
+// enabled 判断输出端是否需要写入 entry，没有单独配置日志规范的输出端使用 defaults 判断。
+func (s *Sink) enabled(defaults *LoggerLevels, e zapcore.Entry) bool {
+	levels := s.levels
+	if levels == nil {
+		levels = defaults
+	}
+	return levels.Level(e.LoggerName).Enabled(e.Level)
+}
+
+func (s *Sink) minLevelEnabled(lvl zapcore.Level) bool {
+	return s.levels != nil && s.levels.Enabled(lvl)
+}
+
+// write 编码并写入一条日志记录，contextFields 是通过 Core.With 添加的字段。
+func (s *Sink) write(e zapcore.Entry, contextFields, fields []zapcore.Field) error {
+	if len(contextFields) > 0 {
+		all := make([]zapcore.Field, 0, len(contextFields)+len(fields))
+		all = append(all, contextFields...)
+		fields = append(all, fields...)
+	}
+
+	buf, err := s.encoder.EncodeEntry(e, fields)
+	if err != nil {
+		return err
+	}
+	_, err = s.writer.Write(buf.Bytes())
+	buf.Free()
+	return err
+}
+
+func (s *Sink) sync() error {
+	return s.writer.Sync()
+}
+
+func (s *Sink) close() {
+	for _, closer := range s.owned {
+		closer.Close()
+	}
+}
+
+func (l *Logging) newSink(c SinkConfig) (*Sink, error) {
+	if !isValidLoggerName(c.Name) {
+		return nil, fmt.Errorf("invalid sink name: '%s'", c.Name)
+	}
+
+	encoding, formatters, err := parseFormat(c.Format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format for sink '%s': %s", c.Name, err)
+	}
+
+	s := &Sink{name: c.Name, encoding: encoding}
+	if c.LogSpec != "" {
+		s.levels = &LoggerLevels{}
+		if err := s.levels.ActivateSpec(c.LogSpec); err != nil {
+			return nil, fmt.Errorf("invalid log spec for sink '%s': %s", c.Name, err)
+		}
+	}
+
+	w, owned, err := openWriter(c.Writer, c.Output, c.Rotate, c.Async, l.droppedCounter(c.Async))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open writer for sink '%s': %s", c.Name, err)
+	}
+	s.writer = writeSyncer(w)
+	s.owned = owned
+
+	switch encoding {
+	case JSON:
+		s.encoder = zapcore.NewJSONEncoder(l.encoderConfig)
+	case LOGFMT:
+		s.encoder = zaplogfmt.NewEncoder(l.encoderConfig)
+	default:
+		s.encoder = cenc.NewFormatEncoder(colorFormatters(formatters, c.Color, w)...)
+	}
+	return s, nil
+}
+
+func (l *Logging) newSinks(configs []SinkConfig) ([]*Sink, error) {
+	var sinks []*Sink
+	names := map[string]struct{}{}
+	for _, c := range configs {
+		if _, ok := names[c.Name]; ok {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("duplicate sink name: '%s'", c.Name)
+		}
+		names[c.Name] = struct{}{}
+
+		s, err := l.newSink(c)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// Sinks 返回当前所有的额外输出端。
+func (l *Logging) Sinks() []*Sink {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.sinks
+}
+
+// WithSinks 在持有读锁的情况下把当前所有的额外输出端交给 fn，这样 Apply 替换输出端时，
+// 被替换的输出端上不会再有正在进行的写入，可以被安全地关闭。
+func (l *Logging) WithSinks(fn func(sinks []*Sink)) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	fn(l.sinks)
+}
+
+// Enabled 只要主输出或者任意一个单独配置了日志规范的输出端启用了 lvl，就返回 true。
+func (l *Logging) Enabled(lvl zapcore.Level) bool {
+	if l.LoggerLevels.Enabled(lvl) {
+		return true
+	}
+	for _, s := range l.Sinks() {
+		if s.minLevelEnabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func closeSinks(sinks []*Sink) {
+	for _, s := range sinks {
+		s.close()
+	}
+}
+
+// openWriter 根据配置创建写入器，返回的 io.Closer 是需要由调用者负责关闭的写入器，排在前面的需要先关闭。
+func openWriter(w io.Writer, output string, rotate *RotateConfig, async *AsyncConfig, droppedCounter metrics.Counter) (io.Writer, []io.Closer, error) {
+	var owned []io.Closer
+	switch {
+	case w != nil:
+	case rotate != nil:
+		rotating, err := NewRotatingWriter(*rotate)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = rotating
+		owned = append(owned, rotating)
+	case output == "" || output == "stderr":
+		w = os.Stderr
+	case output == "stdout":
+		w = os.Stdout
+	default:
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			return nil, nil, err
+		}
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = file
+		owned = append(owned, file)
+	}
+	if async != nil {
+		// 异步写入器需要先于它下层的写入器关闭，这样缓冲区里剩余的日志记录才能被写出。
+		aw := newAsyncWriter(writeSyncer(w), *async, droppedCounter)
+		w = aw
+		owned = append([]io.Closer{aw}, owned...)
+	}
+	return w, owned, nil
+}
+
+// colorFormatters 根据颜色模式决定是否去掉 formatters 里的 cenc.ColorFormatter。
+func colorFormatters(formatters []cenc.Formatter, mode ColorMode, w io.Writer) []cenc.Formatter {
+	switch mode {
+	case ColorNever:
+	case ColorAuto:
+		if isTerminal(w) {
+			return formatters
+		}
+	default:
+		return formatters
+	}
+
+	var stripped []cenc.Formatter
+	for _, f := range formatters {
+		if _, ok := f.(cenc.ColorFormatter); ok {
+			continue
+		}
+		stripped = append(stripped, f)
+	}
+	return stripped
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// parseFormat 解析日志格式，返回对应的编码方式，只有控制台格式会返回 cenc.Formatter。
+func parseFormat(format string) (Encoding, []cenc.Formatter, error) {
+	switch format {
+	case "":
+		format = defaultFormat
+	case "json":
+		return JSON, nil, nil
+	case "logfmt":
+		return LOGFMT, nil, nil
+	}
+
+	formatters, err := cenc.ParseFormat(format)
+	if err != nil {
+		return CONSOLE, nil, err
+	}
+	return CONSOLE, formatters, nil
+}
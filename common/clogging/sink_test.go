@@ -0,0 +1,144 @@
+package clogging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSinksFanOut(t *testing.T) {
+	console, jsonBuf, logfmtBuf := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format:  "%{level} %{message}",
+		LogSpec: "info",
+		Writer:  console,
+		Sinks: []clogging.SinkConfig{
+			{Name: "json", Format: "json", Writer: jsonBuf},
+			{Name: "logfmt", Format: "logfmt", Writer: logfmtBuf},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, logging.Sinks(), 2)
+	require.Equal(t, "json", logging.Sinks()[0].Name())
+	require.Equal(t, clogging.Encoding(clogging.JSON), logging.Sinks()[0].Encoding())
+
+	logger := logging.Logger("peer.gossip").With("node", "n1")
+	logger.Infow("block received", "height", 7)
+	logger.Debug("not enabled anywhere")
+
+	require.Equal(t, "INFO block received node=n1 height=7\n", console.String())
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(jsonBuf.Bytes(), &entry))
+	require.Equal(t, "block received", entry["msg"])
+	require.Equal(t, "peer.gossip", entry["name"])
+	require.Equal(t, "n1", entry["node"])
+	require.Equal(t, float64(7), entry["height"])
+
+	require.Contains(t, logfmtBuf.String(), `msg="block received"`)
+	require.Contains(t, logfmtBuf.String(), "node=n1 height=7")
+	require.Equal(t, 1, strings.Count(logfmtBuf.String(), "\n"))
+}
+
+func TestSinkLogSpec(t *testing.T) {
+	console, debugBuf, errorBuf := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format:  "%{message}",
+		LogSpec: "info",
+		Writer:  console,
+		Sinks: []clogging.SinkConfig{
+			{Name: "debug", Format: "%{level} %{message}", LogSpec: "consensus=debug:warn", Writer: debugBuf},
+			{Name: "errors", Format: "%{message}", LogSpec: "error", Writer: errorBuf},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "consensus=debug:warn", logging.Sinks()[0].Spec())
+
+	consensus := logging.Logger("consensus")
+	require.True(t, consensus.IsEnabledFor(zapcore.DebugLevel), "debug is enabled by the debug sink")
+
+	consensus.Debug("vote")
+	consensus.Info("commit")
+	consensus.Error("timeout")
+	logging.Logger("gossip").Info("peer joined")
+
+	require.Equal(t, "commit\ntimeout\npeer joined\n", console.String())
+	require.Equal(t, "DEBUG vote\nINFO commit\nERROR timeout\n", debugBuf.String())
+	require.Equal(t, "timeout\n", errorBuf.String())
+
+	// 修改主输出的日志规范不会影响单独配置了日志规范的输出端。
+	require.NoError(t, logging.ActivateSpec("error"))
+	console.Reset()
+	debugBuf.Reset()
+	consensus.Info("commit")
+	require.Empty(t, console.String())
+	require.Equal(t, "INFO commit\n", debugBuf.String())
+}
+
+func TestSinksReplacedOnApply(t *testing.T) {
+	first, second := &bytes.Buffer{}, &bytes.Buffer{}
+	logging, err := clogging.New(clogging.Config{
+		Format: "%{message}",
+		Writer: &bytes.Buffer{},
+		Sinks:  []clogging.SinkConfig{{Name: "first", Format: "%{message}", Writer: first}},
+	})
+	require.NoError(t, err)
+	logger := logging.Logger("test")
+	logger.Info("one")
+
+	require.NoError(t, logging.Apply(clogging.Config{
+		Format: "%{message}",
+		Writer: &bytes.Buffer{},
+		Sinks:  []clogging.SinkConfig{{Name: "second", Format: "%{message}", Writer: second}},
+	}))
+	logger.Info("two")
+
+	require.Equal(t, "one\n", first.String())
+	require.Equal(t, "two\n", second.String())
+}
+
+func TestSinkConfigErrors(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		sinks []clogging.SinkConfig
+		err   string
+	}{
+		{
+			desc:  "bad name",
+			sinks: []clogging.SinkConfig{{Name: ""}},
+			err:   "invalid sink name: ''",
+		},
+		{
+			desc:  "duplicate name",
+			sinks: []clogging.SinkConfig{{Name: "file", Writer: &bytes.Buffer{}}, {Name: "file", Writer: &bytes.Buffer{}}},
+			err:   "duplicate sink name: 'file'",
+		},
+		{
+			desc:  "bad format",
+			sinks: []clogging.SinkConfig{{Name: "file", Format: "%{color:bad}"}},
+			err:   "invalid format for sink 'file': invalid color option: bad",
+		},
+		{
+			desc:  "bad spec",
+			sinks: []clogging.SinkConfig{{Name: "file", LogSpec: "a=b=c"}},
+			err:   "invalid log spec for sink 'file': invalid logging specification 'a=b=c': bad segment 'a=b=c'",
+		},
+		{
+			desc:  "bad writer",
+			sinks: []clogging.SinkConfig{{Name: "file", Rotate: &clogging.RotateConfig{}}},
+			err:   "failed to open writer for sink 'file': rotating writer requires a filename",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := clogging.New(clogging.Config{Sinks: tt.sinks})
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
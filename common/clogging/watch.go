@@ -0,0 +1,186 @@
+package clogging
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	cmetrics "github.com/232425wxy/chainer/common/clogging/metrics"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
+)
+
+const defaultPollInterval = time.Second
+
+type WatchConfig struct {
+	// Path 是被监视的配置文件的路径。
+	Path string
+	// PollInterval 是检查配置文件是否被修改的时间间隔，默认为 1 秒。
+	PollInterval time.Duration
+	// Signals 是会触发重新加载的信号，默认为 SIGHUP。收到信号时即使配置文件没有变化也会重新加载，
+	// 这样被 logrotate 之类的工具移走的日志文件会被重新打开。RotatingWriter.ReopenOnSignal 默认也监听 SIGHUP，
+	// 二者同时使用时一个 SIGHUP 会让两边都重新打开各自的文件，这是无害的；重新加载会替换 Logging 根据配置创建的
+	// 写入器，所以不需要再对这些写入器调用 ReopenOnSignal。
+	Signals []os.Signal
+	// Provider 用于导出重新加载的次数和最后一次成功重新加载的时间，为 nil 时使用 disabled.Provider，不导出指标。
+	Provider metrics.Provider
+}
+
+// Watcher 在配置文件被修改或者进程收到信号时重新加载配置文件，并通过 Logging.Apply 应用新的配置。
+// 重新加载失败时 Logging 保持原有的配置，错误会通过名为 "clogging.watcher" 的日志记录器输出。
+type Watcher struct {
+	logging *Logging
+	path    string
+	logger  *ChainerLogger
+
+	// mutex 保证同一时刻只有一次重新加载。
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	data    []byte
+	// pending 是上一次检查时发现的、还没有被加载的文件状态。
+	pending os.FileInfo
+
+	reloadCounter   metrics.Counter
+	lastReloadGauge metrics.Gauge
+
+	signals  chan os.Signal
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// Watch 先应用一次配置文件，成功之后开始在后台监视它，应用失败时返回错误并且不会启动监视。
+func (l *Logging) Watch(c WatchConfig) (*Watcher, error) {
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if len(c.Signals) == 0 {
+		c.Signals = []os.Signal{syscall.SIGHUP}
+	}
+	if c.Provider == nil {
+		c.Provider = &disabled.Provider{}
+	}
+
+	w := &Watcher{
+		logging: l,
+		path:    c.Path,
+		logger:  l.Logger("clogging.watcher"),
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+
+		reloadCounter:   c.Provider.NewCounter(cmetrics.ReloadCountOpts),
+		lastReloadGauge: c.Provider.NewGauge(cmetrics.LastReloadGaugeOpts),
+	}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+
+	signal.Notify(w.signals, c.Signals...)
+	w.wg.Add(1)
+	go w.run(c.PollInterval)
+	return w, nil
+}
+
+// Reload 立即重新加载配置文件，不管它是否被修改过。
+func (w *Watcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.reload()
+}
+
+// Stop 停止监视配置文件，Logging 保持当前的配置。Stop 可以被并发地多次调用。
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		signal.Stop(w.signals)
+		close(w.done)
+	})
+	w.wg.Wait()
+}
+
+func (w *Watcher) run(interval time.Duration) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case sig := <-w.signals:
+			w.logger.Infow("reloading logging config", "path", w.path, "signal", sig.String())
+			w.Reload()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// poll 只有在配置文件的修改时间、大小或者内容发生变化时才重新加载。发现变化之后要等到下一次检查时文件的状态
+// 保持不变才加载，避免读到正在被写入的文件。
+func (w *Watcher) poll() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		// 编辑器保存文件时可能会短暂地删除它，等到文件重新出现时再处理。
+		w.pending = nil
+		return
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		w.pending = nil
+		return
+	}
+	if w.pending == nil || !info.ModTime().Equal(w.pending.ModTime()) || info.Size() != w.pending.Size() {
+		w.pending = info
+		return
+	}
+	w.pending = nil
+
+	data, err := os.ReadFile(w.path)
+	if err == nil && bytes.Equal(data, w.data) {
+		w.modTime, w.size = info.ModTime(), info.Size()
+		return
+	}
+	w.reload()
+}
+
+func (w *Watcher) reload() error {
+	err := w.load()
+	if err != nil {
+		w.logger.Errorw("failed to reload logging config, keeping the previous config", "path", w.path, "error", err)
+		w.reloadCounter.With("result", "failure").Add(1)
+		return err
+	}
+
+	w.logger.Infow("reloaded logging config", "path", w.path, "spec", w.logging.Spec())
+	w.reloadCounter.With("result", "success").Add(1)
+	return nil
+}
+
+// load 的调用者需要持有 w.mutex，失败的加载同样会记录文件的状态，避免同一份错误的配置被反复加载。
+func (w *Watcher) load() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	w.modTime, w.size, w.data = info.ModTime(), info.Size(), data
+
+	c, err := parseConfigFile(w.path, data)
+	if err != nil {
+		return err
+	}
+	if err := w.logging.Apply(c); err != nil {
+		return err
+	}
+	w.lastReloadGauge.Set(float64(time.Now().Unix()))
+	return nil
+}
@@ -0,0 +1,101 @@
+package clogging_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/require"
+)
+
+func newWatchProvider() (*metricsfakes.Provider, *metricsfakes.Counter, *metricsfakes.Gauge) {
+	counter := &metricsfakes.Counter{}
+	counter.SetWithReturns(counter)
+	gauge := &metricsfakes.Gauge{}
+	gauge.SetWithReturns(gauge)
+	provider := &metricsfakes.Provider{}
+	provider.SetNewCounterReturns(counter)
+	provider.SetNewGaugeRetruns(gauge)
+	return provider, counter, gauge
+}
+
+func TestWatcherReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "node.log")
+	path := filepath.Join(dir, "logging.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("format: '%{message}'\nlevel: info\noutput: "+output+"\n"), 0o644))
+
+	provider, counter, gauge := newWatchProvider()
+	logging, err := clogging.New(clogging.Config{})
+	require.NoError(t, err)
+	w, err := logging.Watch(clogging.WatchConfig{Path: path, PollInterval: 10 * time.Millisecond, Provider: provider})
+	require.NoError(t, err)
+	defer w.Stop()
+	require.Equal(t, "info", logging.Spec())
+	require.Equal(t, 1, gauge.SetCallCount())
+	require.Greater(t, gauge.SetArgsForCall(0), float64(0))
+
+	require.NoError(t, os.WriteFile(path, []byte("format: '%{message}'\nlevel: debug\nloggers: {gossip: warn}\noutput: "+output+"\n"), 0o644))
+	require.Eventually(t, func() bool { return counter.AddCallCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "gossip=warn:debug", logging.Spec())
+	require.Equal(t, []string{"result", "success"}, counter.WithArgsForCall(0))
+	require.Equal(t, 2, gauge.SetCallCount())
+
+	// 错误的配置不会替换当前的配置，错误会被记录到当前的输出里。
+	require.NoError(t, os.WriteFile(path, []byte("level: loud\n"), 0o644))
+	require.Eventually(t, func() bool { return counter.AddCallCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"result", "failure"}, counter.WithArgsForCall(1))
+	require.Equal(t, "gossip=warn:debug", logging.Spec())
+	require.Equal(t, 2, gauge.SetCallCount())
+
+	bz, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Contains(t, string(bz), "failed to reload logging config, keeping the previous config path="+path+` error="invalid logging config `+path+`: level: invalid log level: loud"`)
+
+	// 同一份错误的配置不会被反复加载。
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 2, counter.AddCallCount())
+}
+
+func TestWatcherReloadsOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logging.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"spec": "warn", "output": "`+filepath.Join(dir, "node.log")+`"}`), 0o644))
+
+	provider, counter, _ := newWatchProvider()
+	logging, err := clogging.New(clogging.Config{})
+	require.NoError(t, err)
+	w, err := logging.Watch(clogging.WatchConfig{Path: path, PollInterval: time.Hour, Signals: []os.Signal{syscall.SIGUSR2}, Provider: provider})
+	require.NoError(t, err)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	require.Eventually(t, func() bool { return counter.AddCallCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"result", "success"}, counter.WithArgsForCall(0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Stop()
+		}()
+	}
+	wg.Wait()
+	w.Stop()
+	require.NoError(t, os.WriteFile(path, []byte(`{"spec": "a=b=c"}`), 0o644))
+	require.Error(t, w.Reload())
+	require.Equal(t, "warn", logging.Spec())
+}
+
+func TestWatchInvalidConfig(t *testing.T) {
+	logging, err := clogging.New(clogging.Config{})
+	require.NoError(t, err)
+
+	_, err = logging.Watch(clogging.WatchConfig{Path: filepath.Join(t.TempDir(), "missing.yaml")})
+	require.Error(t, err)
+}
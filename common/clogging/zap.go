@@ -1,6 +1,7 @@
 package clogging
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -19,6 +20,7 @@ func NewChainerLogger(l *zap.Logger, options ...zap.Option) *ChainerLogger {
 	}
 }
 
+// NewGRPCLogger 把 grpc 的日志全部交给 l 输出。它不区分 grpc 的组件和详细级别，Logging.GRPCLogger 提供了可配置的实现。
 func NewGRPCLogger(l *zap.Logger) *zapgrpc.Logger {
 	l = l.WithOptions(zap.AddCaller(), zap.AddCallerSkip(3))
 	return zapgrpc.NewLogger(l, zapgrpc.WithDebug())
@@ -138,6 +140,43 @@ func (cl *ChainerLogger) Fatalw(msg string, kvs ...interface{}) {
 	cl.sl.Fatalw(msg, kvs...)
 }
 
+// Ctx 返回一个带有从 ctx 里取出的字段的日志记录器。
+func (cl *ChainerLogger) Ctx(ctx context.Context) *ChainerLogger {
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return cl
+	}
+	return &ChainerLogger{sl: cl.sl.Desugar().With(fields...).Sugar()}
+}
+
+func (cl *ChainerLogger) DebugwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.Debugw(msg, contextArgs(ctx, kvs)...)
+}
+
+func (cl *ChainerLogger) InfowCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.Infow(msg, contextArgs(ctx, kvs)...)
+}
+
+func (cl *ChainerLogger) WarnwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.Warnw(msg, contextArgs(ctx, kvs)...)
+}
+
+func (cl *ChainerLogger) ErrorwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.Errorw(msg, contextArgs(ctx, kvs)...)
+}
+
+func (cl *ChainerLogger) DPanicwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.DPanicw(msg, contextArgs(ctx, kvs)...)
+}
+
+func (cl *ChainerLogger) PanicwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.Panicw(msg, contextArgs(ctx, kvs)...)
+}
+
+func (cl *ChainerLogger) FatalwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	cl.sl.Fatalw(msg, contextArgs(ctx, kvs)...)
+}
+
 func formatArgs(args ...interface{}) string {
 	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
 }
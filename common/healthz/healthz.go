@@ -0,0 +1,149 @@
+package healthz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK          = "OK"
+	StatusUnavailable = "Service Unavailable"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// HealthChecker 由需要参与健康检查的组件实现，检查不通过时返回一个描述原因的错误。
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// FailedCheck 记录一个未通过健康检查的组件以及失败的原因。
+type FailedCheck struct {
+	Component string `json:"component"`
+	Reason    string `json:"reason"`
+}
+
+// HealthStatus 是 /healthz 接口返回的内容。
+type HealthStatus struct {
+	Status       string        `json:"status"`
+	Time         time.Time     `json:"time"`
+	FailedChecks []FailedCheck `json:"failed_checks,omitempty"`
+}
+
+// HealthHandler 是一个 http.Handler，收到 GET 请求时会并发地调用所有已注册的 HealthChecker，
+// 全部通过则返回 200，否则返回 503 以及未通过检查的组件列表。
+type HealthHandler struct {
+	mutex          sync.RWMutex
+	healthCheckers map[string]HealthChecker
+	now            func() time.Time
+	timeout        time.Duration
+}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{
+		healthCheckers: map[string]HealthChecker{},
+		now:            time.Now,
+		timeout:        defaultTimeout,
+	}
+}
+
+// RegisterChecker 为组件 component 注册一个 HealthChecker，同一个组件不能被重复注册。
+func (h *HealthHandler) RegisterChecker(component string, checker HealthChecker) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.healthCheckers[component]; ok {
+		return fmt.Errorf("component '%s' is already registered", component)
+	}
+	h.healthCheckers[component] = checker
+	return nil
+}
+
+func (h *HealthHandler) DeregisterChecker(component string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.healthCheckers, component)
+}
+
+// SetTimeout 设置一轮健康检查的超时时间，超时后仍未返回的组件会被视为检查失败。
+func (h *HealthHandler) SetTimeout(timeout time.Duration) {
+	h.mutex.Lock()
+	h.timeout = timeout
+	h.mutex.Unlock()
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mutex.RLock()
+	timeout := h.timeout
+	h.mutex.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	status := HealthStatus{Status: StatusOK, Time: h.now()}
+	code := http.StatusOK
+	if failedChecks := h.RunChecks(ctx); len(failedChecks) > 0 {
+		status.Status = StatusUnavailable
+		status.FailedChecks = failedChecks
+		code = http.StatusServiceUnavailable
+	}
+
+	bz, err := json.Marshal(status)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(bz)
+}
+
+// RunChecks 并发地执行所有已注册的健康检查，返回未通过检查的组件，结果按照完成的先后顺序排列。
+func (h *HealthHandler) RunChecks(ctx context.Context) []FailedCheck {
+	h.mutex.RLock()
+	checkers := make(map[string]HealthChecker, len(h.healthCheckers))
+	for component, checker := range h.healthCheckers {
+		checkers[component] = checker
+	}
+	h.mutex.RUnlock()
+
+	var failedChecks []FailedCheck
+	results := make(chan FailedCheck, len(checkers))
+	pending := map[string]struct{}{}
+	for component, checker := range checkers {
+		pending[component] = struct{}{}
+		go func(component string, checker HealthChecker) {
+			var reason string
+			if err := checker.HealthCheck(ctx); err != nil {
+				reason = err.Error()
+			}
+			results <- FailedCheck{Component: component, Reason: reason}
+		}(component, checker)
+	}
+
+	for len(pending) > 0 {
+		select {
+		case result := <-results:
+			delete(pending, result.Component)
+			if result.Reason != "" {
+				failedChecks = append(failedChecks, result)
+			}
+		case <-ctx.Done():
+			for component := range pending {
+				failedChecks = append(failedChecks, FailedCheck{Component: component, Reason: "failed to complete health check within the timeout"})
+			}
+			return failedChecks
+		}
+	}
+
+	return failedChecks
+}
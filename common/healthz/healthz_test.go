@@ -0,0 +1,81 @@
+package healthz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type checkerFunc func(ctx context.Context) error
+
+func (f checkerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestRegisterChecker(t *testing.T) {
+	h := NewHealthHandler()
+	ok := checkerFunc(func(context.Context) error { return nil })
+
+	require.NoError(t, h.RegisterChecker("gossip", ok))
+	require.EqualError(t, h.RegisterChecker("gossip", ok), "component 'gossip' is already registered")
+
+	h.DeregisterChecker("gossip")
+	require.NoError(t, h.RegisterChecker("gossip", ok))
+}
+
+func TestHealthHandler(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHealthHandler()
+	h.now = func() time.Time { return now }
+
+	require.NoError(t, h.RegisterChecker("ledger", checkerFunc(func(context.Context) error { return nil })))
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+	var status HealthStatus
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+	require.Equal(t, HealthStatus{Status: StatusOK, Time: now}, status)
+
+	require.NoError(t, h.RegisterChecker("gossip", checkerFunc(func(context.Context) error { return errors.New("no peers") })))
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	status = HealthStatus{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+	require.Equal(t, StatusUnavailable, status.Status)
+	require.Equal(t, []FailedCheck{{Component: "gossip", Reason: "no peers"}}, status.FailedChecks)
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestHealthHandlerTimeout(t *testing.T) {
+	h := NewHealthHandler()
+	h.SetTimeout(10 * time.Millisecond)
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	require.NoError(t, h.RegisterChecker("slow", checkerFunc(func(context.Context) error {
+		<-blocked
+		return nil
+	})))
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	var status HealthStatus
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+	require.Equal(t, []FailedCheck{{Component: "slow", Reason: "failed to complete health check within the timeout"}}, status.FailedChecks)
+}
@@ -0,0 +1,81 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/util/conn"
+	"github.com/go-kit/log"
+)
+
+// DefaultMaxPacketSize 是一个 UDP 包在以太网上不被分片时能够携带的指标数据的大小。
+const DefaultMaxPacketSize = 1432
+
+// Writer 把一次次写入的指标行合并起来，每攒够 MaxPacketSize 字节才向底层的 io.Writer 写一次，这样通过 UDP 发送指标时
+// 不会每一行都占用一个网络包。每次调用 Write 写入的数据会被完整地放进同一个包里，所以调用者应该一次写入一整行，超过
+// MaxPacketSize 的一行会单独占用一个包。写入结束后必须调用 Flush 发送剩余的数据。
+type Writer struct {
+	w    io.Writer
+	size int
+	buf  bytes.Buffer
+}
+
+// NewWriter 返回向 w 写入数据的 Writer，maxPacketSize 不大于 0 时使用 DefaultMaxPacketSize。
+func NewWriter(w io.Writer, maxPacketSize int) *Writer {
+	if maxPacketSize <= 0 {
+		maxPacketSize = DefaultMaxPacketSize
+	}
+	return &Writer{w: w, size: maxPacketSize}
+}
+
+func (bw *Writer) Write(p []byte) (int, error) {
+	if bw.buf.Len() > 0 && bw.buf.Len()+len(p) > bw.size {
+		if err := bw.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	bw.buf.Write(p)
+	if bw.buf.Len() >= bw.size {
+		if err := bw.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 把缓存的数据写到底层的 io.Writer，写入失败时缓存的数据会被丢弃。
+func (bw *Writer) Flush() error {
+	if bw.buf.Len() == 0 {
+		return nil
+	}
+	defer bw.buf.Reset()
+	_, err := bw.w.Write(bw.buf.Bytes())
+	return err
+}
+
+// WriteToFunc 把缓存的指标写到 io.Writer 里，例如 go-kit 的 Dogstatsd.WriteTo。
+type WriteToFunc func(w io.Writer) (int64, error)
+
+// SendLoop 在 c 每次触发时调用 writeTo，把指标按包合并之后发送到 network 和 address 指定的地址，直到 ctx 被取消。
+// 连接断开之后会自动重连，发送失败的指标会被丢弃。
+func SendLoop(ctx context.Context, c <-chan time.Time, network, address string, maxPacketSize int, writeTo WriteToFunc, logger log.Logger) {
+	manager := conn.NewDefaultManager(network, address, logger)
+	bw := NewWriter(manager, maxPacketSize)
+	for {
+		select {
+		case <-c:
+			_, err := writeTo(bw)
+			if err == nil {
+				err = bw.Flush()
+			}
+			if err != nil {
+				bw.buf.Reset()
+				logger.Log("during", "WriteTo", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package batch
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+type packetRecorder struct {
+	packets []string
+}
+
+func (r *packetRecorder) Write(p []byte) (int, error) {
+	r.packets = append(r.packets, string(p))
+	return len(p), nil
+}
+
+func TestWriter(t *testing.T) {
+	r := &packetRecorder{}
+	bw := NewWriter(r, 10)
+
+	for _, line := range []string{"aaa\n", "bbb\n", "ccc\n", "0123456789abc\n", "d\n"} {
+		n, err := io.WriteString(bw, line)
+		require.NoError(t, err)
+		require.Equal(t, len(line), n)
+	}
+	require.Equal(t, []string{"aaa\nbbb\n", "ccc\n", "0123456789abc\n"}, r.packets)
+
+	require.NoError(t, bw.Flush())
+	require.NoError(t, bw.Flush())
+	require.Equal(t, []string{"aaa\nbbb\n", "ccc\n", "0123456789abc\n", "d\n"}, r.packets)
+}
+
+func TestSendLoop(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ticks := make(chan time.Time)
+	writeTo := func(w io.Writer) (int64, error) {
+		var count int64
+		for _, line := range []string{"first:1|c\n", "second:2|c\n", "third:3|c\n"} {
+			n, err := io.WriteString(w, line)
+			count += int64(n)
+			if err != nil {
+				return count, err
+			}
+		}
+		return count, nil
+	}
+	go SendLoop(ctx, ticks, "udp", conn.LocalAddr().String(), 22, writeTo, log.NewNopLogger())
+
+	var packets []string
+	for len(packets) < 2 {
+		select {
+		case ticks <- time.Now():
+		default:
+		}
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			continue
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+	require.Equal(t, []string{"first:1|c\nsecond:2|c\n", "third:3|c\n"}, packets)
+}
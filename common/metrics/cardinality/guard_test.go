@@ -0,0 +1,46 @@
+package cardinality
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardFreeLabelIsNotRecorded(t *testing.T) {
+	inner := &metricsfakes.Provider{}
+	violations := &metricsfakes.Counter{}
+	violations.SetWithReturns(&metricsfakes.Counter{})
+	root := &metricsfakes.Counter{}
+	root.SetWithReturns(&metricsfakes.Counter{})
+	inner.SetNewCounterStub(func(opts metrics.CounterOpts) metrics.Counter {
+		if opts.Name == ViolationsOpts.Name {
+			return violations
+		}
+		return root
+	})
+	p := NewProvider(inner, Config{Logger: &metricsfakes.Logger{}})
+
+	counter := p.NewCounter(metrics.CounterOpts{
+		Name:               "requests",
+		LabelNames:         []string{"method", "tx"},
+		AllowedLabelValues: map[string][]string{"method": {"GET"}},
+	}).(*Counter)
+	for i := 0; i < 10000; i++ {
+		counter.With("method", "GET", "tx", fmt.Sprint(i)).Add(1)
+	}
+	require.Equal(t, 10000, root.WithCallCount())
+	require.Equal(t, []string{"method", "GET", "tx", "9999"}, root.WithArgsForCall(9999))
+	require.Empty(t, counter.guard.seen)
+	require.Empty(t, counter.guard.children)
+
+	// 所有的标签都受 AllowedLabelValues 限制时，标签值组合是有限的，被包装的指标会被缓存。
+	for i := 0; i < 10; i++ {
+		counter.With("method", fmt.Sprint(i)).Add(1)
+	}
+	require.Equal(t, 10001, root.WithCallCount())
+	require.Len(t, counter.guard.children, 1)
+	require.Empty(t, counter.guard.seen)
+}
@@ -0,0 +1,290 @@
+package cardinality
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/namer"
+)
+
+// OverflowValue 替换超出限制的标签值。
+const OverflowValue = "__overflow__"
+
+const (
+	reasonMaxCardinality = "max_cardinality"
+	reasonDisallowed     = "disallowed_value"
+)
+
+// ViolationsOpts 统计标签值因为超出限制而被替换的次数，reason 是 "max_cardinality" 或 "disallowed_value"。
+var ViolationsOpts = metrics.CounterOpts{
+	Namespace:    "metrics",
+	Name:         "label_cardinality_violations",
+	Help:         "Number of observations whose label values were collapsed into " + OverflowValue,
+	LabelNames:   []string{"metric", "reason"},
+	LabelHelp:    map[string]string{"metric": "fully qualified name of the metric", "reason": "max_cardinality or disallowed_value"},
+	StatsdFormat: "%{#fqname}.%{metric}.%{reason}",
+}
+
+type Config struct {
+	// MaxCardinality 是 Opts 没有给出 MaxCardinality 时使用的限制，为 0 时不限制。
+	MaxCardinality int
+	// Logger 输出标签值超出限制的告警，默认为名为 "metrics.cardinality" 的 clogging 日志记录器。
+	Logger metrics.Logger
+}
+
+// Provider 包装另一个 metrics.Provider，按照 Opts 里的 MaxCardinality 和 AllowedLabelValues 检查 With 传入的标签值：
+//   - 不在 AllowedLabelValues 里的标签值会被替换为 OverflowValue；
+//   - 一个指标的标签值组合达到 MaxCardinality 之后，新出现的组合的所有标签值都会被替换为 OverflowValue，
+//     所以超出限制的观测值全部记录在同一个时间序列里。
+//
+// 每次替换都会使 ViolationsOpts 计数器加一，每个指标第一次因为某个原因被替换时还会输出一条告警。
+type Provider struct {
+	provider   metrics.Provider
+	max        int
+	logger     metrics.Logger
+	violations metrics.Counter
+	warnOnce   metrics.WarnOnce
+}
+
+func NewProvider(provider metrics.Provider, c Config) *Provider {
+	p := &Provider{
+		provider: provider,
+		max:      c.MaxCardinality,
+		logger:   c.Logger,
+	}
+	if p.logger == nil {
+		p.logger = clogging.MustGetLogger("metrics.cardinality")
+	}
+	p.violations = provider.NewCounter(ViolationsOpts)
+	return p
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	g := p.newGuard(namer.NewCounterNamer(opts).FullyQualifiedName(), opts.LabelNames, opts.MaxCardinality, opts.AllowedLabelValues)
+	return &Counter{guard: g, root: p.provider.NewCounter(opts)}
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	g := p.newGuard(namer.NewGaugeNamer(opts).FullyQualifiedName(), opts.LabelNames, opts.MaxCardinality, opts.AllowedLabelValues)
+	return &Gauge{guard: g, root: p.provider.NewGauge(opts)}
+}
+
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	g := p.newGuard(namer.NewHistogramNamer(opts).FullyQualifiedName(), opts.LabelNames, opts.MaxCardinality, opts.AllowedLabelValues)
+	return &Histogram{guard: g, root: p.provider.NewHistogram(opts)}
+}
+
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	g := p.newGuard(namer.NewSummaryNamer(opts).FullyQualifiedName(), opts.LabelNames, opts.MaxCardinality, opts.AllowedLabelValues)
+	return &Summary{guard: g, root: p.provider.NewSummary(opts)}
+}
+
+func (p *Provider) newGuard(name string, labelNames []string, max int, allowedValues map[string][]string) *guard {
+	if max == 0 {
+		max = p.max
+	}
+	g := &guard{
+		provider:   p,
+		name:       name,
+		labelNames: labelNames,
+		max:        max,
+		allowed:    map[string]map[string]struct{}{},
+		seen:       map[string]struct{}{},
+		children:   map[string]interface{}{},
+	}
+	for label, values := range allowedValues {
+		g.allowed[label] = map[string]struct{}{}
+		for _, v := range values {
+			g.allowed[label][v] = struct{}{}
+		}
+	}
+	return g
+}
+
+func (p *Provider) violation(name, reason string) {
+	p.violations.With("metric", name, "reason", reason).Add(1)
+
+	p.warnOnce.Warnf(p.logger, name+"\xff"+reason, "Label values of metric %s exceed the %s limit and are collapsed into %s", name, strings.ReplaceAll(reason, "_", " "), OverflowValue)
+}
+
+// guard 记录一个指标出现过的标签值组合，并缓存每个组合对应的被包装的指标。
+type guard struct {
+	provider   *Provider
+	name       string
+	labelNames []string
+	max        int
+	allowed    map[string]map[string]struct{}
+
+	mutex    sync.Mutex
+	seen     map[string]struct{}
+	children map[string]interface{}
+}
+
+// child 返回 labelValues 经过检查之后对应的被包装的指标，newChild 用检查之后的标签值创建该指标。
+func (g *guard) child(labelValues []string, newChild func(labelValues ...string) interface{}) interface{} {
+	if g.max <= 0 && len(g.allowed) == 0 {
+		return newChild(labelValues...)
+	}
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues, "unknown")
+	}
+	guarded := make([]string, len(labelValues))
+	copy(guarded, labelValues)
+
+	disallowed := false
+	allGuarded := true
+	for i := 0; i < len(guarded); i += 2 {
+		allowed, ok := g.allowed[guarded[i]]
+		if !ok {
+			allGuarded = false
+			continue
+		}
+		if _, ok := allowed[guarded[i+1]]; !ok {
+			guarded[i+1] = OverflowValue
+			disallowed = true
+		}
+	}
+	// 没有 MaxCardinality 时，只有所有的标签都在 AllowedLabelValues 里，标签值组合的个数才是有限的。其他情况下
+	// 不记录也不缓存标签值组合，以免占用的内存随着不受限制的标签值无限增长。
+	bounded := g.max > 0 || allGuarded
+	key := g.key(guarded)
+
+	g.mutex.Lock()
+	overflow := false
+	if _, ok := g.seen[key]; !ok && g.max > 0 {
+		if len(g.seen) >= g.max {
+			overflow = true
+			for i := 1; i < len(guarded); i += 2 {
+				guarded[i] = OverflowValue
+			}
+			key = g.key(guarded)
+		} else {
+			g.seen[key] = struct{}{}
+		}
+	}
+	c, ok := g.children[key]
+	if !ok && bounded {
+		c = newChild(guarded...)
+		g.children[key] = c
+	}
+	g.mutex.Unlock()
+
+	if c == nil {
+		c = newChild(guarded...)
+	}
+	if disallowed {
+		g.provider.violation(g.name, reasonDisallowed)
+	}
+	if overflow {
+		g.provider.violation(g.name, reasonMaxCardinality)
+	}
+	return c
+}
+
+// key 按照 LabelNames 的顺序把标签值连接起来，没有给出的标签的值为空字符串。
+func (g *guard) key(labelValues []string) string {
+	values := make([]string, len(g.labelNames))
+	for i := 0; i < len(labelValues); i += 2 {
+		for j, name := range g.labelNames {
+			if name == labelValues[i] {
+				values[j] = labelValues[i+1]
+			}
+		}
+	}
+	return strings.Join(values, "\xff")
+}
+
+type Counter struct {
+	guard *guard
+	root  metrics.Counter
+	lvs   []string
+}
+
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return &Counter{guard: c.guard, root: c.root, lvs: append(c.lvs[:len(c.lvs):len(c.lvs)], labelValues...)}
+}
+
+func (c *Counter) counter() metrics.Counter {
+	if len(c.lvs) == 0 {
+		return c.root
+	}
+	return c.guard.child(c.lvs, func(lvs ...string) interface{} { return c.root.With(lvs...) }).(metrics.Counter)
+}
+
+func (c *Counter) Add(delta float64) {
+	c.counter().Add(delta)
+}
+
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.counter().AddWithExemplar(delta, exemplar)
+}
+
+type Gauge struct {
+	guard *guard
+	root  metrics.Gauge
+	lvs   []string
+}
+
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return &Gauge{guard: g.guard, root: g.root, lvs: append(g.lvs[:len(g.lvs):len(g.lvs)], labelValues...)}
+}
+
+func (g *Gauge) gauge() metrics.Gauge {
+	if len(g.lvs) == 0 {
+		return g.root
+	}
+	return g.guard.child(g.lvs, func(lvs ...string) interface{} { return g.root.With(lvs...) }).(metrics.Gauge)
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.gauge().Add(delta)
+}
+
+func (g *Gauge) Set(value float64) {
+	g.gauge().Set(value)
+}
+
+type Histogram struct {
+	guard *guard
+	root  metrics.Histogram
+	lvs   []string
+}
+
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return &Histogram{guard: h.guard, root: h.root, lvs: append(h.lvs[:len(h.lvs):len(h.lvs)], labelValues...)}
+}
+
+func (h *Histogram) histogram() metrics.Histogram {
+	if len(h.lvs) == 0 {
+		return h.root
+	}
+	return h.guard.child(h.lvs, func(lvs ...string) interface{} { return h.root.With(lvs...) }).(metrics.Histogram)
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.histogram().Observe(value)
+}
+
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	h.histogram().ObserveWithExemplar(value, exemplar)
+}
+
+type Summary struct {
+	guard *guard
+	root  metrics.Summary
+	lvs   []string
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	return &Summary{guard: s.guard, root: s.root, lvs: append(s.lvs[:len(s.lvs):len(s.lvs)], labelValues...)}
+}
+
+func (s *Summary) Observe(value float64) {
+	if len(s.lvs) == 0 {
+		s.root.Observe(value)
+		return
+	}
+	s.guard.child(s.lvs, func(lvs ...string) interface{} { return s.root.With(lvs...) }).(metrics.Summary).Observe(value)
+}
@@ -0,0 +1,98 @@
+package cardinality_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/cardinality"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/232425wxy/chainer/common/metrics/otlp"
+	"github.com/stretchr/testify/require"
+)
+
+// values 返回指标 name 的每个数据点的属性和值，属性按照 LabelNames 的顺序以逗号连接。
+func values(t *testing.T, p *otlp.Provider, name string) map[string]float64 {
+	m, ok := p.Collect().Metric(name)
+	require.True(t, ok)
+	result := map[string]float64{}
+	switch data := m.Data.(type) {
+	case otlp.SumData:
+		for _, dp := range data.DataPoints {
+			result[attributes(dp.Attributes)] = dp.Value
+		}
+	case otlp.GaugeData:
+		for _, dp := range data.DataPoints {
+			result[attributes(dp.Attributes)] = dp.Value
+		}
+	case otlp.HistogramData:
+		for _, dp := range data.DataPoints {
+			result[attributes(dp.Attributes)] = float64(dp.Count)
+		}
+	}
+	return result
+}
+
+func attributes(attrs []otlp.Attribute) string {
+	s := ""
+	for i, attr := range attrs {
+		if i > 0 {
+			s += ","
+		}
+		s += attr.Value
+	}
+	return s
+}
+
+func TestMaxCardinality(t *testing.T) {
+	inner := otlp.NewProvider(otlp.Config{})
+	logger := &metricsfakes.Logger{}
+	p := cardinality.NewProvider(inner, cardinality.Config{Logger: logger})
+
+	counter := p.NewCounter(metrics.CounterOpts{Namespace: "gossip", Name: "messages", LabelNames: []string{"peer", "type"}, MaxCardinality: 2})
+	counter.With("peer", "a", "type", "data").Add(1)
+	counter.With("peer", "b").With("type", "data").Add(1)
+	counter.With("peer", "a", "type", "data").Add(1)
+	counter.With("peer", "c", "type", "data").Add(1)
+	counter.With("peer", "d", "type", "pull").AddWithExemplar(1, nil)
+
+	require.Equal(t, map[string]float64{"a,data": 2, "b,data": 1, "__overflow__,__overflow__": 2}, values(t, inner, "gossip.messages"))
+	require.Equal(t, map[string]float64{"gossip.messages,max_cardinality": 2}, values(t, inner, "metrics.label_cardinality_violations"))
+	require.Equal(t, []string{"Label values of metric gossip.messages exceed the max cardinality limit and are collapsed into __overflow__"}, logger.Messages())
+}
+
+func TestAllowedLabelValues(t *testing.T) {
+	inner := otlp.NewProvider(otlp.Config{})
+	logger := &metricsfakes.Logger{}
+	p := cardinality.NewProvider(inner, cardinality.Config{Logger: logger, MaxCardinality: 10})
+
+	histogram := p.NewHistogram(metrics.HistogramOpts{
+		Name:               "request_duration",
+		LabelNames:         []string{"method", "peer"},
+		AllowedLabelValues: map[string][]string{"method": {"GET", "PUT"}},
+	})
+	histogram.With("method", "GET", "peer", "a").Observe(1)
+	histogram.With("method", "DELETE", "peer", "a").ObserveWithExemplar(1, nil)
+	histogram.With("method", "PATCH", "peer", "a").Observe(1)
+
+	gauge := p.NewGauge(metrics.GaugeOpts{Name: "height", LabelNames: []string{"channel"}, MaxCardinality: 1})
+	gauge.With("channel", "a").Set(1)
+	gauge.With("channel", "b").Set(2)
+	gauge.With("channel", "c").Add(3)
+	gauge.Set(4)
+
+	summary := p.NewSummary(metrics.SummaryOpts{Name: "latency", LabelNames: []string{"tx"}})
+	for i := 0; i < 12; i++ {
+		summary.With("tx", fmt.Sprint(i)).Observe(1)
+	}
+
+	require.Equal(t, map[string]float64{"GET,a": 1, "__overflow__,a": 2}, values(t, inner, "request_duration"))
+	require.Equal(t, map[string]float64{"a": 1, "__overflow__": 5, "": 4}, values(t, inner, "height"))
+	require.Len(t, values(t, inner, "latency"), 11, "the default limit applies when the opts do not set one")
+	require.Equal(t, map[string]float64{
+		"request_duration,disallowed_value": 2,
+		"height,max_cardinality":            2,
+		"latency,max_cardinality":           2,
+	}, values(t, inner, "metrics.label_cardinality_violations"))
+	require.Len(t, logger.Messages(), 3)
+}
@@ -0,0 +1,291 @@
+// gencatalog 扫描源码里以包级变量定义的 metrics.CounterOpts、GaugeOpts、HistogramOpts 和 SummaryOpts，生成指标目录：
+//
+//	go run ./common/metrics/cmd/gencatalog -root . -format markdown -o docs/metrics.md
+//
+// 测试文件、vendor 和 testdata 目录会被跳过。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/registry"
+)
+
+const metricsImportPath = "github.com/232425wxy/chainer/common/metrics"
+
+func main() {
+	root := flag.String("root", ".", "root directory of the source tree to scan")
+	format := flag.String("format", "markdown", "output format, markdown or csv")
+	output := flag.String("o", "", "output file, standard output if empty")
+	flag.Parse()
+
+	if err := run(*root, *format, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(root, format, output string) error {
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return generate(w, root, format)
+}
+
+// generate 扫描 root 下的源码，把指标目录以 format 格式写到 w。
+func generate(w io.Writer, root, format string) error {
+	opts, err := scan(root)
+	if err != nil {
+		return err
+	}
+	r := registry.NewRegistry()
+	r.Add(opts...)
+	if err := r.Check(); err != nil {
+		return err
+	}
+
+	switch format {
+	case "markdown":
+		if _, err := io.WriteString(w, "<!-- 由 common/metrics/cmd/gencatalog 生成，请不要手动修改。 -->\n\n# Metrics\n\n"); err != nil {
+			return err
+		}
+		return registry.WriteMarkdown(w, r.Entries())
+	case "csv":
+		return registry.WriteCSV(w, r.Entries())
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// scan 返回 root 下每个目录里以包级变量定义的指标选项。
+func scan(root string) ([]interface{}, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []interface{}
+	for _, dir := range dirs {
+		o, err := scanDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, o...)
+	}
+	return opts, nil
+}
+
+func scanDir(dir string) ([]interface{}, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	s := &scanner{fset: fset, consts: map[string]ast.Expr{}}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.CONST {
+				for _, spec := range gd.Specs {
+					vs := spec.(*ast.ValueSpec)
+					for i, name := range vs.Names {
+						if i < len(vs.Values) {
+							s.consts[name.Name] = vs.Values[i]
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var opts []interface{}
+	for _, f := range files {
+		alias := metricsAlias(f)
+		if alias == "" {
+			continue
+		}
+		for _, lit := range packageLiterals(f) {
+			sel, ok := lit.Type.(*ast.SelectorExpr)
+			if !ok {
+				continue
+			}
+			if x, ok := sel.X.(*ast.Ident); !ok || x.Name != alias {
+				continue
+			}
+			o, err := s.opts(sel.Sel.Name, lit)
+			if err != nil {
+				return nil, err
+			}
+			if o != nil {
+				opts = append(opts, o)
+			}
+		}
+	}
+	return opts, nil
+}
+
+// packageLiterals 返回 f 里作为包级变量初始值的复合字面量，指标选项按照惯例都以这种方式定义。
+func packageLiterals(f *ast.File) []*ast.CompositeLit {
+	var lits []*ast.CompositeLit
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			for _, value := range spec.(*ast.ValueSpec).Values {
+				if lit, ok := value.(*ast.CompositeLit); ok {
+					lits = append(lits, lit)
+				}
+			}
+		}
+	}
+	return lits
+}
+
+// metricsAlias 返回 f 导入 metrics 包时使用的名字，没有导入时返回空字符串。
+func metricsAlias(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == metricsImportPath {
+			if imp.Name != nil {
+				return imp.Name.Name
+			}
+			return "metrics"
+		}
+	}
+	return ""
+}
+
+type scanner struct {
+	fset   *token.FileSet
+	consts map[string]ast.Expr
+}
+
+// opts 把类型为 metrics.<typeName> 的字面量 lit 转换为对应的指标选项，typeName 不是指标选项时返回 nil。
+func (s *scanner) opts(typeName string, lit *ast.CompositeLit) (interface{}, error) {
+	var namespace, subsystem, name, help, statsdFormat string
+	var labelNames []string
+	fields := map[string]*string{"Namespace": &namespace, "Subsystem": &subsystem, "Name": &name, "Help": &help, "StatsdFormat": &statsdFormat}
+
+	switch typeName {
+	case "CounterOpts", "GaugeOpts", "HistogramOpts", "SummaryOpts":
+	default:
+		return nil, nil
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, fmt.Errorf("%s: metric options must use keyed fields", s.fset.Position(elt.Pos()))
+		}
+		key := kv.Key.(*ast.Ident).Name
+		var err error
+		if field, ok := fields[key]; ok {
+			*field, err = s.eval(kv.Value)
+		} else if key == "LabelNames" {
+			labelNames, err = s.evalSlice(kv.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot evaluate field %s of %s: %s", s.fset.Position(kv.Pos()), key, typeName, err)
+		}
+	}
+
+	switch typeName {
+	case "CounterOpts":
+		return metrics.CounterOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, LabelNames: labelNames, StatsdFormat: statsdFormat}, nil
+	case "GaugeOpts":
+		return metrics.GaugeOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, LabelNames: labelNames, StatsdFormat: statsdFormat}, nil
+	case "HistogramOpts":
+		return metrics.HistogramOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, LabelNames: labelNames, StatsdFormat: statsdFormat}, nil
+	default:
+		return metrics.SummaryOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, LabelNames: labelNames, StatsdFormat: statsdFormat}, nil
+	}
+}
+
+// eval 计算由字符串字面量、同一个包里的常量和 "+" 组成的表达式。
+func (s *scanner) eval(expr ast.Expr) (string, error) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return strconv.Unquote(e.Value)
+		}
+	case *ast.ParenExpr:
+		return s.eval(e.X)
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			x, err := s.eval(e.X)
+			if err != nil {
+				return "", err
+			}
+			y, err := s.eval(e.Y)
+			if err != nil {
+				return "", err
+			}
+			return x + y, nil
+		}
+	case *ast.Ident:
+		if value, ok := s.consts[e.Name]; ok {
+			return s.eval(value)
+		}
+	}
+	return "", fmt.Errorf("not a constant string expression")
+}
+
+func (s *scanner) evalSlice(expr ast.Expr) ([]string, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("not a slice literal")
+	}
+	values := make([]string, 0, len(lit.Elts))
+	for _, elt := range lit.Elts {
+		v, err := s.eval(elt)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
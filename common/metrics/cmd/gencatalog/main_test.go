@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCatalogIsCurrent 在 docs/metrics.md 没有随着指标的变化重新生成时失败。
+func TestCatalogIsCurrent(t *testing.T) {
+	root := filepath.Join("..", "..", "..", "..")
+	expected, err := os.ReadFile(filepath.Join(root, "docs", "metrics.md"))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, generate(buf, root, "markdown"))
+	require.Equal(t, string(expected), buf.String(), "docs/metrics.md is out of date, regenerate it with: go run ./common/metrics/cmd/gencatalog -root . -o docs/metrics.md")
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "opts.go"), []byte(`package sample
+
+import m "github.com/232425wxy/chainer/common/metrics"
+
+const prefix = "Number of "
+
+var (
+	RequestsOpts = m.CounterOpts{
+		Namespace:    "sample",
+		Name:         "requests",
+		Help:         prefix + "requests",
+		LabelNames:   []string{"code"},
+		StatsdFormat: "%{#fqname}.%{code}",
+	}
+	LatencyOpts = m.SummaryOpts{Namespace: "sample", Name: "latency"}
+	other       = []string{"not", "a", "metric"}
+)
+
+func newOpts(name string) m.GaugeOpts {
+	return m.GaugeOpts{Name: name}
+}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "opts_test.go"), []byte(`package sample
+
+import "github.com/232425wxy/chainer/common/metrics"
+
+var testOpts = metrics.GaugeOpts{Name: "test_only"}
+`), 0o644))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, generate(buf, dir, "csv"))
+	require.Equal(t, "name,type,labels,help,statsd_format\n"+
+		"sample.latency,summary,,,\n"+
+		"sample.requests,counter,code,Number of requests,%{#fqname}.%{code}\n", buf.String())
+}
+
+func TestScanErrors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "opts.go"), []byte(`package sample
+
+import "github.com/232425wxy/chainer/common/metrics"
+
+var name = "dynamic"
+
+var DynamicOpts = metrics.CounterOpts{Name: name}
+`), 0o644))
+	err := generate(&bytes.Buffer{}, dir, "markdown")
+	require.EqualError(t, err, filepath.Join(dir, "opts.go")+":7:39: cannot evaluate field Name of CounterOpts: not a constant string expression")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "opts.go"), []byte(`package sample
+
+import "github.com/232425wxy/chainer/common/metrics"
+
+var (
+	CounterOpts = metrics.CounterOpts{Name: "requests"}
+	GaugeOpts   = metrics.GaugeOpts{Name: "requests"}
+)
+`), 0o644))
+	err = generate(&bytes.Buffer{}, dir, "markdown")
+	require.EqualError(t, err, "conflicting metric definitions: metric requests is defined as both a counter and a gauge")
+
+	err = generate(&bytes.Buffer{}, t.TempDir(), "xml")
+	require.EqualError(t, err, `unknown format "xml"`)
+}
@@ -0,0 +1,35 @@
+package disabled
+
+import "github.com/232425wxy/chainer/common/metrics"
+
+// Provider 创建的指标丢弃所有的观测值，包括 With 在内的所有方法都不会分配内存，适合在不需要指标时代替其他提供者。
+// 注意通过接口调用 With("k", "v") 时，调用方构造的可变参数切片仍然会分配在堆上，这与使用哪个提供者无关。
+type Provider struct{}
+
+func (p *Provider) NewCounter(metrics.CounterOpts) metrics.Counter       { return Counter{} }
+func (p *Provider) NewGauge(metrics.GaugeOpts) metrics.Gauge             { return Gauge{} }
+func (p *Provider) NewHistogram(metrics.HistogramOpts) metrics.Histogram { return Histogram{} }
+func (p *Provider) NewSummary(metrics.SummaryOpts) metrics.Summary       { return Summary{} }
+
+type Counter struct{}
+
+func (c Counter) With(...string) metrics.Counter           { return c }
+func (Counter) Add(float64)                                {}
+func (Counter) AddWithExemplar(float64, map[string]string) {}
+
+type Gauge struct{}
+
+func (g Gauge) With(...string) metrics.Gauge { return g }
+func (Gauge) Add(float64)                    {}
+func (Gauge) Set(float64)                    {}
+
+type Histogram struct{}
+
+func (h Histogram) With(...string) metrics.Histogram             { return h }
+func (Histogram) Observe(float64)                                {}
+func (Histogram) ObserveWithExemplar(float64, map[string]string) {}
+
+type Summary struct{}
+
+func (s Summary) With(...string) metrics.Summary { return s }
+func (Summary) Observe(float64)                  {}
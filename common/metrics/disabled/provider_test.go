@@ -0,0 +1,79 @@
+package disabled_test
+
+import (
+	"testing"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	provider metrics.Provider = &disabled.Provider{}
+	// 通过接口调用 With 时，调用方构造的可变参数切片总是会逃逸到堆上，与提供者无关，所以这里使用预先构造的切片，
+	// 只衡量提供者本身的开销。
+	labelValues = []string{"level", "info"}
+)
+
+func TestZeroAllocations(t *testing.T) {
+	counter := provider.NewCounter(metrics.CounterOpts{Name: "counter", LabelNames: []string{"level"}})
+	gauge := provider.NewGauge(metrics.GaugeOpts{Name: "gauge", LabelNames: []string{"level"}})
+	histogram := provider.NewHistogram(metrics.HistogramOpts{Name: "histogram", LabelNames: []string{"level"}})
+	summary := provider.NewSummary(metrics.SummaryOpts{Name: "summary", LabelNames: []string{"level"}})
+
+	allocs := testing.AllocsPerRun(100, func() {
+		counter.With(labelValues...).Add(1)
+		counter.With(labelValues...).AddWithExemplar(1, nil)
+		gauge.With(labelValues...).Set(1)
+		gauge.With(labelValues...).Add(1)
+		histogram.With(labelValues...).Observe(1)
+		histogram.With(labelValues...).ObserveWithExemplar(1, nil)
+		summary.With(labelValues...).Observe(1)
+		provider.NewCounter(metrics.CounterOpts{Name: "counter"}).Add(1)
+	})
+	require.Zero(t, allocs)
+}
+
+func BenchmarkCounter(b *testing.B) {
+	counter := provider.NewCounter(metrics.CounterOpts{Name: "counter", LabelNames: []string{"level"}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		counter.With(labelValues...).Add(1)
+	}
+	b.StopTimer()
+}
+
+func BenchmarkGauge(b *testing.B) {
+	gauge := provider.NewGauge(metrics.GaugeOpts{Name: "gauge", LabelNames: []string{"level"}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gauge.With(labelValues...).Set(1)
+	}
+	b.StopTimer()
+}
+
+func BenchmarkHistogram(b *testing.B) {
+	histogram := provider.NewHistogram(metrics.HistogramOpts{Name: "histogram", LabelNames: []string{"level"}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		histogram.With(labelValues...).Observe(1)
+	}
+	b.StopTimer()
+}
+
+func BenchmarkSummary(b *testing.B) {
+	summary := provider.NewSummary(metrics.SummaryOpts{Name: "summary", LabelNames: []string{"level"}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		summary.With(labelValues...).Observe(1)
+	}
+	b.StopTimer()
+}
@@ -0,0 +1,77 @@
+package dogstatsd
+
+import (
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/namer"
+	kitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/dogstatsd"
+)
+
+// Provider 把指标发送给 DogStatsD。与 statsd 不同，标签不会被编码进指标名里，而是作为 DogStatsD 的标签（"|#k:v"）
+// 发送，指标名是 Namespace、Subsystem 和 Name 以点号连接而成的完全限定名，StatsdFormat 不起作用。
+//
+// Dogstatsd 只在内存里缓存指标，需要定期调用它的 WriteTo 方法，例如通过 batch.SendLoop 发送。
+type Provider struct {
+	DogStatsd *dogstatsd.Dogstatsd
+}
+
+type Counter struct {
+	kitmetrics.Counter
+}
+
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return &Counter{Counter: c.Counter.With(labelValues...)}
+}
+
+// AddWithExemplar 忽略 exemplar，DogStatsD 协议无法携带 exemplar。
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.Add(delta)
+}
+
+type Gauge struct {
+	kitmetrics.Gauge
+}
+
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return &Gauge{Gauge: g.Gauge.With(labelValues...)}
+}
+
+type Histogram struct {
+	kitmetrics.Histogram
+}
+
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return &Histogram{Histogram: h.Histogram.With(labelValues...)}
+}
+
+// ObserveWithExemplar 忽略 exemplar，DogStatsD 协议无法携带 exemplar。
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	h.Observe(value)
+}
+
+// Summary 用 DogStatsD 的 histogram 表示，分位数由 DogStatsD 服务端计算，SummaryOpts 里的 Objectives、MaxAge 和
+// AgeBuckets 不起作用。
+type Summary struct {
+	kitmetrics.Histogram
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	return &Summary{Histogram: s.Histogram.With(labelValues...)}
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	return &Counter{Counter: p.DogStatsd.NewCounter(namer.NewCounterNamer(opts).FullyQualifiedName(), 1)}
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	return &Gauge{Gauge: p.DogStatsd.NewGauge(namer.NewGaugeNamer(opts).FullyQualifiedName())}
+}
+
+// NewHistogram 创建 DogStatsD 的 histogram，桶由 DogStatsD 服务端决定，HistogramOpts 里的 Buckets 不起作用。
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	return &Histogram{Histogram: p.DogStatsd.NewHistogram(namer.NewHistogramNamer(opts).FullyQualifiedName(), 1)}
+}
+
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	return &Summary{Histogram: p.DogStatsd.NewHistogram(namer.NewSummaryNamer(opts).FullyQualifiedName(), 1)}
+}
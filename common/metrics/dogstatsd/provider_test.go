@@ -0,0 +1,45 @@
+package dogstatsd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/go-kit/kit/metrics/dogstatsd"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	d := dogstatsd.New("chainer.", log.NewNopLogger())
+	p := &Provider{DogStatsd: d}
+
+	counter := p.NewCounter(metrics.CounterOpts{
+		Namespace:    "grpc",
+		Subsystem:    "server",
+		Name:         "requests_completed",
+		LabelNames:   []string{"grpc_method", "code"},
+		StatsdFormat: "%{#fqname}.%{grpc_method}.%{code}",
+	})
+	counter.With("grpc_method", "Check", "code", "OK").Add(1)
+	counter.With("grpc_method", "Check").With("code", "OK").AddWithExemplar(2, map[string]string{"trace_id": "abc"})
+
+	gauge := p.NewGauge(metrics.GaugeOpts{Namespace: "ledger", Name: "height", LabelNames: []string{"channel"}})
+	gauge.With("channel", "mychannel").Set(7)
+
+	histogram := p.NewHistogram(metrics.HistogramOpts{Name: "commit_duration"})
+	histogram.ObserveWithExemplar(0.5, map[string]string{"trace_id": "abc"})
+	p.NewSummary(metrics.SummaryOpts{Name: "validate_duration"}).Observe(1.5)
+
+	buf := &bytes.Buffer{}
+	_, err := d.WriteTo(buf)
+	require.NoError(t, err)
+	// Dogstatsd 写出同一类指标的顺序是不确定的。
+	require.ElementsMatch(t, []string{
+		"chainer.grpc.server.requests_completed:3.000000|c|#grpc_method:Check,code:OK",
+		"chainer.ledger.height:7.000000|g|#channel:mychannel",
+		"chainer.commit_duration:0.500000|h",
+		"chainer.validate_duration:1.500000|h",
+	}, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"))
+}
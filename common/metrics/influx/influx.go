@@ -0,0 +1,206 @@
+package influx
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Influx 在内存里聚合指标，WriteTo 把它们以 InfluxDB 行协议写出：
+//   - counter 写出上次 WriteTo 之后的增量，字段为 count，没有变化的 counter 不写出；
+//   - gauge 写出最新的值，字段为 value，每次 WriteTo 都会写出；
+//   - histogram 和 summary 写出上次 WriteTo 之后的观测值的 count、sum、min、max 和 mean，summary 还会写出
+//     Objectives 里的分位数，例如 p50、p99；没有观测值时不写出。分位数根据最多 maxSamples 个均匀抽样的观测值
+//     计算，观测值更多时它们是近似值。
+//
+// 需要定期调用 WriteTo，例如通过 batch.SendLoop 以 UDP 或 TCP 发送给 InfluxDB 或者 Telegraf。
+type Influx struct {
+	tags []tag
+	now  func() time.Time
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+// New 返回 Influx，tags 会被添加到所有指标上，例如 {"host": "peer0"}。
+func New(tags map[string]string) *Influx {
+	i := &Influx{now: time.Now, series: map[string]*series{}}
+	for k, v := range tags {
+		i.tags = append(i.tags, tag{key: k, value: v})
+	}
+	sort.Slice(i.tags, func(a, b int) bool { return i.tags[a].key < i.tags[b].key })
+	return i
+}
+
+type kind int
+
+const (
+	kindCounter kind = iota
+	kindGauge
+	kindHistogram
+	kindSummary
+)
+
+type tag struct {
+	key   string
+	value string
+}
+
+// maxSamples 是每个 summary 在两次 WriteTo 之间最多保留的观测值个数。
+const maxSamples = 1024
+
+type series struct {
+	kind      kind
+	key       string
+	quantiles []float64
+
+	dirty bool
+	value float64
+	// count、sum、min 和 max 是上次 WriteTo 之后的所有观测值的统计量。
+	count    int
+	sum      float64
+	min, max float64
+	// samples 是用蓄水池抽样从观测值里均匀抽取的样本，只有 summary 需要用它们计算分位数。
+	samples []float64
+}
+
+func (s *series) observe(value float64) {
+	s.count++
+	s.sum += value
+	if s.count == 1 || value < s.min {
+		s.min = value
+	}
+	if s.count == 1 || value > s.max {
+		s.max = value
+	}
+	if len(s.quantiles) == 0 {
+		return
+	}
+	if len(s.samples) < maxSamples {
+		s.samples = append(s.samples, value)
+	} else if i := rand.Intn(s.count); i < maxSamples {
+		s.samples[i] = value
+	}
+}
+
+// record 找到 measurement 和 labels 对应的时间序列，并在加锁的情况下调用 update 更新它。
+func (i *Influx) record(k kind, measurement string, labels map[string]string, quantiles []float64, update func(s *series)) {
+	tags := make([]tag, 0, len(i.tags)+len(labels))
+	tags = append(tags, i.tags...)
+	for key, value := range labels {
+		tags = append(tags, tag{key: key, value: value})
+	}
+	sort.SliceStable(tags, func(a, b int) bool { return tags[a].key < tags[b].key })
+
+	var sb strings.Builder
+	sb.WriteString(escape(measurement, measurementEscaper))
+	for _, t := range tags {
+		if t.value == "" {
+			continue
+		}
+		sb.WriteByte(',')
+		sb.WriteString(escape(t.key, tagEscaper))
+		sb.WriteByte('=')
+		sb.WriteString(escape(t.value, tagEscaper))
+	}
+	key := sb.String()
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	s, ok := i.series[key]
+	if !ok {
+		s = &series{kind: k, key: key, quantiles: quantiles}
+		i.series[key] = s
+	}
+	update(s)
+	s.dirty = true
+}
+
+// WriteTo 把聚合的指标以 InfluxDB 行协议写到 w，每一行调用一次 w.Write。写入失败时本次的指标会被丢弃。
+func (i *Influx) WriteTo(w io.Writer) (int64, error) {
+	i.mutex.Lock()
+	keys := make([]string, 0, len(i.series))
+	for key := range i.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var lines []string
+	timestamp := strconv.FormatInt(i.now().UnixNano(), 10)
+	for _, key := range keys {
+		s := i.series[key]
+		if !s.dirty {
+			continue
+		}
+		lines = append(lines, s.key+" "+s.fields()+" "+timestamp+"\n")
+		switch s.kind {
+		case kindCounter:
+			s.value = 0
+			s.dirty = false
+		case kindHistogram, kindSummary:
+			s.count, s.sum, s.min, s.max = 0, 0, 0, 0
+			s.samples = s.samples[:0]
+			s.dirty = false
+		}
+	}
+	i.mutex.Unlock()
+
+	var count int64
+	for _, line := range lines {
+		n, err := io.WriteString(w, line)
+		count += int64(n)
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func (s *series) fields() string {
+	switch s.kind {
+	case kindCounter:
+		return "count=" + formatFloat(s.value)
+	case kindGauge:
+		return "value=" + formatFloat(s.value)
+	}
+
+	fields := []string{
+		"count=" + strconv.Itoa(s.count) + "i",
+		"sum=" + formatFloat(s.sum),
+		"min=" + formatFloat(s.min),
+		"max=" + formatFloat(s.max),
+		"mean=" + formatFloat(s.sum/float64(s.count)),
+	}
+	sorted := append([]float64(nil), s.samples...)
+	sort.Float64s(sorted)
+	for _, q := range s.quantiles {
+		fields = append(fields, "p"+formatFloat(math.Round(q*1e6)/1e4)+"="+formatFloat(quantile(sorted, q)))
+	}
+	return strings.Join(fields, ",")
+}
+
+// quantile 用最近秩方法计算已排序的 sorted 的 q 分位数。
+func quantile(sorted []float64, q float64) float64 {
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+func escape(s string, r *strings.Replacer) string {
+	return r.Replace(strings.ReplaceAll(s, "\n", " "))
+}
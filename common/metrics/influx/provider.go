@@ -0,0 +1,113 @@
+package influx
+
+import (
+	"sort"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/namer"
+)
+
+// Provider 以 InfluxDB 行协议记录指标，measurement 是 Namespace、Subsystem 和 Name 以点号连接而成的完全限定名，
+// 标签作为 InfluxDB 的 tag 发送，值为空的标签会被省略。With 传入的标签名必须在 LabelNames 里声明过，否则会 panic。
+type Provider struct {
+	Influx *Influx
+}
+
+type Counter struct {
+	influx *Influx
+	namer  *namer.Namer
+	lvs    []string
+}
+
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return &Counter{influx: c.influx, namer: c.namer, lvs: append(c.lvs[:len(c.lvs):len(c.lvs)], labelValues...)}
+}
+
+func (c *Counter) Add(delta float64) {
+	c.influx.record(kindCounter, c.namer.FullyQualifiedName(), c.namer.Labels(c.lvs...), nil, func(s *series) { s.value += delta })
+}
+
+// AddWithExemplar 忽略 exemplar，InfluxDB 行协议无法携带 exemplar。
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.Add(delta)
+}
+
+type Gauge struct {
+	influx *Influx
+	namer  *namer.Namer
+	lvs    []string
+}
+
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return &Gauge{influx: g.influx, namer: g.namer, lvs: append(g.lvs[:len(g.lvs):len(g.lvs)], labelValues...)}
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.influx.record(kindGauge, g.namer.FullyQualifiedName(), g.namer.Labels(g.lvs...), nil, func(s *series) { s.value += delta })
+}
+
+func (g *Gauge) Set(value float64) {
+	g.influx.record(kindGauge, g.namer.FullyQualifiedName(), g.namer.Labels(g.lvs...), nil, func(s *series) { s.value = value })
+}
+
+type Histogram struct {
+	influx *Influx
+	namer  *namer.Namer
+	lvs    []string
+}
+
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return &Histogram{influx: h.influx, namer: h.namer, lvs: append(h.lvs[:len(h.lvs):len(h.lvs)], labelValues...)}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.influx.record(kindHistogram, h.namer.FullyQualifiedName(), h.namer.Labels(h.lvs...), nil, func(s *series) { s.observe(value) })
+}
+
+// ObserveWithExemplar 忽略 exemplar，InfluxDB 行协议无法携带 exemplar。
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	h.Observe(value)
+}
+
+type Summary struct {
+	influx    *Influx
+	namer     *namer.Namer
+	quantiles []float64
+	lvs       []string
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	return &Summary{influx: s.influx, namer: s.namer, quantiles: s.quantiles, lvs: append(s.lvs[:len(s.lvs):len(s.lvs)], labelValues...)}
+}
+
+func (s *Summary) Observe(value float64) {
+	s.influx.record(kindSummary, s.namer.FullyQualifiedName(), s.namer.Labels(s.lvs...), s.quantiles, func(se *series) { se.observe(value) })
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	return &Counter{influx: p.Influx, namer: namer.NewCounterNamer(opts)}
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	return &Gauge{influx: p.Influx, namer: namer.NewGaugeNamer(opts)}
+}
+
+// NewHistogram 创建的 Histogram 在每次写出时汇总这段时间内的观测值，HistogramOpts 里的 Buckets 不起作用。
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	return &Histogram{influx: p.Influx, namer: namer.NewHistogramNamer(opts)}
+}
+
+// NewSummary 创建的 Summary 在每次写出时根据这段时间内抽样的观测值计算分位数，SummaryOpts 里的 MaxAge 和 AgeBuckets
+// 不起作用。
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	objectives := opts.Objectives
+	if objectives == nil {
+		objectives = metrics.DefaultObjectives
+	}
+	quantiles := make([]float64, 0, len(objectives))
+	for q := range objectives {
+		quantiles = append(quantiles, q)
+	}
+	sort.Float64s(quantiles)
+	return &Summary{influx: p.Influx, namer: namer.NewSummaryNamer(opts), quantiles: quantiles}
+}
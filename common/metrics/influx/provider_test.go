@@ -0,0 +1,98 @@
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestInflux(tags map[string]string) *Influx {
+	i := New(tags)
+	i.now = func() time.Time { return time.Unix(1, 0) }
+	return i
+}
+
+func writeLines(t *testing.T, i *Influx) string {
+	buf := &bytes.Buffer{}
+	_, err := i.WriteTo(buf)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+func TestProviderCounterAndGauge(t *testing.T) {
+	i := newTestInflux(map[string]string{"host": "peer0"})
+	p := &Provider{Influx: i}
+
+	counter := p.NewCounter(metrics.CounterOpts{Namespace: "grpc", Subsystem: "server", Name: "requests_completed", LabelNames: []string{"grpc_method", "code"}})
+	counter.With("grpc_method", "Check", "code", "OK").Add(1)
+	counter.With("grpc_method", "Check").With("code", "OK").AddWithExemplar(2, map[string]string{"trace_id": "abc"})
+	counter.With("grpc_method", "Watch").Add(1)
+	require.PanicsWithValue(t, "invalid label name: channel", func() { counter.With("channel", "a").Add(1) })
+
+	gauge := p.NewGauge(metrics.GaugeOpts{Namespace: "ledger", Name: "height", LabelNames: []string{"channel"}})
+	gauge.With("channel", "my channel,1").Set(10)
+	gauge.With("channel", "my channel,1").Add(-3)
+
+	require.Equal(t,
+		"grpc.server.requests_completed,code=OK,grpc_method=Check,host=peer0 count=3 1000000000\n"+
+			"grpc.server.requests_completed,grpc_method=Watch,host=peer0 count=1 1000000000\n"+
+			"ledger.height,channel=my\\ channel\\,1,host=peer0 value=7 1000000000\n",
+		writeLines(t, i))
+
+	// counter 只写出增量，gauge 保留最新的值。
+	counter.With("grpc_method", "Watch").Add(4)
+	require.Equal(t,
+		"grpc.server.requests_completed,grpc_method=Watch,host=peer0 count=4 1000000000\n"+
+			"ledger.height,channel=my\\ channel\\,1,host=peer0 value=7 1000000000\n",
+		writeLines(t, i))
+}
+
+func TestProviderHistogramAndSummary(t *testing.T) {
+	i := newTestInflux(nil)
+	p := &Provider{Influx: i}
+
+	histogram := p.NewHistogram(metrics.HistogramOpts{Name: "commit_duration"})
+	summary := p.NewSummary(metrics.SummaryOpts{Name: "validate_duration", Objectives: map[float64]float64{0.99: 0.001, 0.5: 0.05}})
+	for v := 1; v <= 10; v++ {
+		histogram.Observe(float64(v))
+		summary.Observe(float64(v))
+	}
+	histogram.ObserveWithExemplar(45, map[string]string{"trace_id": "abc"})
+
+	require.Equal(t,
+		"commit_duration count=11i,sum=100,min=1,max=45,mean=9.090909090909092 1000000000\n"+
+			"validate_duration count=10i,sum=55,min=1,max=10,mean=5.5,p50=5,p99=10 1000000000\n",
+		writeLines(t, i))
+	require.Empty(t, writeLines(t, i), "no observations since the last write")
+}
+
+func TestProviderSummaryBoundedSamples(t *testing.T) {
+	i := newTestInflux(nil)
+	p := &Provider{Influx: i}
+
+	histogram := p.NewHistogram(metrics.HistogramOpts{Name: "commit_duration"})
+	summary := p.NewSummary(metrics.SummaryOpts{Name: "validate_duration", Objectives: map[float64]float64{0.5: 0.05}})
+	const n = 100000
+	for v := 1; v <= n; v++ {
+		histogram.Observe(float64(v))
+		summary.Observe(float64(v))
+	}
+	require.Empty(t, i.series["commit_duration"].samples, "histograms do not keep samples")
+	require.Len(t, i.series["validate_duration"].samples, maxSamples)
+
+	lines := writeLines(t, i)
+	require.Contains(t, lines, "commit_duration count=100000i,sum=5000050000,min=1,max=100000,mean=50000.5 1000000000\n")
+	require.Contains(t, lines, "validate_duration count=100000i,sum=5000050000,min=1,max=100000,mean=50000.5,p50=")
+	var p50 float64
+	_, err := fmt.Sscanf(lines[strings.Index(lines, "p50=")+4:], "%g", &p50)
+	require.NoError(t, err)
+	require.InDelta(t, n/2, p50, n/10, "p50 is estimated from a uniform sample")
+
+	summary.Observe(-1)
+	require.Equal(t, "validate_duration count=1i,sum=-1,min=-1,max=-1,mean=-1,p50=-1 1000000000\n", writeLines(t, i))
+}
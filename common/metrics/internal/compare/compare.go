@@ -0,0 +1,41 @@
+// Package compare 提供各个指标提供者在检查重复注册的指标选项是否一致时共用的比较函数。
+package compare
+
+// Strings 判断 a 和 b 是否按顺序包含相同的字符串。
+func Strings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Floats 判断 a 和 b 是否按顺序包含相同的浮点数。
+func Floats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Objectives 判断两组 Summary 的分位数设置是否相同。
+func Objectives(a, b map[float64]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for q, e := range a {
+		if other, ok := b[q]; !ok || other != e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import "sync"
+
+// Logger 输出包装其他提供者的提供者（例如 cardinality、validation 和 multi）产生的告警，*clogging.ChainerLogger 实现了
+// 该接口。
+type Logger interface {
+	Warnf(template string, args ...interface{})
+}
+
+// WarnOnce 对同一个键只输出一次告警，用于避免每次观测都输出相同的告警。WarnOnce 的零值可以直接使用，可以被多个 go 例程
+// 同时使用。
+type WarnOnce struct {
+	mutex  sync.Mutex
+	warned map[string]struct{}
+}
+
+// Warnf 在 key 第一次出现时通过 logger 输出告警，返回是否输出了告警。
+func (w *WarnOnce) Warnf(logger Logger, key, template string, args ...interface{}) bool {
+	w.mutex.Lock()
+	_, warned := w.warned[key]
+	if !warned {
+		if w.warned == nil {
+			w.warned = map[string]struct{}{}
+		}
+		w.warned[key] = struct{}{}
+	}
+	w.mutex.Unlock()
+	if warned {
+		return false
+	}
+	logger.Warnf(template, args...)
+	return true
+}
@@ -11,6 +11,13 @@ type Counter struct {
 	addMutex       sync.RWMutex
 	addArgsForCall []struct{ arg1 float64 }
 
+	AddWithExemplarStub        func(float64, map[string]string)
+	addWithExemplarMutex       sync.RWMutex
+	addWithExemplarArgsForCall []struct {
+		arg1 float64
+		arg2 map[string]string
+	}
+
 	WithStub          func(...string) metrics.Counter
 	withMutex         sync.RWMutex
 	withArgsForCall   []struct{ arg1 []string }
@@ -53,6 +60,41 @@ func (fake *Counter) AddArgsForCall(i int) float64 {
 	return argsForCall.arg1
 }
 
+func (fake *Counter) AddWithExemplar(arg1 float64, arg2 map[string]string) {
+	fake.addWithExemplarMutex.Lock()
+	fake.addWithExemplarArgsForCall = append(fake.addWithExemplarArgsForCall, struct {
+		arg1 float64
+		arg2 map[string]string
+	}{arg1, arg2})
+	fake.recordInvocation("AddWithExemplar", []interface{}{arg1, arg2})
+	fake.addWithExemplarMutex.Unlock()
+	if fake.AddWithExemplarStub != nil {
+		fake.AddWithExemplarStub(arg1, arg2)
+	}
+}
+
+// AddWithExemplarCallCount 返回调用 AddWithExemplar 方法的次数。
+func (fake *Counter) AddWithExemplarCallCount() int {
+	fake.addWithExemplarMutex.RLock()
+	defer fake.addWithExemplarMutex.RUnlock()
+	return len(fake.addWithExemplarArgsForCall)
+}
+
+// AddWithExemplarCalls 设置 AddWithExemplarStub，func(float64, map[string]string)。
+func (fake *Counter) AddWithExemplarCalls(stub func(float64, map[string]string)) {
+	fake.addWithExemplarMutex.Lock()
+	defer fake.addWithExemplarMutex.Unlock()
+	fake.AddWithExemplarStub = stub
+}
+
+// AddWithExemplarArgsForCall 返回第 i+1 次调用 AddWithExemplar 方法传入的参数，float64 和 map[string]string。
+func (fake *Counter) AddWithExemplarArgsForCall(i int) (float64, map[string]string) {
+	fake.addWithExemplarMutex.RLock()
+	defer fake.addWithExemplarMutex.RUnlock()
+	argsForCall := fake.addWithExemplarArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *Counter) With(arg1 ...string) metrics.Counter {
 	fake.withMutex.Lock()
 	ret, specifiedReturn := fake.withReturnsOnCall[len(fake.withArgsForCall)]
@@ -113,6 +155,8 @@ func (fake *Counter) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.addMutex.RLock()
 	defer fake.addMutex.RUnlock()
+	fake.addWithExemplarMutex.RLock()
+	defer fake.addWithExemplarMutex.RUnlock()
 	fake.withMutex.RLock()
 	defer fake.withMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,131 @@
+package metricsfakes
+
+import (
+	"sync"
+
+	"github.com/232425wxy/chainer/common/metrics"
+)
+
+type Gauge struct {
+	AddStub        func(float64)
+	addMutex       sync.RWMutex
+	addArgsForCall []struct{ arg1 float64 }
+
+	SetStub        func(float64)
+	setMutex       sync.RWMutex
+	setArgsForCall []struct{ arg1 float64 }
+
+	WithStub          func(...string) metrics.Gauge
+	withMutex         sync.RWMutex
+	withArgsForCall   []struct{ arg1 []string }
+	withReturns       struct{ result1 metrics.Gauge }
+	withReturnsOnCall map[int]struct{ result1 metrics.Gauge }
+
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Gauge) Add(arg1 float64) {
+	fake.addMutex.Lock()
+	fake.addArgsForCall = append(fake.addArgsForCall, struct{ arg1 float64 }{arg1})
+	fake.recordInvocation("Add", []interface{}{arg1})
+	fake.addMutex.Unlock()
+	if fake.AddStub != nil {
+		fake.AddStub(arg1)
+	}
+}
+
+// AddCallCount 返回调用 Add 方法的次数。
+func (fake *Gauge) AddCallCount() int {
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	return len(fake.addArgsForCall)
+}
+
+// AddArgsForCall 返回第 i+1 次调用 Add 方法传入的参数，float64。
+func (fake *Gauge) AddArgsForCall(i int) float64 {
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	return fake.addArgsForCall[i].arg1
+}
+
+func (fake *Gauge) Set(arg1 float64) {
+	fake.setMutex.Lock()
+	fake.setArgsForCall = append(fake.setArgsForCall, struct{ arg1 float64 }{arg1})
+	fake.recordInvocation("Set", []interface{}{arg1})
+	fake.setMutex.Unlock()
+	if fake.SetStub != nil {
+		fake.SetStub(arg1)
+	}
+}
+
+// SetCallCount 返回调用 Set 方法的次数。
+func (fake *Gauge) SetCallCount() int {
+	fake.setMutex.RLock()
+	defer fake.setMutex.RUnlock()
+	return len(fake.setArgsForCall)
+}
+
+// SetArgsForCall 返回第 i+1 次调用 Set 方法传入的参数，float64。
+func (fake *Gauge) SetArgsForCall(i int) float64 {
+	fake.setMutex.RLock()
+	defer fake.setMutex.RUnlock()
+	return fake.setArgsForCall[i].arg1
+}
+
+func (fake *Gauge) With(arg1 ...string) metrics.Gauge {
+	fake.withMutex.Lock()
+	ret, specifiedReturn := fake.withReturnsOnCall[len(fake.withArgsForCall)]
+	fake.withArgsForCall = append(fake.withArgsForCall, struct{ arg1 []string }{arg1})
+	fake.recordInvocation("With", []interface{}{arg1})
+	fake.withMutex.Unlock()
+	if fake.WithStub != nil {
+		return fake.WithStub(arg1...)
+	}
+	if specifiedReturn {
+		return ret.result1
+	}
+	return fake.withReturns.result1
+}
+
+// WithCallCount 返回 With 方法被调用的次数。
+func (fake *Gauge) WithCallCount() int {
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	return len(fake.withArgsForCall)
+}
+
+// WithArgsForCall 返回第 i+1 次调用 With 方法传入的参数，[]string。
+func (fake *Gauge) WithArgsForCall(i int) []string {
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	return fake.withArgsForCall[i].arg1
+}
+
+func (fake *Gauge) SetWithReturns(result1 metrics.Gauge) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = nil
+	fake.withReturns = struct{ result1 metrics.Gauge }{result1}
+}
+
+func (fake *Gauge) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Gauge) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ metrics.Gauge = new(Gauge)
@@ -0,0 +1,181 @@
+package metricsfakes
+
+import (
+	"sync"
+
+	"github.com/232425wxy/chainer/common/metrics"
+)
+
+type Histogram struct {
+	ObserveStub        func(float64)
+	observeMutex       sync.RWMutex
+	observeArgsForCall []struct{ arg1 float64 }
+
+	ObserveWithExemplarStub        func(float64, map[string]string)
+	observeWithExemplarMutex       sync.RWMutex
+	observeWithExemplarArgsForCall []struct {
+		arg1 float64
+		arg2 map[string]string
+	}
+
+	WithStub          func(...string) metrics.Histogram
+	withMutex         sync.RWMutex
+	withArgsForCall   []struct{ arg1 []string }
+	withReturns       struct{ result1 metrics.Histogram }
+	withReturnsOnCall map[int]struct{ result1 metrics.Histogram }
+
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Histogram) Observe(arg1 float64) {
+	fake.observeMutex.Lock()
+	fake.observeArgsForCall = append(fake.observeArgsForCall, struct{ arg1 float64 }{arg1})
+	fake.recordInvocation("Observe", []interface{}{arg1})
+	fake.observeMutex.Unlock()
+	if fake.ObserveStub != nil {
+		fake.ObserveStub(arg1)
+	}
+}
+
+// ObserveCallCount 返回调用 Observe 方法的次数。
+func (fake *Histogram) ObserveCallCount() int {
+	fake.observeMutex.Lock()
+	defer fake.observeMutex.Unlock()
+	return len(fake.observeArgsForCall)
+}
+
+// ObserveCalls 设置 ObserveStub，func(float64)。
+func (fake *Histogram) ObserveCalls(stub func(float64)) {
+	fake.observeMutex.Lock()
+	defer fake.observeMutex.Unlock()
+	fake.ObserveStub = stub
+}
+
+// ObserveArgsForCall 返回第 i+1 次调用 Observe 方法传入的参数，float64。
+func (fake *Histogram) ObserveArgsForCall(i int) float64 {
+	fake.observeMutex.RLock()
+	defer fake.observeMutex.RUnlock()
+	argsForCall := fake.observeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Histogram) ObserveWithExemplar(arg1 float64, arg2 map[string]string) {
+	fake.observeWithExemplarMutex.Lock()
+	fake.observeWithExemplarArgsForCall = append(fake.observeWithExemplarArgsForCall, struct {
+		arg1 float64
+		arg2 map[string]string
+	}{arg1, arg2})
+	fake.recordInvocation("ObserveWithExemplar", []interface{}{arg1, arg2})
+	fake.observeWithExemplarMutex.Unlock()
+	if fake.ObserveWithExemplarStub != nil {
+		fake.ObserveWithExemplarStub(arg1, arg2)
+	}
+}
+
+// ObserveWithExemplarCallCount 返回调用 ObserveWithExemplar 方法的次数。
+func (fake *Histogram) ObserveWithExemplarCallCount() int {
+	fake.observeWithExemplarMutex.RLock()
+	defer fake.observeWithExemplarMutex.RUnlock()
+	return len(fake.observeWithExemplarArgsForCall)
+}
+
+// ObserveWithExemplarCalls 设置 ObserveWithExemplarStub，func(float64, map[string]string)。
+func (fake *Histogram) ObserveWithExemplarCalls(stub func(float64, map[string]string)) {
+	fake.observeWithExemplarMutex.Lock()
+	defer fake.observeWithExemplarMutex.Unlock()
+	fake.ObserveWithExemplarStub = stub
+}
+
+// ObserveWithExemplarArgsForCall 返回第 i+1 次调用 ObserveWithExemplar 方法传入的参数，float64 和 map[string]string。
+func (fake *Histogram) ObserveWithExemplarArgsForCall(i int) (float64, map[string]string) {
+	fake.observeWithExemplarMutex.RLock()
+	defer fake.observeWithExemplarMutex.RUnlock()
+	argsForCall := fake.observeWithExemplarArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Histogram) With(arg1 ...string) metrics.Histogram {
+	fake.withMutex.Lock()
+	ret, specifiedReturn := fake.withReturnsOnCall[len(fake.withArgsForCall)]
+	fake.withArgsForCall = append(fake.withArgsForCall, struct{ arg1 []string }{arg1})
+	fake.recordInvocation("With", []interface{}{arg1})
+	fake.withMutex.Unlock()
+	if fake.WithStub != nil {
+		return fake.WithStub(arg1...)
+	}
+	if specifiedReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.withReturns
+	return fakeReturns.result1
+}
+
+// WithCallCount 返回 With 方法被调用的次数。
+func (fake *Histogram) WithCallCount() int {
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	return len(fake.withArgsForCall)
+}
+
+// WithCalls 设置 WithStub，func(...string) metrics.Histogram。
+func (fake *Histogram) SetWithStub(stub func(...string) metrics.Histogram) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = stub
+}
+
+// WithArgsForCall 返回第 i+1 次调用 With 方法传入的参数，[]string。
+func (fake *Histogram) WithArgsForCall(i int) []string {
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	argsForCall := fake.withArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Histogram) SetWithReturns(result1 metrics.Histogram) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = nil
+	fake.withReturns = struct{ result1 metrics.Histogram }{result1}
+}
+
+func (fake *Histogram) SetWithReturnsOnCall(i int, result1 metrics.Histogram) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = nil
+	if fake.withReturnsOnCall == nil {
+		fake.withReturnsOnCall = make(map[int]struct{ result1 metrics.Histogram })
+	}
+	fake.withReturnsOnCall[i] = struct{ result1 metrics.Histogram }{result1}
+}
+
+func (fake *Histogram) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.observeMutex.RLock()
+	defer fake.observeMutex.RUnlock()
+	fake.observeWithExemplarMutex.RLock()
+	defer fake.observeWithExemplarMutex.RUnlock()
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Histogram) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ metrics.Histogram = new(Histogram)
@@ -0,0 +1,79 @@
+package metricsfakes
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/232425wxy/chainer/common/metrics"
+)
+
+type Logger struct {
+	WarnfStub        func(string, ...interface{})
+	warnfMutex       sync.RWMutex
+	warnfArgsForCall []struct {
+		arg1 string
+		arg2 []interface{}
+	}
+
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Logger) Warnf(arg1 string, arg2 ...interface{}) {
+	fake.warnfMutex.Lock()
+	fake.warnfArgsForCall = append(fake.warnfArgsForCall, struct {
+		arg1 string
+		arg2 []interface{}
+	}{arg1, arg2})
+	fake.recordInvocation("Warnf", []interface{}{arg1, arg2})
+	fake.warnfMutex.Unlock()
+	if fake.WarnfStub != nil {
+		fake.WarnfStub(arg1, arg2...)
+	}
+}
+
+// WarnfCallCount 返回调用 Warnf 方法的次数。
+func (fake *Logger) WarnfCallCount() int {
+	fake.warnfMutex.RLock()
+	defer fake.warnfMutex.RUnlock()
+	return len(fake.warnfArgsForCall)
+}
+
+// WarnfArgsForCall 返回第 i+1 次调用 Warnf 方法传入的参数。
+func (fake *Logger) WarnfArgsForCall(i int) (string, []interface{}) {
+	fake.warnfMutex.RLock()
+	defer fake.warnfMutex.RUnlock()
+	return fake.warnfArgsForCall[i].arg1, fake.warnfArgsForCall[i].arg2
+}
+
+// Messages 按照调用的顺序返回每次调用 Warnf 格式化之后的告警。
+func (fake *Logger) Messages() []string {
+	fake.warnfMutex.RLock()
+	defer fake.warnfMutex.RUnlock()
+	var messages []string
+	for _, args := range fake.warnfArgsForCall {
+		messages = append(messages, fmt.Sprintf(args.arg1, args.arg2...))
+	}
+	return messages
+}
+
+func (fake *Logger) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Logger) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ metrics.Logger = new(Logger)
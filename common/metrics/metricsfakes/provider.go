@@ -25,6 +25,12 @@ type Provider struct {
 	newHistogramReturns       struct{ result1 metrics.Histogram }
 	newHistogramReturnsOnCall map[int]struct{ result1 metrics.Histogram }
 
+	NewSummaryStub          func(metrics.SummaryOpts) metrics.Summary
+	newSummaryMutex         sync.RWMutex
+	newSummaryArgsForCall   []struct{ arg1 metrics.SummaryOpts }
+	newSummaryReturns       struct{ result1 metrics.Summary }
+	newSummaryReturnsOnCall map[int]struct{ result1 metrics.Summary }
+
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -191,6 +197,61 @@ func (fake *Provider) SetNewHistogramReturnsOnCall(i int, result1 metrics.Histog
 	fake.newHistogramReturnsOnCall[i] = struct{ result1 metrics.Histogram }{result1}
 }
 
+// => NewSummary
+
+func (fake *Provider) NewSummary(arg1 metrics.SummaryOpts) metrics.Summary {
+	fake.newSummaryMutex.Lock()
+	ret, specificReturn := fake.newSummaryReturnsOnCall[len(fake.newSummaryArgsForCall)]
+	fake.newSummaryArgsForCall = append(fake.newSummaryArgsForCall, struct{ arg1 metrics.SummaryOpts }{arg1})
+	fake.recordInvocation("NewSummary", []interface{}{arg1})
+	fake.newSummaryMutex.Unlock()
+	if fake.NewSummaryStub != nil {
+		return fake.NewSummaryStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.newSummaryReturns
+	return fakeReturns.result1
+}
+
+func (fake *Provider) NewSummaryCallCount() int {
+	fake.newSummaryMutex.RLock()
+	defer fake.newSummaryMutex.RUnlock()
+	return len(fake.newSummaryArgsForCall)
+}
+
+func (fake *Provider) SetNewSummaryStub(stub func(metrics.SummaryOpts) metrics.Summary) {
+	fake.newSummaryMutex.Lock()
+	fake.NewSummaryStub = stub
+	fake.newSummaryMutex.Unlock()
+}
+
+func (fake *Provider) NewSummaryArgsForCall(i int) metrics.SummaryOpts {
+	fake.newSummaryMutex.RLock()
+	defer fake.newSummaryMutex.RUnlock()
+	argsForCall := fake.newSummaryArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Provider) SetNewSummaryReturns(result1 metrics.Summary) {
+	fake.newSummaryMutex.Lock()
+	defer fake.newSummaryMutex.Unlock()
+	fake.NewSummaryStub = nil
+	fake.newSummaryReturns = struct{ result1 metrics.Summary }{result1}
+}
+
+// NewSummaryReturnsOnCall 将 NewSummaryStub 置为 nil，然后在 newSummaryReturnsOnCall 的对应位置设置将来返回的 Summary。
+func (fake *Provider) SetNewSummaryReturnsOnCall(i int, result1 metrics.Summary) {
+	fake.newSummaryMutex.Lock()
+	defer fake.newSummaryMutex.Unlock()
+	fake.NewSummaryStub = nil
+	if fake.newSummaryReturnsOnCall == nil {
+		fake.newSummaryReturnsOnCall = make(map[int]struct{ result1 metrics.Summary })
+	}
+	fake.newSummaryReturnsOnCall[i] = struct{ result1 metrics.Summary }{result1}
+}
+
 func (fake *Provider) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -200,6 +261,8 @@ func (fake *Provider) Invocations() map[string][][]interface{} {
 	defer fake.newGaugeMutex.RUnlock()
 	fake.newHistogramMutex.RLock()
 	defer fake.newHistogramMutex.RUnlock()
+	fake.newSummaryMutex.RLock()
+	defer fake.newSummaryMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,137 @@
+package metricsfakes
+
+import (
+	"sync"
+
+	"github.com/232425wxy/chainer/common/metrics"
+)
+
+type Summary struct {
+	ObserveStub        func(float64)
+	observeMutex       sync.RWMutex
+	observeArgsForCall []struct{ arg1 float64 }
+
+	WithStub          func(...string) metrics.Summary
+	withMutex         sync.RWMutex
+	withArgsForCall   []struct{ arg1 []string }
+	withReturns       struct{ result1 metrics.Summary }
+	withReturnsOnCall map[int]struct{ result1 metrics.Summary }
+
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Summary) Observe(arg1 float64) {
+	fake.observeMutex.Lock()
+	fake.observeArgsForCall = append(fake.observeArgsForCall, struct{ arg1 float64 }{arg1})
+	fake.recordInvocation("Observe", []interface{}{arg1})
+	fake.observeMutex.Unlock()
+	if fake.ObserveStub != nil {
+		fake.ObserveStub(arg1)
+	}
+}
+
+// ObserveCallCount 返回调用 Observe 方法的次数。
+func (fake *Summary) ObserveCallCount() int {
+	fake.observeMutex.Lock()
+	defer fake.observeMutex.Unlock()
+	return len(fake.observeArgsForCall)
+}
+
+// ObserveCalls 设置 ObserveStub，func(float64)。
+func (fake *Summary) ObserveCalls(stub func(float64)) {
+	fake.observeMutex.Lock()
+	defer fake.observeMutex.Unlock()
+	fake.ObserveStub = stub
+}
+
+// ObserveArgsForCall 返回第 i+1 次调用 Observe 方法传入的参数，float64。
+func (fake *Summary) ObserveArgsForCall(i int) float64 {
+	fake.observeMutex.RLock()
+	defer fake.observeMutex.RUnlock()
+	argsForCall := fake.observeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Summary) With(arg1 ...string) metrics.Summary {
+	fake.withMutex.Lock()
+	ret, specifiedReturn := fake.withReturnsOnCall[len(fake.withArgsForCall)]
+	fake.withArgsForCall = append(fake.withArgsForCall, struct{ arg1 []string }{arg1})
+	fake.recordInvocation("With", []interface{}{arg1})
+	fake.withMutex.Unlock()
+	if fake.WithStub != nil {
+		return fake.WithStub(arg1...)
+	}
+	if specifiedReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.withReturns
+	return fakeReturns.result1
+}
+
+// WithCallCount 返回 With 方法被调用的次数。
+func (fake *Summary) WithCallCount() int {
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	return len(fake.withArgsForCall)
+}
+
+// WithCalls 设置 WithStub，func(...string) metrics.Summary。
+func (fake *Summary) SetWithStub(stub func(...string) metrics.Summary) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = stub
+}
+
+// WithArgsForCall 返回第 i+1 次调用 With 方法传入的参数，[]string。
+func (fake *Summary) WithArgsForCall(i int) []string {
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	argsForCall := fake.withArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Summary) SetWithReturns(result1 metrics.Summary) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = nil
+	fake.withReturns = struct{ result1 metrics.Summary }{result1}
+}
+
+func (fake *Summary) SetWithReturnsOnCall(i int, result1 metrics.Summary) {
+	fake.withMutex.Lock()
+	defer fake.withMutex.Unlock()
+	fake.WithStub = nil
+	if fake.withReturnsOnCall == nil {
+		fake.withReturnsOnCall = make(map[int]struct{ result1 metrics.Summary })
+	}
+	fake.withReturnsOnCall[i] = struct{ result1 metrics.Summary }{result1}
+}
+
+func (fake *Summary) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.observeMutex.RLock()
+	defer fake.observeMutex.RUnlock()
+	fake.withMutex.RLock()
+	defer fake.withMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Summary) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ metrics.Summary = new(Summary)
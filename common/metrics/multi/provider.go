@@ -0,0 +1,203 @@
+package multi
+
+import (
+	"fmt"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/namer"
+)
+
+type Config struct {
+	// Logger 输出某个提供者失败时的告警，默认为名为 "metrics.multi" 的 clogging 日志记录器。
+	Logger metrics.Logger
+}
+
+// Provider 把指标同时交给多个提供者，例如在迁移期间同时向 prometheus 和 statsd 输出。每个提供者各自处理 With 传入的
+// 标签，statsd 把标签编码进指标名里，prometheus 把它们作为标签，二者互不影响。
+//
+// 某个提供者在创建指标或者观测时 panic 不会影响其他提供者：panic 会被恢复，每个指标在每个提供者上第一次失败时输出一条
+// 告警。创建指标失败的提供者之后不再接收该指标的观测值。
+type Provider struct {
+	providers []metrics.Provider
+	logger    metrics.Logger
+	warnOnce  metrics.WarnOnce
+}
+
+func NewProvider(providers []metrics.Provider, c Config) *Provider {
+	p := &Provider{
+		providers: providers,
+		logger:    c.Logger,
+	}
+	if p.logger == nil {
+		p.logger = clogging.MustGetLogger("metrics.multi")
+	}
+	return p
+}
+
+// call 调用 f，第 i 个提供者在 f 里 panic 时恢复 panic 并输出告警。
+func (p *Provider) call(i int, name string, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.failed(i, name, r)
+		}
+	}()
+	f()
+}
+
+func (p *Provider) failed(i int, name string, r interface{}) {
+	p.warnOnce.Warnf(p.logger, fmt.Sprintf("%d\xff%s", i, name), "Metrics provider %T failed on metric %s: %v", p.providers[i], name, r)
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	c := &Counter{provider: p, name: namer.NewCounterNamer(opts).FullyQualifiedName(), counters: make([]metrics.Counter, len(p.providers))}
+	for i, provider := range p.providers {
+		p.call(i, c.name, func() { c.counters[i] = provider.NewCounter(opts) })
+	}
+	return c
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	g := &Gauge{provider: p, name: namer.NewGaugeNamer(opts).FullyQualifiedName(), gauges: make([]metrics.Gauge, len(p.providers))}
+	for i, provider := range p.providers {
+		p.call(i, g.name, func() { g.gauges[i] = provider.NewGauge(opts) })
+	}
+	return g
+}
+
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	h := &Histogram{provider: p, name: namer.NewHistogramNamer(opts).FullyQualifiedName(), histograms: make([]metrics.Histogram, len(p.providers))}
+	for i, provider := range p.providers {
+		p.call(i, h.name, func() { h.histograms[i] = provider.NewHistogram(opts) })
+	}
+	return h
+}
+
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	s := &Summary{provider: p, name: namer.NewSummaryNamer(opts).FullyQualifiedName(), summaries: make([]metrics.Summary, len(p.providers))}
+	for i, provider := range p.providers {
+		p.call(i, s.name, func() { s.summaries[i] = provider.NewSummary(opts) })
+	}
+	return s
+}
+
+// Counter 的 counters 与 Provider 的 providers 一一对应，为 nil 的表示对应的提供者已经失败。
+type Counter struct {
+	provider *Provider
+	name     string
+	counters []metrics.Counter
+}
+
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	counters := make([]metrics.Counter, len(c.counters))
+	for i, counter := range c.counters {
+		if counter != nil {
+			c.provider.call(i, c.name, func() { counters[i] = counter.With(labelValues...) })
+		}
+	}
+	return &Counter{provider: c.provider, name: c.name, counters: counters}
+}
+
+func (c *Counter) Add(delta float64) {
+	for i, counter := range c.counters {
+		if counter != nil {
+			c.provider.call(i, c.name, func() { counter.Add(delta) })
+		}
+	}
+}
+
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	for i, counter := range c.counters {
+		if counter != nil {
+			c.provider.call(i, c.name, func() { counter.AddWithExemplar(delta, exemplar) })
+		}
+	}
+}
+
+type Gauge struct {
+	provider *Provider
+	name     string
+	gauges   []metrics.Gauge
+}
+
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	gauges := make([]metrics.Gauge, len(g.gauges))
+	for i, gauge := range g.gauges {
+		if gauge != nil {
+			g.provider.call(i, g.name, func() { gauges[i] = gauge.With(labelValues...) })
+		}
+	}
+	return &Gauge{provider: g.provider, name: g.name, gauges: gauges}
+}
+
+func (g *Gauge) Add(delta float64) {
+	for i, gauge := range g.gauges {
+		if gauge != nil {
+			g.provider.call(i, g.name, func() { gauge.Add(delta) })
+		}
+	}
+}
+
+func (g *Gauge) Set(value float64) {
+	for i, gauge := range g.gauges {
+		if gauge != nil {
+			g.provider.call(i, g.name, func() { gauge.Set(value) })
+		}
+	}
+}
+
+type Histogram struct {
+	provider   *Provider
+	name       string
+	histograms []metrics.Histogram
+}
+
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	histograms := make([]metrics.Histogram, len(h.histograms))
+	for i, histogram := range h.histograms {
+		if histogram != nil {
+			h.provider.call(i, h.name, func() { histograms[i] = histogram.With(labelValues...) })
+		}
+	}
+	return &Histogram{provider: h.provider, name: h.name, histograms: histograms}
+}
+
+func (h *Histogram) Observe(value float64) {
+	for i, histogram := range h.histograms {
+		if histogram != nil {
+			h.provider.call(i, h.name, func() { histogram.Observe(value) })
+		}
+	}
+}
+
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	for i, histogram := range h.histograms {
+		if histogram != nil {
+			h.provider.call(i, h.name, func() { histogram.ObserveWithExemplar(value, exemplar) })
+		}
+	}
+}
+
+type Summary struct {
+	provider  *Provider
+	name      string
+	summaries []metrics.Summary
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	summaries := make([]metrics.Summary, len(s.summaries))
+	for i, summary := range s.summaries {
+		if summary != nil {
+			s.provider.call(i, s.name, func() { summaries[i] = summary.With(labelValues...) })
+		}
+	}
+	return &Summary{provider: s.provider, name: s.name, summaries: summaries}
+}
+
+func (s *Summary) Observe(value float64) {
+	for i, summary := range s.summaries {
+		if summary != nil {
+			s.provider.call(i, s.name, func() { summary.Observe(value) })
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package multi_test
+
+import (
+	"testing"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/232425wxy/chainer/common/metrics/multi"
+	"github.com/232425wxy/chainer/common/metrics/otlp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	fake := &metricsfakes.Provider{}
+	fakeCounter := &metricsfakes.Counter{}
+	fakeCounter.SetWithReturns(fakeCounter)
+	fake.SetNewCounterReturns(fakeCounter)
+	fakeGauge := &metricsfakes.Gauge{}
+	fakeGauge.SetWithReturns(fakeGauge)
+	fake.SetNewGaugeRetruns(fakeGauge)
+	fakeHistogram := &metricsfakes.Histogram{}
+	fakeHistogram.SetWithReturns(fakeHistogram)
+	fake.SetNewHistogramReturns(fakeHistogram)
+	fakeSummary := &metricsfakes.Summary{}
+	fakeSummary.SetWithReturns(fakeSummary)
+	fake.SetNewSummaryReturns(fakeSummary)
+	o := otlp.NewProvider(otlp.Config{})
+
+	p := multi.NewProvider([]metrics.Provider{fake, o}, multi.Config{Logger: &metricsfakes.Logger{}})
+
+	counterOpts := metrics.CounterOpts{Namespace: "ledger", Name: "transactions", LabelNames: []string{"channel"}}
+	p.NewCounter(counterOpts).With("channel", "a").AddWithExemplar(2, map[string]string{"trace_id": "1"})
+	require.Equal(t, counterOpts, fake.NewCounterArgsForCall(0))
+	require.Equal(t, []string{"channel", "a"}, fakeCounter.WithArgsForCall(0))
+	delta, exemplar := fakeCounter.AddWithExemplarArgsForCall(0)
+	require.Equal(t, float64(2), delta)
+	require.Equal(t, map[string]string{"trace_id": "1"}, exemplar)
+
+	gauge := p.NewGauge(metrics.GaugeOpts{Namespace: "ledger", Name: "height", LabelNames: []string{"channel"}}).With("channel", "a")
+	gauge.Set(5)
+	gauge.Add(1)
+	require.Equal(t, float64(5), fakeGauge.SetArgsForCall(0))
+	require.Equal(t, float64(1), fakeGauge.AddArgsForCall(0))
+
+	p.NewHistogram(metrics.HistogramOpts{Name: "duration", LabelNames: []string{"channel"}}).With("channel", "a").Observe(0.5)
+	require.Equal(t, 0.5, fakeHistogram.ObserveArgsForCall(0))
+
+	p.NewSummary(metrics.SummaryOpts{Name: "latency", LabelNames: []string{"channel"}}).With("channel", "a").Observe(0.25)
+	require.Equal(t, 0.25, fakeSummary.ObserveArgsForCall(0))
+
+	rm := o.Collect()
+	for name, value := range map[string]float64{"ledger.transactions": 2, "ledger.height": 6} {
+		m, ok := rm.Metric(name)
+		require.True(t, ok, name)
+		switch data := m.Data.(type) {
+		case otlp.SumData:
+			require.Equal(t, value, data.DataPoints[0].Value)
+		case otlp.GaugeData:
+			require.Equal(t, value, data.DataPoints[0].Value)
+		}
+	}
+	for _, name := range []string{"duration", "latency"} {
+		m, ok := rm.Metric(name)
+		require.True(t, ok, name)
+		require.Equal(t, uint64(1), m.Data.(otlp.HistogramData).DataPoints[0].Count)
+	}
+}
+
+func TestProviderIsolatesFailures(t *testing.T) {
+	failing := &metricsfakes.Provider{}
+	failingCounter := &metricsfakes.Counter{}
+	failingCounter.SetWithReturns(failingCounter)
+	failingCounter.AddStub = func(float64) { panic("connection refused") }
+	failing.SetNewCounterReturns(failingCounter)
+	failing.SetNewGaugeStub(func(metrics.GaugeOpts) metrics.Gauge { panic("already registered") })
+	o := otlp.NewProvider(otlp.Config{})
+	logger := &metricsfakes.Logger{}
+
+	p := multi.NewProvider([]metrics.Provider{failing, o}, multi.Config{Logger: logger})
+
+	counter := p.NewCounter(metrics.CounterOpts{Name: "requests"})
+	counter.Add(1)
+	counter.Add(2)
+	require.Equal(t, 2, failingCounter.AddCallCount())
+
+	gauge := p.NewGauge(metrics.GaugeOpts{Name: "height"})
+	gauge.Set(3)
+	gauge.With("channel", "a")
+
+	require.Equal(t, []string{
+		"Metrics provider *metricsfakes.Provider failed on metric requests: connection refused",
+		"Metrics provider *metricsfakes.Provider failed on metric height: already registered",
+	}, logger.Messages())
+
+	rm := o.Collect()
+	m, ok := rm.Metric("requests")
+	require.True(t, ok)
+	require.Equal(t, float64(3), m.Data.(otlp.SumData).DataPoints[0].Value)
+	m, ok = rm.Metric("height")
+	require.True(t, ok)
+	require.Equal(t, float64(3), m.Data.(otlp.GaugeData).DataPoints[0].Value)
+}
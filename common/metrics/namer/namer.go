@@ -55,6 +55,16 @@ func NewHistogramNamer(opts metrics.HistogramOpts) *Namer {
 	}
 }
 
+func NewSummaryNamer(opts metrics.SummaryOpts) *Namer {
+	return &Namer{
+		namespace:  opts.Namespace,
+		subsystem:  opts.Subsystem,
+		name:       opts.Name,
+		nameFormat: opts.StatsdFormat,
+		labelNames: sliceToSet(opts.LabelNames),
+	}
+}
+
 // FullyQualifiedName 直译过来就是：`完全合格的名字`。
 // return namespace.subsystem.name or namespace.name or subsystem.name or name
 func (n *Namer) FullyQualifiedName() string {
@@ -116,6 +126,11 @@ func (n *Namer) Format(labelValues ...string) string {
 	return strings.Join(segments, "")
 }
 
+// Labels 把交替出现的标签名和标签值转换成 map，标签名必须在 Namer 的 labelNames 里存在，缺少标签值时为 "unknown"。
+func (n *Namer) Labels(labelValues ...string) map[string]string {
+	return n.labelsToMap(labelValues)
+}
+
 // labelsToMap 要求给定的labels必须在Namer的labelNames里存在。
 func (n *Namer) labelsToMap(labelValues []string) map[string]string {
 	labels := map[string]string{}
@@ -0,0 +1,100 @@
+package otlp
+
+import "time"
+
+// 以下类型对应 OTLP 指标协议（opentelemetry/proto/metrics/v1）里的消息，导出器可以直接把它们转换成 OTLP 的请求。
+
+type ResourceMetrics struct {
+	Resource     Resource
+	ScopeMetrics []ScopeMetrics
+}
+
+type Resource struct {
+	Attributes []Attribute
+}
+
+type ScopeMetrics struct {
+	Scope   InstrumentationScope
+	Metrics []Metric
+}
+
+type InstrumentationScope struct {
+	Name    string
+	Version string
+}
+
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	// Data 是 SumData、GaugeData 或者 HistogramData 之一。
+	Data Data
+}
+
+type Data interface {
+	isData()
+}
+
+// AggregationTemporality 决定数据点的值是从 StartTime 开始累计的值，还是上次导出之后的增量。
+type AggregationTemporality int32
+
+const (
+	AggregationTemporalityUnspecified AggregationTemporality = iota
+	AggregationTemporalityDelta
+	AggregationTemporalityCumulative
+)
+
+type SumData struct {
+	DataPoints             []NumberDataPoint
+	AggregationTemporality AggregationTemporality
+	IsMonotonic            bool
+}
+
+type GaugeData struct {
+	DataPoints []NumberDataPoint
+}
+
+type HistogramData struct {
+	DataPoints             []HistogramDataPoint
+	AggregationTemporality AggregationTemporality
+}
+
+func (SumData) isData()       {}
+func (GaugeData) isData()     {}
+func (HistogramData) isData() {}
+
+type NumberDataPoint struct {
+	Attributes []Attribute
+	StartTime  time.Time
+	Time       time.Time
+	Value      float64
+}
+
+// HistogramDataPoint 里的 BucketCounts 比 ExplicitBounds 多一个元素，第 i 个桶统计的是 (ExplicitBounds[i-1], ExplicitBounds[i]]
+// 区间里的观测值，与 Prometheus 不同，桶之间不累加。
+type HistogramDataPoint struct {
+	Attributes     []Attribute
+	StartTime      time.Time
+	Time           time.Time
+	Count          uint64
+	Sum            float64
+	BucketCounts   []uint64
+	ExplicitBounds []float64
+}
+
+// Metric 返回 rm 里名为 name 的指标。
+func (rm ResourceMetrics) Metric(name string) (Metric, bool) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return Metric{}, false
+}
@@ -0,0 +1,67 @@
+package otlp
+
+import (
+	"context"
+	"sync"
+)
+
+// Exporter 把收集到的指标发送出去，例如通过 gRPC 或者 HTTP 发送给 OpenTelemetry Collector。
+type Exporter interface {
+	Export(ctx context.Context, rm ResourceMetrics) error
+	Shutdown(ctx context.Context) error
+}
+
+// InMemoryExporter 把导出的指标保存在内存里，用于测试。
+type InMemoryExporter struct {
+	mutex    sync.Mutex
+	exports  []ResourceMetrics
+	shutdown bool
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) Export(ctx context.Context, rm ResourceMetrics) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.exports = append(e.exports, rm)
+	return nil
+}
+
+func (e *InMemoryExporter) Shutdown(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+// Exports 返回所有导出过的指标，按照导出的先后排序。
+func (e *InMemoryExporter) Exports() []ResourceMetrics {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]ResourceMetrics(nil), e.exports...)
+}
+
+// Latest 返回最近一次导出的指标。
+func (e *InMemoryExporter) Latest() (ResourceMetrics, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.exports) == 0 {
+		return ResourceMetrics{}, false
+	}
+	return e.exports[len(e.exports)-1], true
+}
+
+func (e *InMemoryExporter) IsShutdown() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.shutdown
+}
+
+// Reset 删除保存的所有指标。
+func (e *InMemoryExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.exports = nil
+}
@@ -0,0 +1,452 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/internal/compare"
+)
+
+const defaultScopeName = "github.com/232425wxy/chainer/common/metrics"
+
+// defaultBuckets 与 Prometheus 的默认桶相同。
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Config struct {
+	Exporter Exporter
+	// Interval 大于 0 时，Provider 在后台每隔 Interval 导出一次指标，否则只在调用 ForceFlush 或者 Shutdown 时导出。
+	Interval time.Duration
+	// Resource 是描述指标来源的属性，例如 service.name。
+	Resource map[string]string
+	// ScopeName 和 ScopeVersion 是 OTLP 里的 InstrumentationScope，ScopeName 默认为本包所在的模块路径。
+	ScopeName    string
+	ScopeVersion string
+	// ErrorHandler 处理后台导出时发生的错误，默认忽略这些错误。
+	ErrorHandler func(error)
+}
+
+// Provider 以 OTLP 的数据模型记录 Counter、Gauge 和 Histogram，并通过 Exporter 导出。指标名由 Namespace、Subsystem
+// 和 Name 以点号连接而成，标签成为数据点的属性，Help 和 LabelHelp 成为指标的描述。所有的指标都使用累计的聚合时间性。
+type Provider struct {
+	exporter     Exporter
+	resource     Resource
+	scope        InstrumentationScope
+	errorHandler func(error)
+	now          func() time.Time
+
+	mutex       sync.Mutex
+	instruments map[string]*instrument
+	names       []string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+var _ metrics.Provider = &Provider{}
+
+func NewProvider(c Config) *Provider {
+	p := &Provider{
+		exporter:     c.Exporter,
+		scope:        InstrumentationScope{Name: c.ScopeName, Version: c.ScopeVersion},
+		errorHandler: c.ErrorHandler,
+		now:          time.Now,
+		instruments:  map[string]*instrument{},
+		done:         make(chan struct{}),
+	}
+	if p.scope.Name == "" {
+		p.scope.Name = defaultScopeName
+	}
+	if p.errorHandler == nil {
+		p.errorHandler = func(error) {}
+	}
+	keys := make([]string, 0, len(c.Resource))
+	for key := range c.Resource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p.resource.Attributes = append(p.resource.Attributes, Attribute{Key: key, Value: c.Resource[key]})
+	}
+
+	if c.Interval > 0 && p.exporter != nil {
+		p.wg.Add(1)
+		go p.run(c.Interval)
+	}
+	return p
+}
+
+func (p *Provider) run(interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.ForceFlush(context.Background()); err != nil {
+				p.errorHandler(err)
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	return &Counter{instrument: p.register(kindCounter, opts.Namespace, opts.Subsystem, opts.Name, opts.Help, opts.LabelNames, opts.LabelHelp, nil, nil)}
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	return &Gauge{instrument: p.register(kindGauge, opts.Namespace, opts.Subsystem, opts.Name, opts.Help, opts.LabelNames, opts.LabelHelp, nil, nil)}
+}
+
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultBuckets
+	}
+	return &Histogram{instrument: p.register(kindHistogram, opts.Namespace, opts.Subsystem, opts.Name, opts.Help, opts.LabelNames, opts.LabelHelp, buckets, nil)}
+}
+
+// NewSummary 用默认桶的 Histogram 近似表示 Summary，OTLP 的 SDK 不在客户端计算分位数，分位数应该由后端根据桶计算，
+// 所以 SummaryOpts 里的 Objectives、MaxAge 和 AgeBuckets 不起作用。不过与 prometheus 的 Provider 一样，用不同的 Objectives
+// 重复创建同一个 Summary 会 panic。
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	objectives := opts.Objectives
+	if objectives == nil {
+		objectives = metrics.DefaultObjectives
+	}
+	return &Summary{instrument: p.register(kindHistogram, opts.Namespace, opts.Subsystem, opts.Name, opts.Help, opts.LabelNames, opts.LabelHelp, defaultBuckets, objectives)}
+}
+
+// register 创建一个指标，同名的指标已经存在并且类型、标签、桶和分位数设置都相同时返回已经存在的指标，否则 panic。
+func (p *Provider) register(kind instrumentKind, namespace, subsystem, name, help string, labelNames []string, labelHelp map[string]string, buckets []float64, objectives map[float64]float64) *instrument {
+	fqName := fullyQualifiedName(namespace, subsystem, name)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if existing, ok := p.instruments[fqName]; ok {
+		switch {
+		case existing.kind != kind || !compare.Strings(existing.labelNames, labelNames):
+			panic(fmt.Sprintf("metric %s is already registered as a %s with labels %v", fqName, existing.kind, existing.labelNames))
+		case !compare.Floats(existing.buckets, buckets):
+			panic(fmt.Sprintf("metric %s is already registered with buckets %v", fqName, existing.buckets))
+		case !compare.Objectives(existing.objectives, objectives):
+			panic(fmt.Sprintf("metric %s is already registered with objectives %v", fqName, existing.objectives))
+		}
+		return existing
+	}
+	inst := &instrument{
+		kind:        kind,
+		name:        fqName,
+		description: description(help, labelNames, labelHelp),
+		labelNames:  append([]string(nil), labelNames...),
+		buckets:     append([]float64(nil), buckets...),
+		objectives:  objectives,
+		now:         p.now,
+		points:      map[string]*point{},
+	}
+	p.instruments[fqName] = inst
+	p.names = append(p.names, fqName)
+	return inst
+}
+
+// Collect 返回所有指标当前的值，指标按照创建的顺序排列，数据点按照属性排序。
+func (p *Provider) Collect() ResourceMetrics {
+	p.mutex.Lock()
+	instruments := make([]*instrument, 0, len(p.names))
+	for _, name := range p.names {
+		instruments = append(instruments, p.instruments[name])
+	}
+	p.mutex.Unlock()
+
+	sm := ScopeMetrics{Scope: p.scope}
+	now := p.now()
+	for _, inst := range instruments {
+		sm.Metrics = append(sm.Metrics, inst.collect(now))
+	}
+	return ResourceMetrics{Resource: p.resource, ScopeMetrics: []ScopeMetrics{sm}}
+}
+
+// ForceFlush 立即导出所有指标。
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p.exporter == nil {
+		return nil
+	}
+	return p.exporter.Export(ctx, p.Collect())
+}
+
+// Shutdown 停止后台导出，导出最后一次指标之后关闭 Exporter。重复调用 Shutdown 没有效果。
+func (p *Provider) Shutdown(ctx context.Context) error {
+	var err error
+	p.once.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+		if p.exporter == nil {
+			return
+		}
+		if err = p.ForceFlush(ctx); err != nil {
+			p.exporter.Shutdown(ctx)
+			return
+		}
+		err = p.exporter.Shutdown(ctx)
+	})
+	return err
+}
+
+type instrumentKind int
+
+const (
+	kindCounter instrumentKind = iota
+	kindGauge
+	kindHistogram
+)
+
+func (k instrumentKind) String() string {
+	switch k {
+	case kindCounter:
+		return "counter"
+	case kindGauge:
+		return "gauge"
+	default:
+		return "histogram"
+	}
+}
+
+type point struct {
+	attributes []Attribute
+	start      time.Time
+	value      float64
+	count      uint64
+	buckets    []uint64
+}
+
+type instrument struct {
+	kind        instrumentKind
+	name        string
+	description string
+	labelNames  []string
+	buckets     []float64
+	objectives  map[float64]float64
+	now         func() time.Time
+
+	mutex  sync.Mutex
+	points map[string]*point
+}
+
+// attributes 把 With 传入的标签名和标签值转换成数据点的属性。最后一个标签名缺少值时与 prometheus 一样补上 "unknown"；
+// 声明过但没有在 With 里出现的标签，值为空字符串。
+func (inst *instrument) attributes(labelValues []string) []Attribute {
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues, "unknown")
+	}
+	attrs := make([]Attribute, len(inst.labelNames))
+	for i, name := range inst.labelNames {
+		attrs[i].Key = name
+	}
+	for i := 0; i < len(labelValues); i += 2 {
+		found := false
+		for j := range attrs {
+			if attrs[j].Key == labelValues[i] {
+				attrs[j].Value = labelValues[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			panic(fmt.Sprintf("label %s is not declared for metric %s", labelValues[i], inst.name))
+		}
+	}
+	return attrs
+}
+
+func (inst *instrument) point(attrs []Attribute) *point {
+	var sb strings.Builder
+	for _, attr := range attrs {
+		sb.WriteString(attr.Value)
+		sb.WriteByte(0xff)
+	}
+	key := sb.String()
+	p, ok := inst.points[key]
+	if !ok {
+		p = &point{attributes: attrs, start: inst.now()}
+		if inst.kind == kindHistogram {
+			p.buckets = make([]uint64, len(inst.buckets)+1)
+		}
+		inst.points[key] = p
+	}
+	return p
+}
+
+func (inst *instrument) add(attrs []Attribute, delta float64) {
+	inst.mutex.Lock()
+	inst.point(attrs).value += delta
+	inst.mutex.Unlock()
+}
+
+func (inst *instrument) set(attrs []Attribute, value float64) {
+	inst.mutex.Lock()
+	inst.point(attrs).value = value
+	inst.mutex.Unlock()
+}
+
+func (inst *instrument) observe(attrs []Attribute, value float64) {
+	i := sort.SearchFloat64s(inst.buckets, value)
+	inst.mutex.Lock()
+	p := inst.point(attrs)
+	p.count++
+	p.value += value
+	p.buckets[i]++
+	inst.mutex.Unlock()
+}
+
+func (inst *instrument) collect(now time.Time) Metric {
+	inst.mutex.Lock()
+	points := make([]*point, 0, len(inst.points))
+	for _, p := range inst.points {
+		cp := *p
+		cp.buckets = append([]uint64(nil), p.buckets...)
+		points = append(points, &cp)
+	}
+	inst.mutex.Unlock()
+	sort.Slice(points, func(i, j int) bool { return lessAttributes(points[i].attributes, points[j].attributes) })
+
+	m := Metric{Name: inst.name, Description: inst.description}
+	switch inst.kind {
+	case kindCounter:
+		sum := SumData{AggregationTemporality: AggregationTemporalityCumulative, IsMonotonic: true}
+		for _, p := range points {
+			sum.DataPoints = append(sum.DataPoints, NumberDataPoint{Attributes: p.attributes, StartTime: p.start, Time: now, Value: p.value})
+		}
+		m.Data = sum
+	case kindGauge:
+		gauge := GaugeData{}
+		for _, p := range points {
+			gauge.DataPoints = append(gauge.DataPoints, NumberDataPoint{Attributes: p.attributes, Time: now, Value: p.value})
+		}
+		m.Data = gauge
+	case kindHistogram:
+		histogram := HistogramData{AggregationTemporality: AggregationTemporalityCumulative}
+		for _, p := range points {
+			histogram.DataPoints = append(histogram.DataPoints, HistogramDataPoint{
+				Attributes:     p.attributes,
+				StartTime:      p.start,
+				Time:           now,
+				Count:          p.count,
+				Sum:            p.value,
+				BucketCounts:   p.buckets,
+				ExplicitBounds: inst.buckets,
+			})
+		}
+		m.Data = histogram
+	}
+	return m
+}
+
+type Counter struct {
+	instrument *instrument
+	attrs      []string
+}
+
+// With 返回带有给定标签的 Counter，labelValues 是交替出现的标签名和标签值。
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return &Counter{instrument: c.instrument, attrs: append(c.attrs[:len(c.attrs):len(c.attrs)], labelValues...)}
+}
+
+// Add 增加计数器的值，delta 为负数时 panic。
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease in value", c.instrument.name))
+	}
+	c.instrument.add(c.instrument.attributes(c.attrs), delta)
+}
+
+// AddWithExemplar 目前忽略 exemplar。
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.Add(delta)
+}
+
+type Gauge struct {
+	instrument *instrument
+	attrs      []string
+}
+
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return &Gauge{instrument: g.instrument, attrs: append(g.attrs[:len(g.attrs):len(g.attrs)], labelValues...)}
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.instrument.add(g.instrument.attributes(g.attrs), delta)
+}
+
+func (g *Gauge) Set(value float64) {
+	g.instrument.set(g.instrument.attributes(g.attrs), value)
+}
+
+type Histogram struct {
+	instrument *instrument
+	attrs      []string
+}
+
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return &Histogram{instrument: h.instrument, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], labelValues...)}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.instrument.observe(h.instrument.attributes(h.attrs), value)
+}
+
+// ObserveWithExemplar 目前忽略 exemplar。
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	h.Observe(value)
+}
+
+type Summary struct {
+	instrument *instrument
+	attrs      []string
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	return &Summary{instrument: s.instrument, attrs: append(s.attrs[:len(s.attrs):len(s.attrs)], labelValues...)}
+}
+
+func (s *Summary) Observe(value float64) {
+	s.instrument.observe(s.instrument.attributes(s.attrs), value)
+}
+
+func fullyQualifiedName(namespace, subsystem, name string) string {
+	var parts []string
+	for _, part := range []string{namespace, subsystem, name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// description 把 LabelHelp 里的标签说明按照 LabelNames 的顺序附加在 Help 之后，OTLP 没有单独描述属性的字段。
+func description(help string, labelNames []string, labelHelp map[string]string) string {
+	var labels []string
+	for _, name := range labelNames {
+		if h := labelHelp[name]; h != "" {
+			labels = append(labels, name+": "+h)
+		}
+	}
+	if len(labels) == 0 {
+		return help
+	}
+	return help + " (" + strings.Join(labels, "; ") + ")"
+}
+
+func lessAttributes(a, b []Attribute) bool {
+	for i := range a {
+		if a[i].Value != b[i].Value {
+			return a[i].Value < b[i].Value
+		}
+	}
+	return false
+}
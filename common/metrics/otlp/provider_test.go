@@ -0,0 +1,176 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProvider(c Config) (*Provider, time.Time) {
+	p := NewProvider(c)
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	return p, now
+}
+
+func TestProviderCounter(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	p, now := newTestProvider(Config{Exporter: exporter, Resource: map[string]string{"service.name": "peer0", "host.name": "node1"}})
+
+	counter := p.NewCounter(metrics.CounterOpts{
+		Namespace:  "logging",
+		Name:       "entries_written",
+		Help:       "Number of log entries that are written",
+		LabelNames: []string{"level", "logger"},
+		LabelHelp:  map[string]string{"level": "level of the entry"},
+	})
+	counter.With("level", "warn", "logger", "gossip").Add(2)
+	counter.With("level", "info").Add(1)
+	counter.With("level", "warn").With("logger", "gossip").Add(0.5)
+	require.PanicsWithValue(t, "counter logging.entries_written cannot decrease in value", func() { counter.With("level", "info").Add(-1) })
+	require.PanicsWithValue(t, "label channel is not declared for metric logging.entries_written", func() { counter.With("channel", "a").Add(1) })
+
+	require.NoError(t, p.ForceFlush(context.Background()))
+	rm, ok := exporter.Latest()
+	require.True(t, ok)
+	require.Equal(t, Resource{Attributes: []Attribute{{Key: "host.name", Value: "node1"}, {Key: "service.name", Value: "peer0"}}}, rm.Resource)
+	require.Equal(t, InstrumentationScope{Name: "github.com/232425wxy/chainer/common/metrics"}, rm.ScopeMetrics[0].Scope)
+	require.Equal(t, []Metric{{
+		Name:        "logging.entries_written",
+		Description: "Number of log entries that are written (level: level of the entry)",
+		Data: SumData{
+			AggregationTemporality: AggregationTemporalityCumulative,
+			IsMonotonic:            true,
+			DataPoints: []NumberDataPoint{
+				{Attributes: []Attribute{{Key: "level", Value: "info"}, {Key: "logger", Value: ""}}, StartTime: now, Time: now, Value: 1},
+				{Attributes: []Attribute{{Key: "level", Value: "warn"}, {Key: "logger", Value: "gossip"}}, StartTime: now, Time: now, Value: 2.5},
+			},
+		},
+	}}, rm.ScopeMetrics[0].Metrics)
+}
+
+func TestProviderGaugeAndHistogram(t *testing.T) {
+	p, now := newTestProvider(Config{})
+
+	gauge := p.NewGauge(metrics.GaugeOpts{Namespace: "ledger", Subsystem: "blockchain", Name: "height", LabelNames: []string{"channel"}})
+	gauge.With("channel", "mychannel").Set(10)
+	gauge.With("channel", "mychannel").Add(-3)
+
+	histogram := p.NewHistogram(metrics.HistogramOpts{Name: "request_duration", Buckets: []float64{0.1, 1}})
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		histogram.Observe(v)
+	}
+
+	rm := p.Collect()
+	height, ok := rm.Metric("ledger.blockchain.height")
+	require.True(t, ok)
+	require.Equal(t, GaugeData{DataPoints: []NumberDataPoint{{Attributes: []Attribute{{Key: "channel", Value: "mychannel"}}, Time: now, Value: 7}}}, height.Data)
+
+	duration, ok := rm.Metric("request_duration")
+	require.True(t, ok)
+	require.Equal(t, HistogramData{
+		AggregationTemporality: AggregationTemporalityCumulative,
+		DataPoints: []HistogramDataPoint{{
+			Attributes:     []Attribute{},
+			StartTime:      now,
+			Time:           now,
+			Count:          4,
+			Sum:            3.65,
+			BucketCounts:   []uint64{2, 1, 1},
+			ExplicitBounds: []float64{0.1, 1},
+		}},
+	}, duration.Data)
+
+	_, ok = rm.Metric("missing")
+	require.False(t, ok)
+	require.NoError(t, p.ForceFlush(context.Background()), "flushing without an exporter is a no-op")
+}
+
+func TestProviderDuplicateRegistration(t *testing.T) {
+	p := NewProvider(Config{})
+	opts := metrics.CounterOpts{Namespace: "grpc", Name: "requests", LabelNames: []string{"code"}}
+	first := p.NewCounter(opts)
+	second := p.NewCounter(opts)
+	first.With("code", "OK").Add(1)
+	second.With("code", "OK").Add(1)
+
+	rm := p.Collect()
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	require.Equal(t, float64(2), rm.ScopeMetrics[0].Metrics[0].Data.(SumData).DataPoints[0].Value)
+
+	require.PanicsWithValue(t, "metric grpc.requests is already registered as a counter with labels [code]", func() {
+		p.NewGauge(metrics.GaugeOpts{Namespace: "grpc", Name: "requests", LabelNames: []string{"code"}})
+	})
+	require.Panics(t, func() { p.NewCounter(metrics.CounterOpts{Namespace: "grpc", Name: "requests"}) })
+
+	p.NewHistogram(metrics.HistogramOpts{Namespace: "grpc", Name: "duration", Buckets: []float64{1, 2}})
+	p.NewHistogram(metrics.HistogramOpts{Namespace: "grpc", Name: "duration", Buckets: []float64{1, 2}})
+	require.PanicsWithValue(t, "metric grpc.duration is already registered with buckets [1 2]", func() {
+		p.NewHistogram(metrics.HistogramOpts{Namespace: "grpc", Name: "duration", Buckets: []float64{1, 5}})
+	})
+
+	p.NewSummary(metrics.SummaryOpts{Namespace: "grpc", Name: "latency"})
+	p.NewSummary(metrics.SummaryOpts{Namespace: "grpc", Name: "latency", Objectives: metrics.DefaultObjectives})
+	require.PanicsWithValue(t, "metric grpc.latency is already registered with objectives "+fmt.Sprint(metrics.DefaultObjectives), func() {
+		p.NewSummary(metrics.SummaryOpts{Namespace: "grpc", Name: "latency", Objectives: map[float64]float64{0.99: 0.001}})
+	})
+}
+
+func TestProviderMissingLabelValue(t *testing.T) {
+	p := NewProvider(Config{})
+	counter := p.NewCounter(metrics.CounterOpts{Name: "requests", LabelNames: []string{"code", "method"}})
+	counter.With("code").Add(1)
+
+	m, ok := p.Collect().Metric("requests")
+	require.True(t, ok)
+	require.Equal(t, []Attribute{{Key: "code", Value: "unknown"}, {Key: "method", Value: ""}}, m.Data.(SumData).DataPoints[0].Attributes)
+}
+
+type failingExporter struct {
+	*InMemoryExporter
+	err error
+}
+
+func (e *failingExporter) Export(ctx context.Context, rm ResourceMetrics) error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.InMemoryExporter.Export(ctx, rm)
+}
+
+func TestProviderPeriodicExport(t *testing.T) {
+	exporter := &failingExporter{InMemoryExporter: NewInMemoryExporter(), err: errors.New("collector unavailable")}
+	errs := make(chan error, 10)
+	p := NewProvider(Config{
+		Exporter:     exporter,
+		Interval:     10 * time.Millisecond,
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	p.NewCounter(metrics.CounterOpts{Name: "ticks"}).Add(1)
+
+	require.EqualError(t, <-errs, "collector unavailable")
+	require.Empty(t, exporter.Exports())
+
+	require.EqualError(t, p.Shutdown(context.Background()), "collector unavailable")
+	require.True(t, exporter.IsShutdown())
+	require.NoError(t, p.Shutdown(context.Background()), "shutdown is idempotent")
+}
+
+func TestProviderSummary(t *testing.T) {
+	p, _ := newTestProvider(Config{})
+	summary := p.NewSummary(metrics.SummaryOpts{Name: "commit_duration", Objectives: map[float64]float64{0.99: 0.001}})
+	summary.Observe(0.3)
+	summary.Observe(20)
+
+	m, ok := p.Collect().Metric("commit_duration")
+	require.True(t, ok)
+	dp := m.Data.(HistogramData).DataPoints[0]
+	require.Equal(t, uint64(2), dp.Count)
+	require.Equal(t, defaultBuckets, dp.ExplicitBounds)
+	require.Equal(t, uint64(1), dp.BucketCounts[len(dp.BucketCounts)-1])
+}
@@ -1,20 +1,73 @@
 package prometheus
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/internal/compare"
 	kitmetrics "github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/prometheus"
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
-type Provider struct{}
+// Provider 把指标注册到 Registerer 上，并通过 Gatherer 供 prometheus 抓取。零值的 Provider 使用 prometheus 全局默认的
+// Registerer 和 Gatherer；需要在同一个进程里运行多个互不干扰的 Provider 时（例如在集成测试里启动多个节点），应该通过
+// NewProvider 为每个 Provider 指定独立的 Registry。
+//
+// 用相同的选项重复创建同一个指标时，返回的是已经注册过的指标；选项不同时（指标类型、Help、LabelNames、Buckets
+// 或者 Summary 的分位数设置不同）会 panic。
+type Provider struct {
+	registerer prom.Registerer
+	gatherer   prom.Gatherer
+
+	mutex      sync.Mutex
+	registered map[string]*registration
+}
+
+// NewProvider 返回使用 registerer 注册指标、使用 gatherer 收集指标的 Provider，二者为 nil 时使用 prometheus 全局默认的值。
+func NewProvider(registerer prom.Registerer, gatherer prom.Gatherer) *Provider {
+	return &Provider{registerer: registerer, gatherer: gatherer}
+}
+
+// NewRegistryProvider 返回使用一个全新的 Registry 的 Provider。
+func NewRegistryProvider() *Provider {
+	registry := prom.NewRegistry()
+	return NewProvider(registry, registry)
+}
+
+// Registerer 返回 Provider 注册指标所使用的 prom.Registerer。
+func (p *Provider) Registerer() prom.Registerer {
+	if p.registerer == nil {
+		return prom.DefaultRegisterer
+	}
+	return p.registerer
+}
+
+// Gatherer 返回 Provider 收集指标所使用的 prom.Gatherer，可以用它构造 promhttp.HandlerFor。
+func (p *Provider) Gatherer() prom.Gatherer {
+	if p.gatherer == nil {
+		return prom.DefaultGatherer
+	}
+	return p.gatherer
+}
 
 type Counter struct {
 	kitmetrics.Counter
+	cv          *prom.CounterVec
+	labelValues []string
 }
 
 func (c *Counter) With(labelValues ...string) metrics.Counter {
-	return &Counter{Counter: c.Counter.With(labelValues...)}
+	return &Counter{Counter: c.Counter.With(labelValues...), cv: c.cv, labelValues: withLabelValues(c.labelValues, labelValues)}
+}
+
+// AddWithExemplar 增加计数器的值并附带 exemplar，exemplar 只有在以 OpenMetrics 格式抓取时才会输出。exemplar 的标签名
+// 和标签值的总长度不能超过 128 个字符，否则会 panic。
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.cv.With(makeLabels(c.labelValues)).(prom.ExemplarAdder).AddWithExemplar(delta, exemplar)
 }
 
 type Gauge struct {
@@ -27,51 +80,202 @@ func (g *Gauge) With(labelValues ...string) metrics.Gauge {
 
 type Histogram struct {
 	kitmetrics.Histogram
+	hv          *prom.HistogramVec
+	labelValues []string
 }
 
 func (h *Histogram) With(labelValues ...string) metrics.Histogram {
-	return &Histogram{Histogram: h.Histogram.With(labelValues...)}
+	return &Histogram{Histogram: h.Histogram.With(labelValues...), hv: h.hv, labelValues: withLabelValues(h.labelValues, labelValues)}
+}
+
+// ObserveWithExemplar 记录观测值并为它所在的 bucket 附带 exemplar，exemplar 的限制与 Counter.AddWithExemplar 相同。
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	h.hv.With(makeLabels(h.labelValues)).(prom.ExemplarObserver).ObserveWithExemplar(value, exemplar)
+}
+
+type Summary struct {
+	kitmetrics.Histogram
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	return &Summary{Histogram: s.Histogram.With(labelValues...)}
 }
 
 func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
-	return &Counter{
-		Counter: prometheus.NewCounterFrom(
-			prom.CounterOpts{
-				Namespace: opts.Namespace,
-				Subsystem: opts.Subsystem,
-				Name:      opts.Name,
-				Help:      opts.Help,
-			},
-			opts.LabelNames,
-		),
-	}
+	cv := prom.NewCounterVec(
+		prom.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      opts.Name,
+			Help:      opts.Help,
+		},
+		opts.LabelNames,
+	)
+	r := &registration{kind: "counter", help: opts.Help, labelNames: opts.LabelNames, collector: cv}
+	cv = p.mustRegister(prom.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), r).(*prom.CounterVec)
+	return &Counter{Counter: prometheus.NewCounter(cv), cv: cv}
 }
 
 func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
-	return &Gauge{
-		Gauge: prometheus.NewGaugeFrom(
-			prom.GaugeOpts{
-				Namespace: opts.Namespace,
-				Subsystem: opts.Subsystem,
-				Name:      opts.Name,
-				Help:      opts.Help,
-			},
-			opts.LabelNames,
-		),
-	}
+	gv := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      opts.Name,
+			Help:      opts.Help,
+		},
+		opts.LabelNames,
+	)
+	r := &registration{kind: "gauge", help: opts.Help, labelNames: opts.LabelNames, collector: gv}
+	gv = p.mustRegister(prom.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), r).(*prom.GaugeVec)
+	return &Gauge{Gauge: prometheus.NewGauge(gv)}
 }
 
 func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
-	return &Histogram{
-		Histogram: prometheus.NewHistogramFrom(
-			prom.HistogramOpts{
-				Namespace: opts.Namespace,
-				Subsystem: opts.Subsystem,
-				Name:      opts.Name,
-				Help:      opts.Help,
-				Buckets:   opts.Buckets,
-			},
-			opts.LabelNames,
-		),
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prom.DefBuckets
+	}
+	hv := prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      opts.Name,
+			Help:      opts.Help,
+			Buckets:   buckets,
+		},
+		opts.LabelNames,
+	)
+	r := &registration{kind: "histogram", help: opts.Help, labelNames: opts.LabelNames, buckets: buckets, collector: hv}
+	hv = p.mustRegister(prom.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), r).(*prom.HistogramVec)
+	return &Histogram{Histogram: prometheus.NewHistogram(hv), hv: hv}
+}
+
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	objectives := opts.Objectives
+	if objectives == nil {
+		objectives = metrics.DefaultObjectives
+	}
+	maxAge := opts.MaxAge
+	if maxAge == 0 {
+		maxAge = prom.DefMaxAge
+	}
+	ageBuckets := opts.AgeBuckets
+	if ageBuckets == 0 {
+		ageBuckets = prom.DefAgeBuckets
+	}
+	sv := prom.NewSummaryVec(
+		prom.SummaryOpts{
+			Namespace:  opts.Namespace,
+			Subsystem:  opts.Subsystem,
+			Name:       opts.Name,
+			Help:       opts.Help,
+			Objectives: objectives,
+			MaxAge:     maxAge,
+			AgeBuckets: ageBuckets,
+		},
+		opts.LabelNames,
+	)
+	r := &registration{kind: "summary", help: opts.Help, labelNames: opts.LabelNames, objectives: objectives, maxAge: maxAge, ageBuckets: ageBuckets, collector: sv}
+	sv = p.mustRegister(prom.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), r).(*prom.SummaryVec)
+	return &Summary{Histogram: prometheus.NewSummary(sv)}
+}
+
+type registration struct {
+	kind       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	objectives map[float64]float64
+	maxAge     time.Duration
+	ageBuckets uint32
+	collector  prom.Collector
+}
+
+func (r *registration) conflicts(other *registration) error {
+	switch {
+	case r.kind != other.kind:
+		return fmt.Errorf("already registered as a %s", r.kind)
+	case r.help != other.help:
+		return fmt.Errorf("already registered with help %q", r.help)
+	case !compare.Strings(r.labelNames, other.labelNames):
+		return fmt.Errorf("already registered with labels %v", r.labelNames)
+	case !compare.Floats(r.buckets, other.buckets):
+		return fmt.Errorf("already registered with buckets %v", r.buckets)
+	case !compare.Objectives(r.objectives, other.objectives) || r.maxAge != other.maxAge || r.ageBuckets != other.ageBuckets:
+		return fmt.Errorf("already registered with objectives %v, max age %s and %d age buckets", r.objectives, r.maxAge, r.ageBuckets)
+	}
+	return nil
+}
+
+// register 把 r 注册到 Registerer 上，返回实际使用的 Collector。如果同名的指标已经通过 p 注册过，选项相同时返回已经
+// 注册的 Collector，否则返回错误；如果同名的指标已经由其他的 Provider 注册到同一个 Registerer 上，类型相同时同样返回
+// 已经注册的 Collector。
+func (p *Provider) register(fqName string, r *registration) (prom.Collector, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if existing, ok := p.registered[fqName]; ok {
+		if err := existing.conflicts(r); err != nil {
+			return nil, fmt.Errorf("metric %s is %s", fqName, err)
+		}
+		return existing.collector, nil
+	}
+
+	if err := p.Registerer().Register(r.collector); err != nil {
+		var are prom.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return nil, fmt.Errorf("failed to register metric %s: %s", fqName, err)
+		}
+		r.collector = are.ExistingCollector
+		if !sameKind(r.kind, r.collector) {
+			return nil, fmt.Errorf("metric %s is already registered as a %T", fqName, r.collector)
+		}
+	}
+
+	if p.registered == nil {
+		p.registered = map[string]*registration{}
+	}
+	p.registered[fqName] = r
+	return r.collector, nil
+}
+
+// metrics.Provider 的方法不能返回错误，所以注册失败时直接 panic。
+func (p *Provider) mustRegister(fqName string, r *registration) prom.Collector {
+	c, err := p.register(fqName, r)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// withLabelValues 与 go-kit 的 With 一样追加标签名和标签值，标签值缺失时补上 "unknown"。
+func withLabelValues(lvs []string, labelValues []string) []string {
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues, "unknown")
+	}
+	return append(lvs[:len(lvs):len(lvs)], labelValues...)
+}
+
+func makeLabels(labelValues []string) prom.Labels {
+	labels := prom.Labels{}
+	for i := 0; i < len(labelValues); i += 2 {
+		labels[labelValues[i]] = labelValues[i+1]
+	}
+	return labels
+}
+
+func sameKind(kind string, c prom.Collector) bool {
+	switch c.(type) {
+	case *prom.CounterVec:
+		return kind == "counter"
+	case *prom.GaugeVec:
+		return kind == "gauge"
+	case *prom.HistogramVec:
+		return kind == "histogram"
+	case *prom.SummaryVec:
+		return kind == "summary"
+	default:
+		return false
 	}
 }
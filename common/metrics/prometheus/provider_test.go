@@ -3,6 +3,7 @@ package prometheus
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -84,3 +85,132 @@ func TestProvider(t *testing.T) {
 	require.NoError(t, err)
 	t.Log(string(bz))
 }
+
+func TestProviderPrivateRegistry(t *testing.T) {
+	p1 := NewRegistryProvider()
+	p2 := NewRegistryProvider()
+
+	opts := metrics.CounterOpts{Namespace: "node", Name: "blocks", Help: "committed blocks", LabelNames: []string{"channel"}}
+	p1.NewCounter(opts).With("channel", "a").Add(1)
+	p2.NewCounter(opts).With("channel", "a").Add(5)
+
+	require.Equal(t, float64(1), gatheredValue(t, p1, "node_blocks"))
+	require.Equal(t, float64(5), gatheredValue(t, p2, "node_blocks"))
+}
+
+func TestProviderDuplicateRegistration(t *testing.T) {
+	p := NewRegistryProvider()
+
+	counterOpts := metrics.CounterOpts{Namespace: "node", Name: "requests", Help: "requests", LabelNames: []string{"code"}}
+	p.NewCounter(counterOpts).With("code", "OK").Add(1)
+	p.NewCounter(counterOpts).With("code", "OK").Add(2)
+	require.Equal(t, float64(3), gatheredValue(t, p, "node_requests"))
+
+	histogramOpts := metrics.HistogramOpts{Namespace: "node", Name: "duration", Buckets: []float64{1, 2}}
+	p.NewHistogram(histogramOpts)
+	p.NewHistogram(histogramOpts)
+
+	require.PanicsWithError(t, "metric node_requests is already registered as a counter", func() {
+		p.NewGauge(metrics.GaugeOpts{Namespace: "node", Name: "requests", Help: "requests", LabelNames: []string{"code"}})
+	})
+	require.PanicsWithError(t, "metric node_requests is already registered with labels [code]", func() {
+		p.NewCounter(metrics.CounterOpts{Namespace: "node", Name: "requests", Help: "requests"})
+	})
+	require.PanicsWithError(t, `metric node_requests is already registered with help "requests"`, func() {
+		p.NewCounter(metrics.CounterOpts{Namespace: "node", Name: "requests", LabelNames: []string{"code"}})
+	})
+	require.PanicsWithError(t, "metric node_duration is already registered with buckets [1 2]", func() {
+		p.NewHistogram(metrics.HistogramOpts{Namespace: "node", Name: "duration"})
+	})
+}
+
+func TestProviderSharedRegistry(t *testing.T) {
+	registry := prom.NewRegistry()
+	p1 := NewProvider(registry, registry)
+	p2 := NewProvider(registry, registry)
+
+	opts := metrics.GaugeOpts{Namespace: "node", Name: "height"}
+	p1.NewGauge(opts).Set(3)
+	p2.NewGauge(opts).Add(1)
+	require.Equal(t, float64(4), gatheredValue(t, p1, "node_height"))
+
+	p3 := NewProvider(registry, registry)
+	require.PanicsWithError(t, "metric node_height is already registered as a *prometheus.GaugeVec", func() {
+		p3.NewCounter(metrics.CounterOpts{Namespace: "node", Name: "height"})
+	})
+	require.Panics(t, func() {
+		p3.NewGauge(metrics.GaugeOpts{Namespace: "node", Name: "height", LabelNames: []string{"channel"}})
+	})
+}
+
+func gatheredValue(t *testing.T, p *Provider, name string) float64 {
+	families, err := p.Gatherer().Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		m := family.GetMetric()[0]
+		if m.GetCounter() != nil {
+			return m.GetCounter().GetValue()
+		}
+		return m.GetGauge().GetValue()
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+func TestProviderSummary(t *testing.T) {
+	p := NewRegistryProvider()
+	opts := metrics.SummaryOpts{
+		Namespace:  "ledger",
+		Name:       "block_commit_duration",
+		Help:       "commit latency",
+		Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001},
+		LabelNames: []string{"channel"},
+	}
+	summary := p.NewSummary(opts)
+	for i := 1; i <= 100; i++ {
+		summary.With("channel", "mychannel").Observe(float64(i))
+	}
+	p.NewSummary(opts).With("channel", "mychannel").Observe(100)
+
+	families, err := p.Gatherer().Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	s := families[0].GetMetric()[0].GetSummary()
+	require.Equal(t, uint64(101), s.GetSampleCount())
+	require.Equal(t, float64(5150), s.GetSampleSum())
+	require.Len(t, s.GetQuantile(), 2)
+	require.Equal(t, 0.5, s.GetQuantile()[0].GetQuantile())
+	require.InDelta(t, 51, s.GetQuantile()[0].GetValue(), 5)
+
+	require.PanicsWithError(t, "metric ledger_block_commit_duration is already registered with objectives map[0.5:0.05 0.99:0.001], max age 10m0s and 5 age buckets", func() {
+		p.NewSummary(metrics.SummaryOpts{Namespace: "ledger", Name: "block_commit_duration", Help: "commit latency", LabelNames: []string{"channel"}})
+	})
+}
+
+func TestProviderExemplars(t *testing.T) {
+	p := NewRegistryProvider()
+	server := httptest.NewServer(promhttp.HandlerFor(p.Gatherer(), promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	defer server.Close()
+
+	counter := p.NewCounter(metrics.CounterOpts{Namespace: "ledger", Name: "transactions", Help: "transactions", LabelNames: []string{"channel"}})
+	counter.With("channel", "mychannel").AddWithExemplar(2, map[string]string{"trace_id": "4bf92f3577b34da6"})
+	histogram := p.NewHistogram(metrics.HistogramOpts{Namespace: "ledger", Name: "commit_duration", Help: "commit latency", Buckets: []float64{1, 5}, LabelNames: []string{"channel"}})
+	histogram.With("channel", "mychannel").ObserveWithExemplar(3, map[string]string{"trace_id": "00f067aa0ba902b7"})
+	histogram.With("channel", "mychannel").Observe(0.5)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	bz, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Contains(t, string(bz), `ledger_transactions{channel="mychannel"} 2.0 # {trace_id="4bf92f3577b34da6"} 2.0`)
+	require.Contains(t, string(bz), `ledger_commit_duration_bucket{channel="mychannel",le="5.0"} 2 # {trace_id="00f067aa0ba902b7"} 3.0`)
+	require.Contains(t, string(bz), `ledger_commit_duration_bucket{channel="mychannel",le="1.0"} 1`+"\n")
+}
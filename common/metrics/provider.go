@@ -1,9 +1,12 @@
 package metrics
 
+import "time"
+
 type Provider interface {
 	NewCounter(CounterOpts) Counter
 	NewGauge(GaugeOpts) Gauge
 	NewHistogram(HistogramOpts) Histogram
+	NewSummary(SummaryOpts) Summary
 }
 
 // Counter 是一个累计类型的数据指标，它代表单调递增的计数器，其值只能增加，不能减少。
@@ -12,16 +15,23 @@ type Counter interface {
 	With(labelValues ...string) Counter
 	// 计数器增加值
 	Add(delta float64)
+	// AddWithExemplar 与 Add 一样增加计数器的值，同时附带一个 exemplar，例如 {"trace_id": "..."}，使得可以从指标
+	// 跳转到引起变化的那次调用。不支持 exemplar 的提供者会忽略 exemplar。
+	AddWithExemplar(delta float64, exemplar map[string]string)
 }
 
+// 各个 Opts 里的 MaxCardinality 限制指标最多有多少种不同的标签值组合，AllowedLabelValues 限定标签可以取的值，
+// 二者只有在提供者被 cardinality.NewProvider 包装之后才会生效，超出限制的标签值会被替换为 "__overflow__"。
 type CounterOpts struct {
-	Namespace    string
-	Subsystem    string
-	Name         string
-	Help         string
-	LabelNames   []string
-	LabelHelp    map[string]string
-	StatsdFormat string
+	Namespace          string
+	Subsystem          string
+	Name               string
+	Help               string
+	LabelNames         []string
+	LabelHelp          map[string]string
+	MaxCardinality     int
+	AllowedLabelValues map[string][]string
+	StatsdFormat       string
 }
 
 // Gauge 是可以任意上下波动数值的指标类型，也就是说Gauge的值可增可减。
@@ -33,13 +43,15 @@ type Gauge interface {
 }
 
 type GaugeOpts struct {
-	Namespace    string
-	Subsystem    string
-	Name         string
-	Help         string
-	LabelNames   []string
-	LabelHelp    map[string]string
-	StatsdFormat string
+	Namespace          string
+	Subsystem          string
+	Name               string
+	Help               string
+	LabelNames         []string
+	LabelHelp          map[string]string
+	MaxCardinality     int
+	AllowedLabelValues map[string][]string
+	StatsdFormat       string
 }
 
 // Histogram 在Prometheus里是一种累积直方图，在弄懂什么是累积直方图前，先看一个例子：
@@ -56,15 +68,49 @@ type GaugeOpts struct {
 type Histogram interface {
 	With(labelValues ...string) Histogram
 	Observe(value float64)
+	// ObserveWithExemplar 与 Observe 一样记录观测值，同时为该值所在的 bucket 附带一个 exemplar，例如
+	// {"trace_id": "..."}。不支持 exemplar 的提供者会忽略 exemplar。
+	ObserveWithExemplar(value float64, exemplar map[string]string)
 }
 
 type HistogramOpts struct {
-	Namespace    string
-	Subsystem    string
-	Name         string
-	Help         string
-	Buckets      []float64
-	LabelNames   []string
-	LabelHelp    map[string]string
-	StatsdFormat string
+	Namespace          string
+	Subsystem          string
+	Name               string
+	Help               string
+	Buckets            []float64
+	LabelNames         []string
+	LabelHelp          map[string]string
+	MaxCardinality     int
+	AllowedLabelValues map[string][]string
+	StatsdFormat       string
+}
+
+// Summary 与 Histogram 一样记录观测值的分布，不同的是它在客户端直接计算分位数（例如 0.5、0.9 和 0.99 分位数），
+// 而不是统计落在各个 bucket 里的样本数。分位数是在最近 MaxAge 时间内的样本上计算的，这段时间被分成 AgeBuckets
+// 个窗口轮流淘汰旧样本。分位数无法在多个实例之间聚合，需要聚合时应该使用 Histogram。
+type Summary interface {
+	With(labelValues ...string) Summary
+	Observe(value float64)
 }
+
+type SummaryOpts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	// Objectives 的键是分位数，值是允许的绝对误差，例如 {0.5: 0.05, 0.99: 0.001}。为 nil 时使用 DefaultObjectives，
+	// 不需要分位数时可以传入空的 map。
+	Objectives map[float64]float64
+	// MaxAge 为 0 时使用 10 分钟，AgeBuckets 为 0 时使用 5 个窗口。
+	MaxAge             time.Duration
+	AgeBuckets         uint32
+	LabelNames         []string
+	LabelHelp          map[string]string
+	MaxCardinality     int
+	AllowedLabelValues map[string][]string
+	StatsdFormat       string
+}
+
+// DefaultObjectives 是 SummaryOpts 没有给出 Objectives 时计算的分位数。
+var DefaultObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
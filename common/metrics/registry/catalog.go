@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+// WriteMarkdown 把 entries 以 Markdown 表格的形式写到 w，每个指标一行。
+func WriteMarkdown(w io.Writer, entries []Entry) error {
+	var sb strings.Builder
+	sb.WriteString("| Name | Type | Labels | Help | StatsD Format |\n")
+	sb.WriteString("|------|------|--------|------|---------------|\n")
+	for _, e := range entries {
+		labels := make([]string, len(e.LabelNames))
+		for i, label := range e.LabelNames {
+			labels[i] = "`" + label + "`"
+		}
+		fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s |\n",
+			e.Name, e.Type, strings.Join(labels, ", "), markdownEscaper.Replace(e.Help), code(e.StatsdFormat))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
+
+// WriteCSV 把 entries 以 CSV 的形式写到 w，第一行是表头，标签名之间以空格分隔。
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"name", "type", "labels", "help", "statsd_format"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := cw.Write([]string{e.Name, e.Type, strings.Join(e.LabelNames, " "), e.Help, e.StatsdFormat}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/namer"
+)
+
+// 指标的类型。
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+)
+
+// Entry 描述一个指标，Name 是 Namespace、Subsystem 和 Name 以点号连接而成的完全限定名。
+type Entry struct {
+	Name         string
+	Type         string
+	Help         string
+	LabelNames   []string
+	StatsdFormat string
+}
+
+// NewEntry 根据 CounterOpts、GaugeOpts、HistogramOpts 或者 SummaryOpts 返回 Entry，opts 是其他类型时会 panic。
+func NewEntry(opts interface{}) Entry {
+	switch o := opts.(type) {
+	case metrics.CounterOpts:
+		return Entry{Name: namer.NewCounterNamer(o).FullyQualifiedName(), Type: TypeCounter, Help: o.Help, LabelNames: o.LabelNames, StatsdFormat: o.StatsdFormat}
+	case metrics.GaugeOpts:
+		return Entry{Name: namer.NewGaugeNamer(o).FullyQualifiedName(), Type: TypeGauge, Help: o.Help, LabelNames: o.LabelNames, StatsdFormat: o.StatsdFormat}
+	case metrics.HistogramOpts:
+		return Entry{Name: namer.NewHistogramNamer(o).FullyQualifiedName(), Type: TypeHistogram, Help: o.Help, LabelNames: o.LabelNames, StatsdFormat: o.StatsdFormat}
+	case metrics.SummaryOpts:
+		return Entry{Name: namer.NewSummaryNamer(o).FullyQualifiedName(), Type: TypeSummary, Help: o.Help, LabelNames: o.LabelNames, StatsdFormat: o.StatsdFormat}
+	default:
+		panic(fmt.Sprintf("unsupported metric options type %T", opts))
+	}
+}
+
+// equal 判断 e 和 other 是否描述同一个指标。
+func (e Entry) equal(other Entry) bool {
+	return e.Name == other.Name && e.Type == other.Type && e.Help == other.Help &&
+		e.StatsdFormat == other.StatsdFormat && strings.Join(e.LabelNames, ",") == strings.Join(other.LabelNames, ",")
+}
+
+// Registry 记录创建过的指标，用于列出一个节点定义的所有指标。
+type Registry struct {
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add 记录 opts 描述的指标，opts 的类型与 NewEntry 的要求相同。同一个指标被记录多次时只保留一份。
+func (r *Registry) Add(opts ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, o := range opts {
+		entry := NewEntry(o)
+		if !r.contains(entry) {
+			r.entries = append(r.entries, entry)
+		}
+	}
+}
+
+func (r *Registry) contains(entry Entry) bool {
+	for _, e := range r.entries {
+		if e.equal(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries 返回记录的指标，按照 Name 和 Type 排序。
+func (r *Registry) Entries() []Entry {
+	r.mutex.Lock()
+	entries := append([]Entry(nil), r.entries...)
+	r.mutex.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Type < entries[j].Type
+	})
+	return entries
+}
+
+// Check 检查是否有完全限定名相同而类型或者标签不同的指标，有的话返回描述所有冲突的错误。
+func (r *Registry) Check() error {
+	var conflicts []string
+	entries := r.Entries()
+	for i := 1; i < len(entries); i++ {
+		prev, e := entries[i-1], entries[i]
+		if prev.Name != e.Name {
+			continue
+		}
+		switch {
+		case prev.Type != e.Type:
+			conflicts = append(conflicts, fmt.Sprintf("metric %s is defined as both a %s and a %s", e.Name, prev.Type, e.Type))
+		case strings.Join(prev.LabelNames, ",") != strings.Join(e.LabelNames, ","):
+			conflicts = append(conflicts, fmt.Sprintf("metric %s is defined with labels [%s] and [%s]", e.Name, strings.Join(prev.LabelNames, " "), strings.Join(e.LabelNames, " ")))
+		}
+	}
+	if len(conflicts) != 0 {
+		return fmt.Errorf("conflicting metric definitions: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// TestingT 是 *testing.T 的子集。
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// RequireConsistent 在 opts 里有完全限定名相同而类型或者标签不同的指标时使测试失败，opts 的类型与 NewEntry 的要求相同。
+func RequireConsistent(t TestingT, opts ...interface{}) {
+	t.Helper()
+	r := NewRegistry()
+	r.Add(opts...)
+	if err := r.Check(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+// Provider 包装另一个 metrics.Provider，把通过它创建的每个指标记录到 Registry 里。
+type Provider struct {
+	provider metrics.Provider
+	registry *Registry
+}
+
+func NewProvider(provider metrics.Provider, registry *Registry) *Provider {
+	return &Provider{provider: provider, registry: registry}
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	p.registry.Add(opts)
+	return p.provider.NewCounter(opts)
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	p.registry.Add(opts)
+	return p.provider.NewGauge(opts)
+}
+
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	p.registry.Add(opts)
+	return p.provider.NewHistogram(opts)
+}
+
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	p.registry.Add(opts)
+	return p.provider.NewSummary(opts)
+}
@@ -0,0 +1,129 @@
+package registry_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/232425wxy/chainer/common/clogging/grpclogging"
+	logmetrics "github.com/232425wxy/chainer/common/clogging/metrics"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/cardinality"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/232425wxy/chainer/common/metrics/registry"
+	"github.com/232425wxy/chainer/common/metrics/validation"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	requestsOpts = metrics.CounterOpts{
+		Namespace:    "grpc",
+		Subsystem:    "server",
+		Name:         "requests",
+		Help:         "Number of requests | calls",
+		LabelNames:   []string{"method", "code"},
+		StatsdFormat: "%{#fqname}.%{method}.%{code}",
+	}
+	heightOpts   = metrics.GaugeOpts{Namespace: "ledger", Name: "height", Help: "Height of the chain"}
+	durationOpts = metrics.HistogramOpts{Name: "duration", Help: "Duration, in seconds", LabelNames: []string{"channel"}}
+	latencyOpts  = metrics.SummaryOpts{Namespace: "ledger", Name: "latency"}
+)
+
+func TestProvider(t *testing.T) {
+	r := registry.NewRegistry()
+	fake := &metricsfakes.Provider{}
+	fake.SetNewCounterReturns(&metricsfakes.Counter{})
+	p := registry.NewProvider(fake, r)
+
+	p.NewSummary(latencyOpts)
+	p.NewCounter(requestsOpts)
+	p.NewHistogram(durationOpts)
+	p.NewGauge(heightOpts)
+	p.NewCounter(requestsOpts)
+
+	require.Equal(t, 2, fake.NewCounterCallCount())
+	require.Equal(t, requestsOpts, fake.NewCounterArgsForCall(1))
+	require.Equal(t, []registry.Entry{
+		{Name: "duration", Type: registry.TypeHistogram, Help: "Duration, in seconds", LabelNames: []string{"channel"}},
+		{Name: "grpc.server.requests", Type: registry.TypeCounter, Help: "Number of requests | calls", LabelNames: []string{"method", "code"}, StatsdFormat: "%{#fqname}.%{method}.%{code}"},
+		{Name: "ledger.height", Type: registry.TypeGauge, Help: "Height of the chain"},
+		{Name: "ledger.latency", Type: registry.TypeSummary},
+	}, r.Entries())
+	require.NoError(t, r.Check())
+}
+
+func TestNewEntryUnsupported(t *testing.T) {
+	require.PanicsWithValue(t, "unsupported metric options type *metrics.CounterOpts", func() { registry.NewEntry(&requestsOpts) })
+}
+
+func TestCheck(t *testing.T) {
+	r := registry.NewRegistry()
+	r.Add(requestsOpts, metrics.GaugeOpts{Namespace: "grpc", Subsystem: "server", Name: "requests"})
+	r.Add(durationOpts, metrics.HistogramOpts{Name: "duration", LabelNames: []string{"peer"}})
+	r.Add(heightOpts, metrics.GaugeOpts{Namespace: "ledger", Name: "height", Help: "Other help"})
+
+	require.EqualError(t, r.Check(), "conflicting metric definitions: "+
+		"metric duration is defined with labels [channel] and [peer]; "+
+		"metric grpc.server.requests is defined as both a counter and a gauge")
+}
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRequireConsistent(t *testing.T) {
+	rt := &recordingT{}
+	registry.RequireConsistent(rt, requestsOpts, heightOpts, requestsOpts)
+	require.Empty(t, rt.errors)
+
+	registry.RequireConsistent(rt, heightOpts, metrics.SummaryOpts{Namespace: "ledger", Name: "height"})
+	require.Equal(t, []string{"conflicting metric definitions: metric ledger.height is defined as both a gauge and a summary"}, rt.errors)
+}
+
+// TestDefinedMetricsAreConsistent 检查仓库里定义的指标没有冲突。
+func TestDefinedMetricsAreConsistent(t *testing.T) {
+	registry.RequireConsistent(t,
+		logmetrics.CheckedCountOpts,
+		logmetrics.WriteCountOpts,
+		logmetrics.DroppedCountOpts,
+		logmetrics.SampledCountOpts,
+		logmetrics.RateLimitedCountOpts,
+		logmetrics.ReloadCountOpts,
+		logmetrics.LastReloadGaugeOpts,
+		grpclogging.ServerRequestsCompletedOpts,
+		grpclogging.ServerRequestDurationOpts,
+		grpclogging.ClientRequestsCompletedOpts,
+		grpclogging.ClientRequestDurationOpts,
+		cardinality.ViolationsOpts,
+		validation.ErrorsOpts,
+	)
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	r := registry.NewRegistry()
+	r.Add(requestsOpts, heightOpts)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, registry.WriteMarkdown(buf, r.Entries()))
+	require.Equal(t, "| Name | Type | Labels | Help | StatsD Format |\n"+
+		"|------|------|--------|------|---------------|\n"+
+		"| `grpc.server.requests` | counter | `method`, `code` | Number of requests \\| calls | `%{#fqname}.%{method}.%{code}` |\n"+
+		"| `ledger.height` | gauge |  | Height of the chain |  |\n", buf.String())
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := registry.NewRegistry()
+	r.Add(requestsOpts, durationOpts)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, registry.WriteCSV(buf, r.Entries()))
+	require.Equal(t, "name,type,labels,help,statsd_format\n"+
+		"duration,histogram,channel,\"Duration, in seconds\",\n"+
+		"grpc.server.requests,counter,method code,Number of requests | calls,%{#fqname}.%{method}.%{code}\n", buf.String())
+}
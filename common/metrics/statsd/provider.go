@@ -25,6 +25,11 @@ func (c *Counter) Add(delta float64) {
 	c.Counter.Add(delta)
 }
 
+// AddWithExemplar 忽略 exemplar，statsd 协议无法携带 exemplar。
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	c.Add(delta)
+}
+
 func (c *Counter) With(labelValues ...string) metrics.Counter {
 	name := c.namer.Format(labelValues...)
 	return &Counter{Counter: c.statsdProvider.NewCounter(name, 1)}
@@ -73,6 +78,31 @@ func (h *Histogram) Observe(value float64) {
 	h.Timing.Observe(value)
 }
 
+// ObserveWithExemplar 忽略 exemplar，statsd 协议无法携带 exemplar。
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	h.Observe(value)
+}
+
+// Summary 用 statsd 的 timing 近似表示，分位数由 statsd 服务端根据上报的样本计算，SummaryOpts 里的 Objectives、
+// MaxAge 和 AgeBuckets 不起作用。
+type Summary struct {
+	Timing         *statsd.Timing
+	namer          *namer.Namer
+	statsdProvider *statsd.Statsd
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	name := s.namer.Format(labelValues...)
+	return &Summary{Timing: s.statsdProvider.NewTiming(name, 1)}
+}
+
+func (s *Summary) Observe(value float64) {
+	if s.Timing == nil {
+		panic("label values must be provided by calling With")
+	}
+	s.Timing.Observe(value)
+}
+
 // NewCounter 新建一个Counter，如果opts里的LabelNames是空的，则暂时不会创建statsd的Counter，
 // 所以将来调用Add方法会因为空指针调用而panic，所以想避免该错误，则必须在调用Add方法之前，先调
 // 用With方法。
@@ -132,3 +162,21 @@ func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
 
 	return histogram
 }
+
+// NewSummary 新建一个Summary，与NewHistogram一样，如果opts里的LabelNames不是空的，则必须先调用With方法再调用
+// Observe方法。
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	if opts.StatsdFormat == "" {
+		opts.StatsdFormat = defaultFormat
+	}
+	summary := &Summary{
+		namer:          namer.NewSummaryNamer(opts),
+		statsdProvider: p.Statsd,
+	}
+
+	if len(opts.LabelNames) == 0 {
+		summary.Timing = p.Statsd.NewTiming(summary.namer.Format(), 1)
+	}
+
+	return summary
+}
@@ -0,0 +1,332 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
+	"github.com/232425wxy/chainer/common/metrics/namer"
+)
+
+// Mode 决定 Provider 如何处理不合法的指标选项和标签。
+type Mode int
+
+const (
+	// Lenient 丢弃不合法的观测值，使 ErrorsOpts 计数器加一，并且每个指标的每种错误只输出一次告警。
+	Lenient Mode = iota
+	// Strict 在发现错误时以 *Error 为值 panic，适合在测试里使用。
+	Strict
+)
+
+const (
+	ReasonInvalidOptions   = "invalid_options"
+	ReasonOddLabelValues   = "odd_label_values"
+	ReasonUndeclaredLabel  = "undeclared_label"
+	ReasonDuplicateLabel   = "duplicate_label"
+	ReasonMissingLabel     = "missing_label"
+	ReasonNegativeIncrease = "negative_increase"
+)
+
+// ErrorsOpts 统计 Lenient 模式下因为不合法而被丢弃的观测值和指标。
+var ErrorsOpts = metrics.CounterOpts{
+	Namespace:    "metrics",
+	Name:         "validation_errors",
+	Help:         "Number of observations dropped because of invalid metric options or labels",
+	LabelNames:   []string{"metric", "reason"},
+	LabelHelp:    map[string]string{"metric": "fully qualified name of the metric", "reason": "kind of the validation error"},
+	StatsdFormat: "%{#fqname}.%{metric}.%{reason}",
+}
+
+// Error 描述一个指标的选项或者标签不合法的原因。
+type Error struct {
+	Metric string
+	Reason string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("metric %s: %s", e.Metric, e.Detail)
+}
+
+type Config struct {
+	Mode Mode
+	// Logger 输出 Lenient 模式下的告警，默认为名为 "metrics.validation" 的 clogging 日志记录器。
+	Logger metrics.Logger
+}
+
+// Provider 包装另一个 metrics.Provider，在把指标和观测值交给它之前进行统一的检查，这样不同的提供者对不合法的使用
+// 有相同的表现，而不是各自 panic 或者静默地产生错误的时间序列。检查的内容包括：
+//   - 创建指标时，Namespace、Subsystem、Name 和 LabelNames 必须由字母、数字和下划线组成且不以数字开头，Name 不能为空，
+//     标签名不能重复，也不能以 "__" 开头；
+//   - 观测时，With 传入的标签名和标签值必须成对出现，标签名必须在 LabelNames 里声明过并且不能重复，LabelNames 里的
+//     每个标签都必须给出值；
+//   - Counter 不能减少。
+type Provider struct {
+	provider metrics.Provider
+	mode     Mode
+	logger   metrics.Logger
+	errors   metrics.Counter
+	warnOnce metrics.WarnOnce
+}
+
+func NewProvider(provider metrics.Provider, c Config) *Provider {
+	p := &Provider{
+		provider: provider,
+		mode:     c.Mode,
+		logger:   c.Logger,
+	}
+	if p.logger == nil {
+		p.logger = clogging.MustGetLogger("metrics.validation")
+	}
+	p.errors = provider.NewCounter(ErrorsOpts)
+	return p
+}
+
+// report 按照 Mode 处理 err。
+func (p *Provider) report(err *Error) {
+	if p.mode == Strict {
+		panic(err)
+	}
+	p.errors.With("metric", err.Metric, "reason", err.Reason).Add(1)
+
+	p.warnOnce.Warnf(p.logger, err.Metric+"\xff"+err.Reason, "Dropping observations of metric %s: %s", err.Metric, err.Detail)
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// newValidator 检查指标的选项，选项不合法时返回 nil。
+func (p *Provider) newValidator(n *namer.Namer, namespace, subsystem, name string, labelNames []string) *validator {
+	v := &validator{provider: p, name: n.FullyQualifiedName(), labelNames: labelNames, declared: map[string]struct{}{}}
+	invalid := func(format string, args ...interface{}) *validator {
+		p.report(&Error{Metric: v.name, Reason: ReasonInvalidOptions, Detail: fmt.Sprintf(format, args...)})
+		return nil
+	}
+
+	if name == "" {
+		return invalid("name must not be empty")
+	}
+	for _, part := range []string{namespace, subsystem, name} {
+		if part != "" && !identifierRegexp.MatchString(part) {
+			return invalid("invalid name component '%s'", part)
+		}
+	}
+	for _, label := range labelNames {
+		if !identifierRegexp.MatchString(label) || len(label) > 1 && label[:2] == "__" {
+			return invalid("invalid label name '%s'", label)
+		}
+		if _, ok := v.declared[label]; ok {
+			return invalid("label '%s' is declared more than once", label)
+		}
+		v.declared[label] = struct{}{}
+	}
+	return v
+}
+
+type validator struct {
+	provider   *Provider
+	name       string
+	labelNames []string
+	declared   map[string]struct{}
+}
+
+// with 检查一次 With 调用传入的标签，返回之前已经发现的错误或者本次发现的错误。
+func (v *validator) with(err *Error, labelValues []string) *Error {
+	if err != nil {
+		return err
+	}
+	if len(labelValues)%2 != 0 {
+		return &Error{Metric: v.name, Reason: ReasonOddLabelValues, Detail: fmt.Sprintf("label '%s' has no value", labelValues[len(labelValues)-1])}
+	}
+	for i := 0; i < len(labelValues); i += 2 {
+		if _, ok := v.declared[labelValues[i]]; !ok {
+			return &Error{Metric: v.name, Reason: ReasonUndeclaredLabel, Detail: fmt.Sprintf("label '%s' is not declared", labelValues[i])}
+		}
+	}
+	return nil
+}
+
+// check 在观测之前检查累积的标签，有错误时报告错误并返回 false。
+func (v *validator) check(err *Error, labelValues []string) bool {
+	if err == nil {
+		seen := map[string]struct{}{}
+		for i := 0; i < len(labelValues); i += 2 {
+			if _, ok := seen[labelValues[i]]; ok {
+				err = &Error{Metric: v.name, Reason: ReasonDuplicateLabel, Detail: fmt.Sprintf("label '%s' is given more than once", labelValues[i])}
+				break
+			}
+			seen[labelValues[i]] = struct{}{}
+		}
+		if err == nil && len(seen) < len(v.labelNames) {
+			for _, label := range v.labelNames {
+				if _, ok := seen[label]; !ok {
+					err = &Error{Metric: v.name, Reason: ReasonMissingLabel, Detail: fmt.Sprintf("label '%s' has no value", label)}
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		v.provider.report(err)
+		return false
+	}
+	return true
+}
+
+func (p *Provider) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	v := p.newValidator(namer.NewCounterNamer(opts), opts.Namespace, opts.Subsystem, opts.Name, opts.LabelNames)
+	if v == nil {
+		return disabled.Counter{}
+	}
+	return &Counter{validator: v, root: p.provider.NewCounter(opts)}
+}
+
+func (p *Provider) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	v := p.newValidator(namer.NewGaugeNamer(opts), opts.Namespace, opts.Subsystem, opts.Name, opts.LabelNames)
+	if v == nil {
+		return disabled.Gauge{}
+	}
+	return &Gauge{validator: v, root: p.provider.NewGauge(opts)}
+}
+
+func (p *Provider) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	v := p.newValidator(namer.NewHistogramNamer(opts), opts.Namespace, opts.Subsystem, opts.Name, opts.LabelNames)
+	if v == nil {
+		return disabled.Histogram{}
+	}
+	return &Histogram{validator: v, root: p.provider.NewHistogram(opts)}
+}
+
+func (p *Provider) NewSummary(opts metrics.SummaryOpts) metrics.Summary {
+	v := p.newValidator(namer.NewSummaryNamer(opts), opts.Namespace, opts.Subsystem, opts.Name, opts.LabelNames)
+	if v == nil {
+		return disabled.Summary{}
+	}
+	return &Summary{validator: v, root: p.provider.NewSummary(opts)}
+}
+
+type Counter struct {
+	*validator
+	root metrics.Counter
+	lvs  []string
+	err  *Error
+}
+
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return &Counter{validator: c.validator, root: c.root, lvs: append(c.lvs[:len(c.lvs):len(c.lvs)], labelValues...), err: c.with(c.err, labelValues)}
+}
+
+func (c *Counter) counter(delta float64) metrics.Counter {
+	if !c.check(c.err, c.lvs) {
+		return nil
+	}
+	if delta < 0 {
+		c.provider.report(&Error{Metric: c.name, Reason: ReasonNegativeIncrease, Detail: fmt.Sprintf("counter cannot decrease by %v", -delta)})
+		return nil
+	}
+	if len(c.lvs) == 0 {
+		return c.root
+	}
+	return c.root.With(c.lvs...)
+}
+
+func (c *Counter) Add(delta float64) {
+	if counter := c.counter(delta); counter != nil {
+		counter.Add(delta)
+	}
+}
+
+func (c *Counter) AddWithExemplar(delta float64, exemplar map[string]string) {
+	if counter := c.counter(delta); counter != nil {
+		counter.AddWithExemplar(delta, exemplar)
+	}
+}
+
+type Gauge struct {
+	*validator
+	root metrics.Gauge
+	lvs  []string
+	err  *Error
+}
+
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return &Gauge{validator: g.validator, root: g.root, lvs: append(g.lvs[:len(g.lvs):len(g.lvs)], labelValues...), err: g.with(g.err, labelValues)}
+}
+
+func (g *Gauge) gauge() metrics.Gauge {
+	if !g.check(g.err, g.lvs) {
+		return nil
+	}
+	if len(g.lvs) == 0 {
+		return g.root
+	}
+	return g.root.With(g.lvs...)
+}
+
+func (g *Gauge) Add(delta float64) {
+	if gauge := g.gauge(); gauge != nil {
+		gauge.Add(delta)
+	}
+}
+
+func (g *Gauge) Set(value float64) {
+	if gauge := g.gauge(); gauge != nil {
+		gauge.Set(value)
+	}
+}
+
+type Histogram struct {
+	*validator
+	root metrics.Histogram
+	lvs  []string
+	err  *Error
+}
+
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return &Histogram{validator: h.validator, root: h.root, lvs: append(h.lvs[:len(h.lvs):len(h.lvs)], labelValues...), err: h.with(h.err, labelValues)}
+}
+
+func (h *Histogram) histogram() metrics.Histogram {
+	if !h.check(h.err, h.lvs) {
+		return nil
+	}
+	if len(h.lvs) == 0 {
+		return h.root
+	}
+	return h.root.With(h.lvs...)
+}
+
+func (h *Histogram) Observe(value float64) {
+	if histogram := h.histogram(); histogram != nil {
+		histogram.Observe(value)
+	}
+}
+
+func (h *Histogram) ObserveWithExemplar(value float64, exemplar map[string]string) {
+	if histogram := h.histogram(); histogram != nil {
+		histogram.ObserveWithExemplar(value, exemplar)
+	}
+}
+
+type Summary struct {
+	*validator
+	root metrics.Summary
+	lvs  []string
+	err  *Error
+}
+
+func (s *Summary) With(labelValues ...string) metrics.Summary {
+	return &Summary{validator: s.validator, root: s.root, lvs: append(s.lvs[:len(s.lvs):len(s.lvs)], labelValues...), err: s.with(s.err, labelValues)}
+}
+
+func (s *Summary) Observe(value float64) {
+	if !s.check(s.err, s.lvs) {
+		return
+	}
+	if len(s.lvs) == 0 {
+		s.root.Observe(value)
+		return
+	}
+	s.root.With(s.lvs...).Observe(value)
+}
@@ -0,0 +1,133 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/dogstatsd"
+	"github.com/232425wxy/chainer/common/metrics/influx"
+	"github.com/232425wxy/chainer/common/metrics/metricsfakes"
+	"github.com/232425wxy/chainer/common/metrics/otlp"
+	"github.com/232425wxy/chainer/common/metrics/prometheus"
+	"github.com/232425wxy/chainer/common/metrics/statsd"
+	"github.com/232425wxy/chainer/common/metrics/validation"
+	kitdogstatsd "github.com/go-kit/kit/metrics/dogstatsd"
+	kitstatsd "github.com/go-kit/kit/metrics/statsd"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func providers() map[string]func() metrics.Provider {
+	return map[string]func() metrics.Provider{
+		"prometheus": func() metrics.Provider { return prometheus.NewRegistryProvider() },
+		"statsd":     func() metrics.Provider { return &statsd.Provider{Statsd: kitstatsd.New("", log.NewNopLogger())} },
+		"dogstatsd": func() metrics.Provider {
+			return &dogstatsd.Provider{DogStatsd: kitdogstatsd.New("", log.NewNopLogger())}
+		},
+		"influx": func() metrics.Provider { return &influx.Provider{Influx: influx.New(nil)} },
+		"otlp":   func() metrics.Provider { return otlp.NewProvider(otlp.Config{}) },
+	}
+}
+
+var counterOpts = metrics.CounterOpts{
+	Namespace:    "gossip",
+	Name:         "messages",
+	LabelNames:   []string{"channel", "type"},
+	StatsdFormat: "%{#fqname}.%{channel}.%{type}",
+}
+
+// misuses 是在每个提供者上都会以相同的方式处理的错误用法，以及对应的错误。
+var misuses = []struct {
+	reason string
+	err    string
+	use    func(p metrics.Provider)
+}{
+	{validation.ReasonMissingLabel, "metric gossip.messages: label 'channel' has no value", func(p metrics.Provider) { p.NewCounter(counterOpts).Add(1) }},
+	{validation.ReasonMissingLabel, "metric gossip.messages: label 'type' has no value", func(p metrics.Provider) { p.NewCounter(counterOpts).With("channel", "a").Add(1) }},
+	{validation.ReasonOddLabelValues, "metric gossip.messages: label 'type' has no value", func(p metrics.Provider) {
+		p.NewCounter(counterOpts).With("channel", "a", "type").Add(1)
+	}},
+	{validation.ReasonUndeclaredLabel, "metric gossip.messages: label 'peer' is not declared", func(p metrics.Provider) {
+		p.NewCounter(counterOpts).With("channel", "a", "type", "b").With("peer", "c").Add(1)
+	}},
+	{validation.ReasonDuplicateLabel, "metric gossip.messages: label 'channel' is given more than once", func(p metrics.Provider) {
+		p.NewCounter(counterOpts).With("channel", "a", "type", "b").With("channel", "c").Add(1)
+	}},
+	{validation.ReasonNegativeIncrease, "metric gossip.messages: counter cannot decrease by 1", func(p metrics.Provider) {
+		p.NewCounter(counterOpts).With("channel", "a", "type", "b").Add(-1)
+	}},
+	{validation.ReasonMissingLabel, "metric ledger.height: label 'channel' has no value", func(p metrics.Provider) {
+		p.NewGauge(metrics.GaugeOpts{Namespace: "ledger", Name: "height", LabelNames: []string{"channel"}}).Set(1)
+	}},
+	{validation.ReasonMissingLabel, "metric duration: label 'channel' has no value", func(p metrics.Provider) {
+		p.NewHistogram(metrics.HistogramOpts{Name: "duration", LabelNames: []string{"channel"}}).ObserveWithExemplar(1, nil)
+	}},
+	{validation.ReasonUndeclaredLabel, "metric latency: label 'tx' is not declared", func(p metrics.Provider) {
+		p.NewSummary(metrics.SummaryOpts{Name: "latency"}).With("tx", "1").Observe(1)
+	}},
+	{validation.ReasonInvalidOptions, "metric : name must not be empty", func(p metrics.Provider) { p.NewCounter(metrics.CounterOpts{}).Add(1) }},
+	{validation.ReasonInvalidOptions, "metric gossip.bad-name: invalid name component 'bad-name'", func(p metrics.Provider) {
+		p.NewGauge(metrics.GaugeOpts{Namespace: "gossip", Name: "bad-name"}).With("any", "thing").Set(1)
+	}},
+	{validation.ReasonInvalidOptions, "metric duration: label 'a' is declared more than once", func(p metrics.Provider) {
+		p.NewHistogram(metrics.HistogramOpts{Name: "duration", LabelNames: []string{"a", "a"}}).Observe(1)
+	}},
+	{validation.ReasonInvalidOptions, "metric latency: invalid label name '__name__'", func(p metrics.Provider) {
+		p.NewSummary(metrics.SummaryOpts{Name: "latency", LabelNames: []string{"__name__"}}).Observe(1)
+	}},
+}
+
+func TestStrict(t *testing.T) {
+	for name, newProvider := range providers() {
+		for _, m := range misuses {
+			t.Run(name+"/"+m.reason, func(t *testing.T) {
+				p := validation.NewProvider(newProvider(), validation.Config{Mode: validation.Strict})
+				require.PanicsWithError(t, m.err, func() { m.use(p) })
+			})
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	for name, newProvider := range providers() {
+		t.Run(name, func(t *testing.T) {
+			logger := &metricsfakes.Logger{}
+			inner := newProvider()
+			p := validation.NewProvider(inner, validation.Config{Logger: logger})
+			for _, m := range misuses {
+				require.NotPanics(t, func() { m.use(p) }, m.err)
+				require.NotPanics(t, func() { m.use(p) }, m.err)
+			}
+			require.Len(t, logger.Messages(), len(misuses)-1, "each metric and reason is logged once")
+			require.Equal(t, "Dropping observations of metric gossip.messages: label 'channel' has no value", logger.Messages()[0])
+
+			counter := p.NewCounter(counterOpts)
+			require.NotPanics(t, func() { counter.With("channel", "a").With("type", "b").Add(1) })
+		})
+	}
+}
+
+func TestLenientCountsErrors(t *testing.T) {
+	inner := otlp.NewProvider(otlp.Config{})
+	p := validation.NewProvider(inner, validation.Config{Logger: &metricsfakes.Logger{}})
+
+	counter := p.NewCounter(counterOpts)
+	counter.Add(1)
+	counter.With("channel", "a").Add(1)
+	counter.With("channel", "a", "type", "b").Add(-1)
+	counter.With("channel", "a", "type", "b").Add(2)
+
+	rm := inner.Collect()
+	errs, ok := rm.Metric("metrics.validation_errors")
+	require.True(t, ok)
+	points := errs.Data.(otlp.SumData).DataPoints
+	require.Len(t, points, 2)
+	require.Equal(t, []otlp.Attribute{{Key: "metric", Value: "gossip.messages"}, {Key: "reason", Value: "missing_label"}}, points[0].Attributes)
+	require.Equal(t, float64(2), points[0].Value)
+	require.Equal(t, "negative_increase", points[1].Attributes[1].Value)
+
+	messages, ok := rm.Metric("gossip.messages")
+	require.True(t, ok)
+	require.Len(t, messages.Data.(otlp.SumData).DataPoints, 1)
+	require.Equal(t, float64(2), messages.Data.(otlp.SumData).DataPoints[0].Value)
+}
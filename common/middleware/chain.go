@@ -0,0 +1,28 @@
+package middleware
+
+import "net/http"
+
+// Middleware 对一个 http.Handler 进行包装，在请求到达被包装的 handler 之前或之后执行额外的逻辑。
+type Middleware func(next http.Handler) http.Handler
+
+// Chain 按照顺序将多个 Middleware 组合在一起，排在前面的 Middleware 最先处理请求。
+type Chain struct {
+	middlewares []Middleware
+}
+
+func NewChain(middlewares ...Middleware) Chain {
+	return Chain{
+		middlewares: append([]Middleware{}, middlewares...),
+	}
+}
+
+func (c Chain) Handler(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h
+}
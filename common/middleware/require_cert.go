@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+type requireCert struct {
+	next http.Handler
+}
+
+// RequireCert 要求请求必须通过 TLS 发送，并且携带一个已经通过校验的客户端证书，否则返回 401。
+// 客户端证书的校验发生在 TLS 握手阶段，由 tls.Config 的 ClientCAs 决定哪些 CA 签发的证书是可信的。
+func RequireCert() Middleware {
+	return func(next http.Handler) http.Handler {
+		return &requireCert{next: next}
+	}
+}
+
+func (r *requireCert) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.TLS == nil:
+		fallthrough
+	case len(req.TLS.VerifiedChains) == 0:
+		w.WriteHeader(http.StatusUnauthorized)
+	default:
+		r.next.ServeHTTP(w, req)
+	}
+}
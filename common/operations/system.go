@@ -0,0 +1,378 @@
+package operations
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/clogging/httpadmin"
+	"github.com/232425wxy/chainer/common/healthz"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/batch"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
+	"github.com/232425wxy/chainer/common/metrics/dogstatsd"
+	"github.com/232425wxy/chainer/common/metrics/influx"
+	"github.com/232425wxy/chainer/common/metrics/multi"
+	"github.com/232425wxy/chainer/common/metrics/prometheus"
+	"github.com/232425wxy/chainer/common/metrics/statsd"
+	"github.com/232425wxy/chainer/common/metrics/validation"
+	kitdogstatsd "github.com/go-kit/kit/metrics/dogstatsd"
+	kitstatsd "github.com/go-kit/kit/metrics/statsd"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Logger 是 System 输出告警信息所使用的日志记录器，*clogging.ChainerLogger 实现了该接口。
+type Logger interface {
+	Warn(args ...interface{})
+	Warnf(template string, args ...interface{})
+}
+
+type Statsd struct {
+	Network       string
+	Address       string
+	WriteInterval time.Duration
+	Prefix        string
+}
+
+// Influx 配置以 InfluxDB 行协议发送指标的目标，Network 可以是 "udp" 或 "tcp"，Tags 会被添加到所有指标上。
+type Influx struct {
+	Network       string
+	Address       string
+	WriteInterval time.Duration
+	Tags          map[string]string
+}
+
+// MetricsOptions 的 Provider 可以是 "prometheus"、"statsd"、"dogstatsd" 或 "influx"，为空时不启用任何指标提供者，
+// System 使用 disabled.Provider，创建的指标不记录任何东西。
+// statsd 和 dogstatsd 分别使用 Statsd 和 DogStatsd 配置发送的目标，influx 使用 Influx。Provider 也可以是以逗号分隔的
+// 多个提供者，例如 "prometheus,statsd"，此时指标会同时输出到每个提供者，见 multi.Provider。
+type MetricsOptions struct {
+	Provider  string
+	Statsd    *Statsd
+	DogStatsd *Statsd
+	Influx    *Influx
+	// StrictValidation 为 true 时，不合法的指标选项和标签会导致 panic，否则相应的观测值被丢弃，见 validation.Provider。
+	StrictValidation bool
+}
+
+// Options 配置运维子系统。访问策略依赖客户端证书，所以只在 TLS.Enabled 为 true 时生效；不启用 TLS 时所有接口都不做
+// 身份验证，任何能连上 ListenAddress 的人都可以通过 PUT 或 PATCH /logspec 修改日志级别，Start 会为此输出一条告警。
+// 不启用 TLS 时应该让 ListenAddress 只监听本机或者受信任的网络。
+type Options struct {
+	ListenAddress string
+	Logger        Logger
+	Metrics       MetricsOptions
+	TLS           TLS
+	// Policies 以接口路径为键，配置访问各个接口需要满足的策略，为空时使用 DefaultPolicies。
+	Policies map[string]Policy
+	Version  string
+}
+
+// System 是节点的运维子系统，它在一个独立的 HTTP 监听地址上提供以下接口：
+//   - /logspec：GET 获取当前的日志规范，PUT 修改日志规范；
+//   - /metrics：当指标提供者是 prometheus 时，供 prometheus 抓取指标，以 OpenMetrics 格式抓取时会输出 exemplar；
+//   - /healthz：调用所有已注册的 HealthChecker，报告节点的健康状况；
+//   - /version：返回节点的版本信息。
+type System struct {
+	metrics.Provider
+	*healthz.HealthHandler
+
+	logger      Logger
+	options     Options
+	senders     []*metricsSender
+	sendTickers []*time.Ticker
+	cancel      context.CancelFunc
+	httpServer  *http.Server
+	mux         *http.ServeMux
+	mutex       sync.Mutex
+	addr        string
+}
+
+func NewSystem(o Options) *System {
+	logger := o.Logger
+	if logger == nil {
+		logger = clogging.MustGetLogger("operations.runner")
+	}
+
+	s := &System{
+		HealthHandler: healthz.NewHealthHandler(),
+		logger:        logger,
+		options:       o,
+		mux:           http.NewServeMux(),
+	}
+
+	s.initializeServer()
+	s.initializeLoggingHandler()
+	s.initializeMetricsProvider()
+	s.initializeHealthCheckHandler()
+	s.initializeVersionInfoHandler()
+
+	return s
+}
+
+// Start 开始监听 ListenAddress 并在后台提供服务，如果指标提供者是 statsd、dogstatsd 或 influx，还会启动定期发送指标的循环。
+func (s *System) Start() error {
+	if err := s.startMetricsTickers(); err != nil {
+		return err
+	}
+
+	listener, err := s.listen()
+	if err != nil {
+		s.stopMetricsTickers()
+		return err
+	}
+
+	s.mutex.Lock()
+	s.addr = listener.Addr().String()
+	s.mutex.Unlock()
+
+	if !s.options.TLS.Enabled {
+		s.logger.Warnf("TLS is disabled for the operations endpoint at %s; PUT and PATCH /logspec are unauthenticated and anyone who can reach the endpoint can change the log levels", listener.Addr())
+	}
+
+	go s.httpServer.Serve(listener)
+
+	return nil
+}
+
+// Stop 停止发送指标并关闭 HTTP 服务。
+func (s *System) Stop() error {
+	s.stopMetricsTickers()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// Addr 返回 System 实际监听的地址，当 ListenAddress 的端口是 0 时，可以通过它获得系统分配的端口。
+func (s *System) Addr() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addr
+}
+
+// Log 使得 System 可以作为 go-kit statsd 和 dogstatsd 的日志记录器。
+func (s *System) Log(keyvals ...interface{}) error {
+	s.logger.Warn(keyvals...)
+	return nil
+}
+
+func (s *System) listen() (net.Listener, error) {
+	listener, err := net.Listen("tcp", s.options.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := s.options.TLS.Config()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return listener, nil
+}
+
+// handle 在 mux 上注册 handler，启用 TLS 时会按照为 pattern 配置的访问策略对 handler 进行包装。
+func (s *System) handle(pattern string, handler http.Handler) {
+	if s.options.TLS.Enabled {
+		policies := s.options.Policies
+		if policies == nil {
+			policies = DefaultPolicies()
+		}
+		if policy, ok := policies[pattern]; ok {
+			handler = policy.handler(handler)
+		}
+	}
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *System) initializeServer() {
+	s.httpServer = &http.Server{
+		Addr:         s.options.ListenAddress,
+		Handler:      s.mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 2 * time.Minute,
+	}
+}
+
+func (s *System) initializeLoggingHandler() {
+	s.handle("/logspec", httpadmin.NewSpecHandler())
+}
+
+func (s *System) initializeMetricsProvider() {
+	m := s.options.Metrics
+	var providers []metrics.Provider
+	for _, name := range strings.Split(m.Provider, ",") {
+		if provider := s.newMetricsProvider(strings.TrimSpace(name)); provider != nil {
+			providers = append(providers, provider)
+		}
+	}
+
+	switch len(providers) {
+	case 0:
+		// 没有启用任何指标提供者时，System 作为 metrics.Provider 仍然可以使用，只是不记录任何指标。
+		s.Provider = &disabled.Provider{}
+		return
+	case 1:
+		s.Provider = providers[0]
+	default:
+		s.Provider = multi.NewProvider(providers, multi.Config{})
+	}
+
+	mode := validation.Lenient
+	if m.StrictValidation {
+		mode = validation.Strict
+	}
+	s.Provider = validation.NewProvider(s.Provider, validation.Config{Mode: mode})
+}
+
+// newMetricsProvider 创建名为 name 的指标提供者，需要定期发送指标的提供者会被添加到 s.senders 里。
+func (s *System) newMetricsProvider(name string) metrics.Provider {
+	m := s.options.Metrics
+	switch strings.ToLower(name) {
+	case "statsd":
+		st := kitstatsd.New(statsdPrefix(m.Statsd), s)
+		if m.Statsd != nil {
+			s.senders = append(s.senders, &metricsSender{network: m.Statsd.Network, address: m.Statsd.Address, writeInterval: m.Statsd.WriteInterval, sendLoop: st.SendLoop})
+		}
+		return &statsd.Provider{Statsd: st}
+
+	case "dogstatsd":
+		d := kitdogstatsd.New(statsdPrefix(m.DogStatsd), s)
+		if m.DogStatsd != nil {
+			s.senders = append(s.senders, &metricsSender{network: m.DogStatsd.Network, address: m.DogStatsd.Address, writeInterval: m.DogStatsd.WriteInterval, sendLoop: s.batchSendLoop(d.WriteTo)})
+		}
+		return &dogstatsd.Provider{DogStatsd: d}
+
+	case "influx":
+		var tags map[string]string
+		if m.Influx != nil {
+			tags = m.Influx.Tags
+		}
+		i := influx.New(tags)
+		if m.Influx != nil {
+			s.senders = append(s.senders, &metricsSender{network: m.Influx.Network, address: m.Influx.Address, writeInterval: m.Influx.WriteInterval, sendLoop: s.batchSendLoop(i.WriteTo)})
+		}
+		return &influx.Provider{Influx: i}
+
+	case "prometheus":
+		// 每个 System 使用独立的 Registry，这样同一个进程里的多个 System 不会因为重复注册指标而冲突。
+		registry := prom.NewRegistry()
+		registry.MustRegister(prom.NewGoCollector(), prom.NewProcessCollector(prom.ProcessCollectorOpts{}))
+		provider := prometheus.NewProvider(registry, registry)
+		s.handle("/metrics", promhttp.InstrumentMetricHandler(registry, promhttp.HandlerFor(provider.Gatherer(), promhttp.HandlerOpts{EnableOpenMetrics: true})))
+		return provider
+
+	case "":
+		return nil
+
+	default:
+		s.logger.Warnf("Unknown provider type: %s; metrics disabled", name)
+		return nil
+	}
+}
+
+func (s *System) initializeHealthCheckHandler() {
+	s.handle("/healthz", s.HealthHandler)
+}
+
+func (s *System) initializeVersionInfoHandler() {
+	s.handle("/version", &versionInfoHandler{version: s.options.Version})
+}
+
+// metricsSender 描述如何把 statsd、dogstatsd 或 influx 指标定期发送出去。
+type metricsSender struct {
+	network       string
+	address       string
+	writeInterval time.Duration
+	sendLoop      func(ctx context.Context, c <-chan time.Time, network, address string)
+}
+
+func statsdPrefix(st *Statsd) string {
+	prefix := ""
+	if st != nil {
+		prefix = st.Prefix
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix = prefix + "."
+	}
+	return prefix
+}
+
+// batchSendLoop 返回把 writeTo 写出的指标合并成包之后发送的循环。
+func (s *System) batchSendLoop(writeTo batch.WriteToFunc) func(ctx context.Context, c <-chan time.Time, network, address string) {
+	return func(ctx context.Context, c <-chan time.Time, network, address string) {
+		batch.SendLoop(ctx, c, network, address, batch.DefaultMaxPacketSize, writeTo, s)
+	}
+}
+
+func (s *System) startMetricsTickers() error {
+	if len(s.senders) == 0 {
+		return nil
+	}
+
+	// 预先连接一次，以便尽早发现错误的地址。
+	for _, sender := range s.senders {
+		c, err := net.Dial(sender.network, sender.address)
+		if err != nil {
+			return err
+		}
+		c.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cancel = cancel
+	for _, sender := range s.senders {
+		writeInterval := sender.writeInterval
+		if writeInterval <= 0 {
+			writeInterval = 10 * time.Second
+		}
+		ticker := time.NewTicker(writeInterval)
+		s.sendTickers = append(s.sendTickers, ticker)
+		go sender.sendLoop(ctx, ticker.C, sender.network, sender.address)
+	}
+	return nil
+}
+
+func (s *System) stopMetricsTickers() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, ticker := range s.sendTickers {
+		ticker.Stop()
+	}
+	s.sendTickers = nil
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+type versionInfoHandler struct {
+	version string
+}
+
+func (v *versionInfoHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	bz, err := json.Marshal(map[string]string{"version": v.version})
+	if err != nil {
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	resp.Write(bz)
+}
@@ -0,0 +1,315 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/232425wxy/chainer/common/metrics"
+	"github.com/232425wxy/chainer/common/metrics/disabled"
+	"github.com/stretchr/testify/require"
+)
+
+type checkerFunc func(ctx context.Context) error
+
+func (f checkerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+func newSystem(t *testing.T, o Options) (*System, *http.Client) {
+	o.ListenAddress = "127.0.0.1:0"
+	s := NewSystem(o)
+	require.NoError(t, s.Start())
+	t.Cleanup(func() { s.Stop() })
+	return s, &http.Client{Timeout: 5 * time.Second}
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	bz, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(bz)
+}
+
+func TestSystemLogSpec(t *testing.T) {
+	defer clogging.Reset()
+	s, client := newSystem(t, Options{})
+	url := fmt.Sprintf("http://%s/logspec", s.Addr())
+
+	code, body := get(t, client, url)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"spec":"info"}`, body)
+
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(`{"spec":"gossip=debug:warn"}`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, "gossip=debug:warn", clogging.Global.Spec())
+
+	code, body = get(t, client, url)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"spec":"gossip=debug:warn"}`, body)
+}
+
+type recordingLogger struct {
+	mutex    sync.Mutex
+	warnings []string
+}
+
+func (l *recordingLogger) Warn(args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprint(args...))
+}
+
+func (l *recordingLogger) Warnf(template string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(template, args...))
+}
+
+func TestSystemWarnsWithoutTLS(t *testing.T) {
+	logger := &recordingLogger{}
+	s, _ := newSystem(t, Options{Logger: logger})
+	require.Len(t, logger.warnings, 1)
+	require.Contains(t, logger.warnings[0], s.Addr())
+	require.Contains(t, logger.warnings[0], "PUT and PATCH /logspec are unauthenticated")
+}
+
+func TestSystemPrometheusMetrics(t *testing.T) {
+	s, client := newSystem(t, Options{Metrics: MetricsOptions{Provider: "prometheus"}})
+	require.NotNil(t, s.Provider)
+
+	counter := s.NewCounter(metrics.CounterOpts{
+		Namespace:  "operations",
+		Name:       "test_requests",
+		Help:       "test counter",
+		LabelNames: []string{"kind"},
+	})
+	counter.With("kind", "unit").Add(3)
+
+	code, body := get(t, client, fmt.Sprintf("http://%s/metrics", s.Addr()))
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `operations_test_requests{kind="unit"} 3`)
+}
+
+func TestSystemPrometheusMetricsPerSystem(t *testing.T) {
+	s1, client := newSystem(t, Options{Metrics: MetricsOptions{Provider: "prometheus"}})
+	s2, _ := newSystem(t, Options{Metrics: MetricsOptions{Provider: "prometheus"}})
+
+	opts := metrics.CounterOpts{Namespace: "operations", Name: "node_requests", Help: "test counter"}
+	s1.NewCounter(opts).Add(1)
+	s2.NewCounter(opts).Add(2)
+
+	_, body := get(t, client, fmt.Sprintf("http://%s/metrics", s1.Addr()))
+	require.Contains(t, body, "operations_node_requests 1")
+	_, body = get(t, client, fmt.Sprintf("http://%s/metrics", s2.Addr()))
+	require.Contains(t, body, "operations_node_requests 2")
+	require.Contains(t, body, "go_goroutines")
+}
+
+func TestSystemMetricsDisabled(t *testing.T) {
+	s, client := newSystem(t, Options{})
+	require.IsType(t, &disabled.Provider{}, s.Provider)
+
+	code, _ := get(t, client, fmt.Sprintf("http://%s/metrics", s.Addr()))
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestSystemMetricsDisabledProvider(t *testing.T) {
+	for _, provider := range []string{"", "unknown"} {
+		s := NewSystem(Options{Metrics: MetricsOptions{Provider: provider}})
+		require.NotPanics(t, func() {
+			s.NewCounter(metrics.CounterOpts{Namespace: "operations", Name: "requests", LabelNames: []string{"kind"}}).With("kind", "unit").Add(1)
+			s.NewGauge(metrics.GaugeOpts{Namespace: "operations", Name: "height"}).Set(1)
+			s.NewHistogram(metrics.HistogramOpts{Namespace: "operations", Name: "duration"}).Observe(1)
+			s.NewSummary(metrics.SummaryOpts{Namespace: "operations", Name: "latency"}).Observe(1)
+		}, provider)
+	}
+}
+
+func TestSystemStatsdMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, _ := newSystem(t, Options{
+		Metrics: MetricsOptions{
+			Provider: "statsd",
+			Statsd: &Statsd{
+				Network:       "udp",
+				Address:       conn.LocalAddr().String(),
+				WriteInterval: 10 * time.Millisecond,
+				Prefix:        "chainer",
+			},
+		},
+	})
+
+	counter := s.NewCounter(metrics.CounterOpts{Namespace: "operations", Name: "statsd_counter"})
+	counter.Add(2)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "chainer.operations.statsd_counter:2.000000|c\n", string(buf[:n]))
+}
+
+func TestSystemDogStatsdMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, _ := newSystem(t, Options{
+		Metrics: MetricsOptions{
+			Provider: "dogstatsd",
+			DogStatsd: &Statsd{
+				Network:       "udp",
+				Address:       conn.LocalAddr().String(),
+				WriteInterval: 10 * time.Millisecond,
+				Prefix:        "chainer",
+			},
+		},
+	})
+
+	counter := s.NewCounter(metrics.CounterOpts{Namespace: "operations", Name: "dogstatsd_counter", LabelNames: []string{"kind"}})
+	counter.With("kind", "unit").Add(2)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "chainer.operations.dogstatsd_counter:2.000000|c|#kind:unit\n", string(buf[:n]))
+}
+
+func TestSystemInfluxMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, _ := newSystem(t, Options{
+		Metrics: MetricsOptions{
+			Provider: "influx",
+			Influx: &Influx{
+				Network:       "udp",
+				Address:       conn.LocalAddr().String(),
+				WriteInterval: 10 * time.Millisecond,
+				Tags:          map[string]string{"host": "peer0"},
+			},
+		},
+	})
+
+	counter := s.NewCounter(metrics.CounterOpts{Namespace: "operations", Name: "influx_counter", LabelNames: []string{"kind"}})
+	counter.With("kind", "unit").Add(2)
+	s.NewGauge(metrics.GaugeOpts{Namespace: "operations", Name: "influx_gauge"}).Set(5)
+
+	// 两个指标可能在不同的周期里写出，gauge 每个周期都会写出，所以一直读到两者都出现为止。
+	var counterLine, gaugeLine string
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for counterLine == "" || gaugeLine == "" {
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		for _, line := range strings.Split(strings.TrimSuffix(string(buf[:n]), "\n"), "\n") {
+			if strings.HasPrefix(line, "operations.influx_counter") {
+				counterLine = line
+			} else {
+				gaugeLine = line
+			}
+		}
+	}
+	require.Regexp(t, `^operations\.influx_counter,host=peer0,kind=unit count=2 \d+$`, counterLine)
+	require.Regexp(t, `^operations\.influx_gauge,host=peer0 value=5 \d+$`, gaugeLine)
+}
+
+func TestSystemMultipleProviders(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, client := newSystem(t, Options{
+		Metrics: MetricsOptions{
+			Provider: "prometheus, statsd",
+			Statsd: &Statsd{
+				Network:       "udp",
+				Address:       conn.LocalAddr().String(),
+				WriteInterval: 10 * time.Millisecond,
+			},
+		},
+	})
+
+	counter := s.NewCounter(metrics.CounterOpts{
+		Namespace:    "operations",
+		Name:         "migrated",
+		Help:         "test counter",
+		LabelNames:   []string{"kind"},
+		StatsdFormat: "%{#fqname}.%{kind}",
+	})
+	counter.With("kind", "unit").Add(2)
+
+	_, body := get(t, client, fmt.Sprintf("http://%s/metrics", s.Addr()))
+	require.Contains(t, body, `operations_migrated{kind="unit"} 2`)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "operations.migrated.unit:2.000000|c\n", string(buf[:n]))
+}
+
+func TestSystemStatsdBadAddress(t *testing.T) {
+	s := NewSystem(Options{
+		ListenAddress: "127.0.0.1:0",
+		Metrics: MetricsOptions{
+			Provider: "statsd",
+			Statsd:   &Statsd{Network: "bad-network", Address: "127.0.0.1:0"},
+		},
+	})
+	require.EqualError(t, s.Start(), "dial bad-network: unknown network bad-network")
+}
+
+func TestSystemHealthz(t *testing.T) {
+	s, client := newSystem(t, Options{})
+	url := fmt.Sprintf("http://%s/healthz", s.Addr())
+
+	code, body := get(t, client, url)
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `"status":"OK"`)
+
+	require.NoError(t, s.RegisterChecker("consensus", checkerFunc(func(context.Context) error {
+		return errors.New("not leader")
+	})))
+	code, body = get(t, client, url)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Contains(t, body, `"failed_checks":[{"component":"consensus","reason":"not leader"}]`)
+}
+
+func TestSystemVersion(t *testing.T) {
+	s, client := newSystem(t, Options{Version: "v1.2.3"})
+
+	code, body := get(t, client, fmt.Sprintf("http://%s/version", s.Addr()))
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"version":"v1.2.3"}`, body)
+}
+
+func TestSystemStop(t *testing.T) {
+	s := NewSystem(Options{ListenAddress: "127.0.0.1:0"})
+	require.NoError(t, s.Start())
+	addr := s.Addr()
+	require.NoError(t, s.Stop())
+
+	_, err := net.DialTimeout("tcp", addr, time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package operations
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/232425wxy/chainer/common/middleware"
+)
+
+// TLS 配置运维接口的 TLS 监听。ClientCACertFiles 里的 CA 证书被用来校验客户端证书，
+// ClientCertRequired 为 true 时所有请求都必须携带客户端证书，否则客户端证书是可选的，
+// 由各个接口的 Policy 决定是否必须提供。
+type TLS struct {
+	Enabled            bool
+	CertFile           string
+	KeyFile            string
+	ClientCertRequired bool
+	ClientCACertFiles  []string
+}
+
+func (t TLS) Config() (*tls.Config, error) {
+	if !t.Enabled {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	caCertPool := x509.NewCertPool()
+	for _, caPath := range t.ClientCACertFiles {
+		caPem, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, err
+		}
+		if !caCertPool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("failed to parse client CA certificate: %s", caPath)
+		}
+	}
+
+	clientAuth := tls.VerifyClientCertIfGiven
+	if t.ClientCertRequired {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    caCertPool,
+		ClientAuth:   clientAuth,
+	}, nil
+}
+
+// Policy 描述访问某个接口需要满足的条件，只在启用 TLS 时生效。
+type Policy struct {
+	// RequireClientCert 为 true 时，请求必须携带一个由 TLS.ClientCACertFiles 中的 CA 签发的客户端证书。
+	RequireClientCert bool
+	// Methods 限定策略作用的请求方法，为空时作用于所有请求方法。
+	Methods []string
+}
+
+// DefaultPolicies 是 Options.Policies 为空时使用的访问策略：修改日志规范需要客户端证书，其余接口对所有人开放。
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		"/logspec": {RequireClientCert: true, Methods: []string{http.MethodPut, http.MethodPatch}},
+	}
+}
+
+func (p Policy) appliesTo(method string) bool {
+	if len(p.Methods) == 0 {
+		return true
+	}
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// handler 按照策略对 next 进行包装，策略不适用的请求方法直接交给 next 处理。
+func (p Policy) handler(next http.Handler) http.Handler {
+	if !p.RequireClientCert {
+		return next
+	}
+
+	protected := middleware.NewChain(middleware.RequireCert()).Handler(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.appliesTo(r.Method) {
+			protected.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,232 @@
+package operations
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/232425wxy/chainer/common/clogging"
+	"github.com/stretchr/testify/require"
+)
+
+type certKeyPair struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (p *certKeyPair) tlsCert(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(p.certPEM, p.keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+func (p *certKeyPair) writeTo(t *testing.T, dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+"-cert.pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	require.NoError(t, os.WriteFile(certFile, p.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, p.keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+// newCertKeyPair 生成一对证书和私钥，issuer 为 nil 时生成自签名的 CA 证书。
+func newCertKeyPair(t *testing.T, cn string, issuer *certKeyPair) *certKeyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	parent, signer := template, key
+	if issuer == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = issuer.cert, issuer.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &certKeyPair{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+type tlsFixture struct {
+	serverCA      *certKeyPair
+	clientCA      *certKeyPair
+	trustedClient *certKeyPair
+	rogueClient   *certKeyPair
+	tls           TLS
+}
+
+func newTLSFixture(t *testing.T) *tlsFixture {
+	dir := t.TempDir()
+
+	serverCA := newCertKeyPair(t, "server-ca", nil)
+	server := newCertKeyPair(t, "server", serverCA)
+	clientCA := newCertKeyPair(t, "client-ca", nil)
+	rogueCA := newCertKeyPair(t, "rogue-ca", nil)
+
+	certFile, keyFile := server.writeTo(t, dir, "server")
+	clientCAFile, _ := clientCA.writeTo(t, dir, "client-ca")
+
+	return &tlsFixture{
+		serverCA:      serverCA,
+		clientCA:      clientCA,
+		trustedClient: newCertKeyPair(t, "trusted-client", clientCA),
+		rogueClient:   newCertKeyPair(t, "rogue-client", rogueCA),
+		tls: TLS{
+			Enabled:           true,
+			CertFile:          certFile,
+			KeyFile:           keyFile,
+			ClientCACertFiles: []string{clientCAFile},
+		},
+	}
+}
+
+func (f *tlsFixture) client(t *testing.T, clientCert *certKeyPair) *http.Client {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(f.serverCA.cert)
+	tlsConfig := &tls.Config{RootCAs: rootCAs}
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{clientCert.tlsCert(t)}
+	}
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	config, err := TLS{}.Config()
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	f := newTLSFixture(t)
+	config, err = f.tls.Config()
+	require.NoError(t, err)
+	require.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth)
+	require.Len(t, config.Certificates, 1)
+
+	f.tls.ClientCertRequired = true
+	config, err = f.tls.Config()
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+
+	_, err = TLS{Enabled: true, CertFile: "missing-cert.pem", KeyFile: "missing-key.pem"}.Config()
+	require.Error(t, err)
+
+	badCA := filepath.Join(t.TempDir(), "bad-ca.pem")
+	require.NoError(t, os.WriteFile(badCA, []byte("not a certificate"), 0o600))
+	f.tls.ClientCACertFiles = []string{badCA}
+	_, err = f.tls.Config()
+	require.EqualError(t, err, fmt.Sprintf("failed to parse client CA certificate: %s", badCA))
+}
+
+func TestSystemTLSDefaultPolicies(t *testing.T) {
+	defer clogging.Reset()
+	f := newTLSFixture(t)
+	logger := &recordingLogger{}
+	s, _ := newSystem(t, Options{TLS: f.tls, Logger: logger, Version: "v1.0.0"})
+	require.Empty(t, logger.warnings)
+	base := fmt.Sprintf("https://%s", s.Addr())
+
+	putSpec := func(client *http.Client) int {
+		req, err := http.NewRequest(http.MethodPut, base+"/logspec", strings.NewReader(`{"spec":"debug"}`))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	anonymous := f.client(t, nil)
+	for _, path := range []string{"/healthz", "/version", "/logspec"} {
+		code, _ := get(t, anonymous, base+path)
+		require.Equal(t, http.StatusOK, code, path)
+	}
+	require.Equal(t, http.StatusUnauthorized, putSpec(anonymous))
+	require.Equal(t, "info", clogging.Global.Spec())
+
+	require.Equal(t, http.StatusNoContent, putSpec(f.client(t, f.trustedClient)))
+	require.Equal(t, "debug", clogging.Global.Spec())
+
+	// 由不受信任的 CA 签发的客户端证书不会被接受。
+	require.Equal(t, http.StatusUnauthorized, putSpec(f.client(t, f.rogueClient)))
+	require.Equal(t, "debug", clogging.Global.Spec())
+}
+
+func TestSystemTLSCustomPolicies(t *testing.T) {
+	f := newTLSFixture(t)
+	s, _ := newSystem(t, Options{
+		TLS: f.tls,
+		Policies: map[string]Policy{
+			"/version": {RequireClientCert: true},
+		},
+	})
+	base := fmt.Sprintf("https://%s", s.Addr())
+
+	anonymous := f.client(t, nil)
+	code, _ := get(t, anonymous, base+"/version")
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, _ = get(t, anonymous, base+"/healthz")
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = get(t, f.client(t, f.trustedClient), base+"/version")
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestSystemTLSClientCertRequired(t *testing.T) {
+	f := newTLSFixture(t)
+	f.tls.ClientCertRequired = true
+	s, _ := newSystem(t, Options{TLS: f.tls})
+	base := fmt.Sprintf("https://%s", s.Addr())
+
+	_, err := f.client(t, nil).Get(base + "/healthz")
+	require.Error(t, err)
+
+	code, _ := get(t, f.client(t, f.trustedClient), base+"/healthz")
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestSystemTLSBadConfig(t *testing.T) {
+	s := NewSystem(Options{
+		ListenAddress: "127.0.0.1:0",
+		TLS:           TLS{Enabled: true, CertFile: "missing-cert.pem", KeyFile: "missing-key.pem"},
+	})
+	require.Error(t, s.Start())
+}